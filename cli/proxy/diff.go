@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/laurentsimon/jupyter-lineage/cli/proxy/internal/utils"
+	"github.com/laurentsimon/jupyter-lineage/pkg/diff"
+)
+
+// diffCommand compares the provenances of two sessions.
+func diffCommand(prog string, arguments []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the differences as JSON")
+	flags.Usage = func() {
+		utils.Log("Usage: %s diff [-json] oldProvenancePath newProvenancePath\n", prog)
+		flags.PrintDefaults()
+		os.Exit(1)
+	}
+	flags.Parse(arguments)
+	if flags.NArg() != 2 {
+		flags.Usage()
+	}
+	oldContent, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		fatal(fmt.Errorf("read old provenance: %w", err))
+	}
+	newContent, err := os.ReadFile(flags.Arg(1))
+	if err != nil {
+		fatal(fmt.Errorf("read new provenance: %w", err))
+	}
+	r, err := diff.Provenance(oldContent, newContent)
+	if err != nil {
+		fatal(fmt.Errorf("diff: %w", err))
+	}
+	if !*asJSON {
+		fmt.Print(r.String())
+		return
+	}
+	b, err := r.ToBytes()
+	if err != nil {
+		fatal(fmt.Errorf("diff: %w", err))
+	}
+	fmt.Println(string(b))
+}
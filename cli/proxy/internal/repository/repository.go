@@ -41,6 +41,12 @@ func (c *Client) Init() error {
 	if err != nil {
 		return fmt.Errorf("git init: (stderr=%q): %w", stderr, err)
 	}
+	// Create the first commit, so the repository has a digest
+	// even if nothing is recorded.
+	_, stderr, err = c.run("git", "commit", "--allow-empty", "-m", "init")
+	if err != nil {
+		return fmt.Errorf("git commit --allow-empty -m \"init\": (stderr=%q): %w", stderr, err)
+	}
 	return nil
 }
 
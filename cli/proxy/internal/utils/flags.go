@@ -0,0 +1,15 @@
+package utils
+
+import "strings"
+
+// StringsFlag is a flag that may be repeated.
+type StringsFlag []string
+
+func (f *StringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *StringsFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
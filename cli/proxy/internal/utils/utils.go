@@ -13,7 +13,7 @@ func Log(format string, a ...any) {
 func StringToUint(s string) uint {
 	i, err := strconv.Atoi(s)
 	if err != nil {
-		Log("convert %q to uint: %w", s, err)
+		Log("convert %q to uint: %v\n", s, err)
 		os.Exit(2)
 	}
 	return uint(i)
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/laurentsimon/jupyter-lineage/cli/proxy/internal/utils"
+	"github.com/laurentsimon/jupyter-lineage/pkg/lockfile"
+)
+
+func lockfileUsage(prog string) {
+	msg := "" +
+		"Usage: %s lockfile provenancePath [lockfilePath]\n" +
+		"Writes the lockfile to stdout if lockfilePath is not provided\n"
+	utils.Log(msg, prog)
+	os.Exit(1)
+}
+
+// lockfileCommand generates a lockfile from a provenance.
+func lockfileCommand(prog string, arguments []string) {
+	if len(arguments) != 1 && len(arguments) != 2 {
+		lockfileUsage(prog)
+	}
+	content, err := os.ReadFile(arguments[0])
+	if err != nil {
+		fatal(fmt.Errorf("read provenance: %w", err))
+	}
+	l, err := lockfile.FromProvenance(content)
+	if err != nil {
+		fatal(fmt.Errorf("lockfile from provenance: %w", err))
+	}
+	b, err := l.ToBytes()
+	if err != nil {
+		fatal(fmt.Errorf("lockfile: %w", err))
+	}
+	if len(arguments) == 1 {
+		fmt.Println(string(b))
+		return
+	}
+	if err := os.WriteFile(arguments[1], b, 0644); err != nil {
+		fatal(fmt.Errorf("write lockfile: %w", err))
+	}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"crypto"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
@@ -14,15 +16,21 @@ import (
 	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy"
 	handler "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http"
 	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http/allow"
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http/policy"
+	"github.com/laurentsimon/jupyter-lineage/pkg/lockfile"
 	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
 )
 
 func usage(prog string) {
 	msg := "" +
-		"Usage: %s srcIP, srcShellPort, srcStdinPort, srcIOPubPort, srcControlPort, srcHeartBeatPort\n" +
+		"Usage: %s [-policy policyPath] [-lockfile lockfilePath] [-signing-key keyPath [-key-id keyID]] [-output pattern]... [-output-dir outputDir] [-notebook notebookPath] srcIP, srcShellPort, srcStdinPort, srcIOPubPort, srcControlPort, srcHeartBeatPort\n" +
 		"dstIP, dstShellPort, dstStdinPort, dstIOPubPort, dstControlPort, dstHeartBeatPort\n" +
-		"provenancePath, certDir"
+		"provenancePath, certDir\n" +
+		"       %[1]s lockfile provenancePath [lockfilePath]\n" +
+		"       %[1]s verify [-key keyPath] [-repository repoDir] [-cache cacheDir]... provenancePath\n" +
+		"       %[1]s diff [-json] oldProvenancePath newProvenancePath\n"
 	utils.Log(msg, prog)
+	flag.PrintDefaults()
 	os.Exit(1)
 }
 
@@ -39,7 +47,29 @@ func (c *allowConfig) WantRecord(*http.Response, handler.Context) (bool, *http.H
 }
 
 func main() {
-	arguments := os.Args[1:]
+	if len(os.Args) > 1 && os.Args[1] == "lockfile" {
+		lockfileCommand(os.Args[0], os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		verifyCommand(os.Args[0], os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		diffCommand(os.Args[0], os.Args[2:])
+		return
+	}
+	policyPath := flag.String("policy", "", "YAML or JSON policy to enforce on HTTP requests")
+	lockfilePath := flag.String("lockfile", "", "JSON lockfile of the expected dependencies to enforce")
+	signingKeyPath := flag.String("signing-key", "", "PEM private key to sign the provenance with")
+	keyID := flag.String("key-id", "", "ID of the signing key, recorded in the signature")
+	var outputs utils.StringsFlag
+	flag.Var(&outputs, "output", "glob of the output files to record as subjects, e.g. model/*.safetensors")
+	outputDir := flag.String("output-dir", "", "directory whose files written during the session are recorded as subjects")
+	notebookPath := flag.String("notebook", "", "notebook run in the session, recorded in the provenance")
+	flag.Usage = func() { usage(os.Args[0]) }
+	flag.Parse()
+	arguments := flag.Args()
 	if len(arguments) != 14 {
 		usage(os.Args[0])
 	}
@@ -129,6 +159,13 @@ func main() {
 	if err != nil {
 		logger.Fatalf("create repo client: %v", err)
 	}
+	var signer crypto.Signer
+	if *signingKeyPath != "" {
+		signer, err = utils.LoadSigner(*signingKeyPath)
+		if err != nil {
+			fatal(fmt.Errorf("load signing key: %w", err))
+		}
+	}
 	// Read CA
 	cert, err := os.Open(filepath.Join(certDir, "ca.cert"))
 	if err != nil {
@@ -138,14 +175,52 @@ func main() {
 	if err != nil {
 		fatal(fmt.Errorf("read key: %w", err))
 	}
-	// Create a new jnproxy.
-	proxy, err := jnproxy.New(*jserverConfig, *httpConfig,
-		repoClient, jnproxy.WithLogger(logger),
+	proxyOpts := []jnproxy.Option{
+		jnproxy.WithLogger(logger),
 		jnproxy.WithCA(jnproxy.CA{Certificate: cert, Key: key}),
+		jnproxy.InstallHuggingfaceUpload(),
 		jnproxy.InstallHuggingfaceModel(),
 		jnproxy.InstallHuggingfaceDataset(),
-		jnproxy.InstallAllowHandler(allow.WithConfig(&allowConfig{})))
-	//jnproxy.InstallDenyHandler())
+	}
+	if len(outputs) > 0 {
+		proxyOpts = append(proxyOpts, jnproxy.WithOutputs(outputs...))
+	}
+	if *outputDir != "" {
+		proxyOpts = append(proxyOpts, jnproxy.WithOutputDir(*outputDir))
+	}
+	if *notebookPath != "" {
+		proxyOpts = append(proxyOpts, jnproxy.WithNotebook(*notebookPath))
+	}
+	if *policyPath != "" {
+		// The policy decides what is allowed and recorded.
+		f, err := os.Open(*policyPath)
+		if err != nil {
+			fatal(fmt.Errorf("read policy: %w", err))
+		}
+		p, err := policy.Parse(f)
+		f.Close()
+		if err != nil {
+			fatal(fmt.Errorf("parse policy: %w", err))
+		}
+		proxyOpts = append(proxyOpts, jnproxy.InstallPolicy(p))
+	} else {
+		proxyOpts = append(proxyOpts, jnproxy.InstallAllowHandler(allow.WithConfig(&allowConfig{})))
+		//jnproxy.InstallDenyHandler())
+	}
+	if *lockfilePath != "" {
+		f, err := os.Open(*lockfilePath)
+		if err != nil {
+			fatal(fmt.Errorf("read lockfile: %w", err))
+		}
+		l, err := lockfile.Parse(f)
+		f.Close()
+		if err != nil {
+			fatal(fmt.Errorf("parse lockfile: %w", err))
+		}
+		proxyOpts = append(proxyOpts, jnproxy.InstallLockfile(l))
+	}
+	// Create a new jnproxy.
+	proxy, err := jnproxy.New(*jserverConfig, *httpConfig, repoClient, proxyOpts...)
 	if err != nil {
 		logger.Fatalf("create proxy: %v", err)
 	}
@@ -153,24 +228,18 @@ func main() {
 	if err := proxy.Start(); err != nil {
 		logger.Fatalf("start proxy: %v", err)
 	}
+	logger.Infof("session %s", proxy.SessionID())
 
 	// os.Kill?
-	c := make(chan os.Signal)
+	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
 	go func() {
 		<-c
 		if err := proxy.Stop(); err != nil {
 			logger.Fatalf("stop proxy: %v", err)
 		}
-		subjects := []slsa.Subject{
-			{
-				Name: "modelX",
-				DigestSet: slsa.DigestSet{
-					"sha256": "86cbdad53be99e43661bbbd2f22d95680334d92d579404a4747b1d15373da263",
-				},
-			},
-		}
-		prov, err := proxy.Provenance(slsa.Builder{ID: "https://colab.googleapis.com/ColabHostedKernel"}, subjects, "")
+		// Subjects are the outputs and the files uploaded to the Hub.
+		prov, err := proxy.Provenance(slsa.Builder{ID: "https://colab.googleapis.com/ColabHostedKernel"}, nil, "")
 		if err != nil {
 			logger.Fatalf("provenance: %v", err)
 		}
@@ -178,6 +247,43 @@ func main() {
 		if err := os.WriteFile(filepath.Join(repoDir, "prov.json"), prov, 0644); err != nil {
 			logger.Fatalf("write provenance: %v", err)
 		}
+		if signer != nil {
+			envelope, err := slsa.Sign(prov, signer, *keyID)
+			if err != nil {
+				logger.Fatalf("sign provenance: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(repoDir, "prov.dsse.json"), envelope, 0644); err != nil {
+				logger.Fatalf("write signed provenance: %v", err)
+			}
+		}
+		cdx, err := proxy.CycloneDX(nil)
+		if err != nil {
+			logger.Fatalf("cyclonedx: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(repoDir, "bom.cdx.json"), cdx, 0644); err != nil {
+			logger.Fatalf("write cyclonedx: %v", err)
+		}
+		spdx, err := proxy.SPDX(nil)
+		if err != nil {
+			logger.Fatalf("spdx: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(repoDir, "bom.spdx.json"), spdx, 0644); err != nil {
+			logger.Fatalf("write spdx: %v", err)
+		}
+		l, err := proxy.Lockfile()
+		if err != nil {
+			logger.Fatalf("lockfile: %v", err)
+		}
+		for _, uri := range l.Unpinned {
+			logger.Warnf("lockfile: uri (%q) observed with different digests is not pinned", uri)
+		}
+		lb, err := l.ToBytes()
+		if err != nil {
+			logger.Fatalf("lockfile: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(repoDir, "lockfile.json"), lb, 0644); err != nil {
+			logger.Fatalf("write lockfile: %v", err)
+		}
 		logger.Infof("Exiting...\n")
 		os.Exit(0)
 	}()
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/laurentsimon/jupyter-lineage/cli/proxy/internal/utils"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+	"github.com/laurentsimon/jupyter-lineage/pkg/verify"
+)
+
+// verifyCommand verifies a provenance or a DSSE envelope.
+func verifyCommand(prog string, arguments []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	keyPath := flags.String("key", "", "PEM public key the provenance must be signed with")
+	keyID := flags.String("key-id", "", "ID of the public key")
+	repoDir := flags.String("repository", "", "lineage repository directory to verify")
+	var cacheDirs utils.StringsFlag
+	flags.Var(&cacheDirs, "cache", "cache directory of dependency files to recheck, e.g. ~/.cache/huggingface/hub")
+	flags.Usage = func() {
+		utils.Log("Usage: %s verify [-key keyPath [-key-id keyID]] [-repository repoDir] [-cache cacheDir]... provenancePath\n", prog)
+		flags.PrintDefaults()
+		os.Exit(1)
+	}
+	flags.Parse(arguments)
+	if flags.NArg() != 1 {
+		flags.Usage()
+	}
+	content, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		fatal(fmt.Errorf("read provenance: %w", err))
+	}
+	var opts []verify.Option
+	if *keyPath != "" {
+		key, err := utils.LoadPublicKey(*keyPath)
+		if err != nil {
+			fatal(fmt.Errorf("load public key: %w", err))
+		}
+		opts = append(opts, verify.WithPublicKeys(slsa.PublicKey{KeyID: *keyID, Key: key}))
+	}
+	if *repoDir != "" {
+		opts = append(opts, verify.WithRepository(*repoDir))
+	}
+	if len(cacheDirs) > 0 {
+		opts = append(opts, verify.WithCacheDirs(cacheDirs...))
+	}
+	res, err := verify.Provenance(content, opts...)
+	if err != nil {
+		fatal(fmt.Errorf("verify: %w", err))
+	}
+	utils.Log("verified provenance (signed=%t)\n", res.Signed)
+	for _, fn := range res.Files {
+		utils.Log("verified file %q\n", fn)
+	}
+}
@@ -0,0 +1,304 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+// DigestChange is a dependency whose content changed.
+type DigestChange struct {
+	URI string         `json:"uri"`
+	Old slsa.DigestSet `json:"old"`
+	New slsa.DigestSet `json:"new"`
+}
+
+// CellChange is a cell whose source changed, or that was
+// executed in one session only.
+type CellChange struct {
+	// Position of the cell in the order of execution, from 1: in the new
+	// session, unless the cell was executed in the old session only.
+	Position int        `json:"position"`
+	Old      *slsa.Cell `json:"old,omitempty"`
+	New      *slsa.Cell `json:"new,omitempty"`
+}
+
+// FieldChange is a metadata field that changed, e.g. kernel.languageVersion.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Report lists the differences between two provenances,
+// from the old one to the new one.
+type Report struct {
+	Added    []slsa.ResourceDescriptor `json:"added,omitempty"`
+	Removed  []slsa.ResourceDescriptor `json:"removed,omitempty"`
+	Changed  []DigestChange            `json:"changed,omitempty"`
+	Cells    []CellChange              `json:"cells,omitempty"`
+	Metadata []FieldChange             `json:"metadata,omitempty"`
+}
+
+// Provenance compares two provenances generated by JNProxy.
+// The repository and checkpoint dependencies, which differ
+// for every session, are not compared.
+// NOTE: Signatures are not verified, see the verify package.
+func Provenance(oldContent, newContent []byte) (*Report, error) {
+	oldStatement, err := slsa.ParseProvenance(oldContent)
+	if err != nil {
+		return nil, fmt.Errorf("old provenance: %w", err)
+	}
+	newStatement, err := slsa.ParseProvenance(newContent)
+	if err != nil {
+		return nil, fmt.Errorf("new provenance: %w", err)
+	}
+	return Statements(oldStatement, newStatement), nil
+}
+
+// Statements compares two parsed provenances. See Provenance.
+func Statements(oldStatement, newStatement *slsa.Statement) *Report {
+	var r Report
+	r.compareDependencies(oldStatement.Predicate.BuildDefinition.ResolvedDependencies,
+		newStatement.Predicate.BuildDefinition.ResolvedDependencies)
+	r.compareCells(oldStatement.Predicate.BuildDefinition.ExternalParameters.Cells,
+		newStatement.Predicate.BuildDefinition.ExternalParameters.Cells)
+	r.compareMetadata(oldStatement, newStatement)
+	return &r
+}
+
+// IsEmpty returns true if the provenances do not differ.
+func (r *Report) IsEmpty() bool {
+	return len(r.Added) == 0 && len(r.Removed) == 0 && len(r.Changed) == 0 &&
+		len(r.Cells) == 0 && len(r.Metadata) == 0
+}
+
+// dependencies returns the descriptors of each uri, and the uris in order.
+// A uri fetched with different digests during a session, e.g. a mutable
+// URL, has one descriptor per digest.
+func dependencies(deps []slsa.ResourceDescriptor) (map[string][]slsa.ResourceDescriptor, []string) {
+	res := make(map[string][]slsa.ResourceDescriptor)
+	var uris []string
+	for _, rd := range deps {
+		if rd.URI == "" || rd.Name == slsa.RepositoryName || rd.Name == slsa.CheckpointName {
+			continue
+		}
+		if _, ok := res[rd.URI]; !ok {
+			uris = append(uris, rd.URI)
+		}
+		res[rd.URI] = append(res[rd.URI], rd)
+	}
+	sort.Strings(uris)
+	return res, uris
+}
+
+// unmatched returns the descriptors of a whose digest is in none of b.
+func unmatched(a, b []slsa.ResourceDescriptor) []slsa.ResourceDescriptor {
+	var res []slsa.ResourceDescriptor
+	for _, x := range a {
+		found := false
+		for _, y := range b {
+			if equalDigests(x.DigestSet, y.DigestSet) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			res = append(res, x)
+		}
+	}
+	return res
+}
+
+func equalDigests(a, b slsa.DigestSet) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for alg, v := range a {
+		if !strings.EqualFold(b[alg], v) {
+			return false
+		}
+	}
+	return true
+}
+
+// compareDependencies compares the digests of each uri. Digests found in
+// both sessions are unchanged, the others are paired in the order they were
+// recorded, and the digests left over are reported as added or removed.
+func (r *Report) compareDependencies(oldDeps, newDeps []slsa.ResourceDescriptor) {
+	oldByURI, oldURIs := dependencies(oldDeps)
+	newByURI, newURIs := dependencies(newDeps)
+	for _, uri := range oldURIs {
+		n, ok := newByURI[uri]
+		if !ok {
+			r.Removed = append(r.Removed, oldByURI[uri]...)
+			continue
+		}
+		olds := unmatched(oldByURI[uri], n)
+		news := unmatched(n, oldByURI[uri])
+		for i := 0; i < min(len(olds), len(news)); i++ {
+			r.Changed = append(r.Changed, DigestChange{URI: uri, Old: olds[i].DigestSet, New: news[i].DigestSet})
+		}
+		if len(olds) > len(news) {
+			r.Removed = append(r.Removed, olds[len(news):]...)
+		}
+		if len(news) > len(olds) {
+			r.Added = append(r.Added, news[len(olds):]...)
+		}
+	}
+	for _, uri := range newURIs {
+		if _, ok := oldByURI[uri]; !ok {
+			r.Added = append(r.Added, newByURI[uri]...)
+		}
+	}
+}
+
+// compareCells matches the cells of both sessions by digest, so that
+// a cell inserted or removed does not shift the cells after it.
+// The cells left unmatched are compared by position in the order
+// of execution.
+func (r *Report) compareCells(oldCells, newCells []slsa.Cell) {
+	// Indices of the old cells by digest, in the order of execution.
+	byDigest := make(map[string][]int)
+	for i := range oldCells {
+		k := digestString(oldCells[i].DigestSet)
+		byDigest[k] = append(byDigest[k], i)
+	}
+	oldMatched := make([]bool, len(oldCells))
+	newMatched := make([]bool, len(newCells))
+	for i := range newCells {
+		k := digestString(newCells[i].DigestSet)
+		if indices := byDigest[k]; len(indices) > 0 {
+			oldMatched[indices[0]] = true
+			newMatched[i] = true
+			byDigest[k] = indices[1:]
+		}
+	}
+	for i := 0; i < max(len(oldCells), len(newCells)); i++ {
+		c := CellChange{Position: i + 1}
+		if i < len(oldCells) && !oldMatched[i] {
+			c.Old = &oldCells[i]
+		}
+		if i < len(newCells) && !newMatched[i] {
+			c.New = &newCells[i]
+		}
+		if c.Old == nil && c.New == nil {
+			continue
+		}
+		r.Cells = append(r.Cells, c)
+	}
+}
+
+// fields returns the metadata of a statement to compare, by name.
+func fields(s *slsa.Statement) map[string]string {
+	res := map[string]string{
+		"buildType":       s.Predicate.BuildDefinition.BuildType,
+		"builder.id":      s.Predicate.RunDetails.Builder.ID,
+		"builder.version": s.Predicate.RunDetails.Builder.Version,
+	}
+	for _, rd := range s.Predicate.RunDetails.Builder.BuilderDependencies {
+		value := rd.URI
+		if d := digestString(rd.DigestSet); d != "" {
+			value = d
+		}
+		res["builder.dependencies."+rd.Name] = value
+	}
+	params := s.Predicate.BuildDefinition.ExternalParameters
+	if k := params.Kernel; k != nil {
+		res["kernel.implementation"] = k.Implementation
+		res["kernel.implementationVersion"] = k.ImplementationVersion
+		res["kernel.language"] = k.Language
+		res["kernel.languageVersion"] = k.LanguageVersion
+		res["kernel.protocolVersion"] = k.ProtocolVersion
+	}
+	if n := params.Notebook; n != nil {
+		res["notebook.path"] = n.Path
+		res["notebook.digest"] = digestString(n.DigestSet)
+	}
+	if p := s.Predicate.BuildDefinition.InternalParameters; p != nil {
+		res["handlers"] = strings.Join(p.Handlers, ",")
+		res["policy"] = digestString(p.Policy)
+		res["lockfile"] = digestString(p.Lockfile)
+		res["ca"] = digestString(p.CA)
+	}
+	return res
+}
+
+// digestString returns a stable representation of a digest set.
+func digestString(ds slsa.DigestSet) string {
+	algs := make([]string, 0, len(ds))
+	for alg := range ds {
+		algs = append(algs, alg)
+	}
+	sort.Strings(algs)
+	values := make([]string, 0, len(algs))
+	for _, alg := range algs {
+		values = append(values, alg+":"+strings.ToLower(ds[alg]))
+	}
+	return strings.Join(values, ",")
+}
+
+func (r *Report) compareMetadata(oldStatement, newStatement *slsa.Statement) {
+	oldFields := fields(oldStatement)
+	newFields := fields(newStatement)
+	names := make(map[string]bool)
+	for k := range oldFields {
+		names[k] = true
+	}
+	for k := range newFields {
+		names[k] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for k := range names {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	for _, k := range sorted {
+		if oldFields[k] != newFields[k] {
+			r.Metadata = append(r.Metadata, FieldChange{Field: k, Old: oldFields[k], New: newFields[k]})
+		}
+	}
+}
+
+// ToBytes returns the JSON representation of the report.
+func (r *Report) ToBytes() ([]byte, error) {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal: %w", err)
+	}
+	return b, nil
+}
+
+func cellString(c *slsa.Cell) string {
+	if c == nil {
+		return "none"
+	}
+	return fmt.Sprintf("[%d] %s", c.ExecutionCount, digestString(c.DigestSet))
+}
+
+// String returns a human-readable representation of the report.
+func (r *Report) String() string {
+	if r.IsEmpty() {
+		return "no differences\n"
+	}
+	var b strings.Builder
+	for _, rd := range r.Added {
+		fmt.Fprintf(&b, "+ dependency %s (%s)\n", rd.URI, digestString(rd.DigestSet))
+	}
+	for _, rd := range r.Removed {
+		fmt.Fprintf(&b, "- dependency %s (%s)\n", rd.URI, digestString(rd.DigestSet))
+	}
+	for _, c := range r.Changed {
+		fmt.Fprintf(&b, "~ dependency %s: %s -> %s\n", c.URI, digestString(c.Old), digestString(c.New))
+	}
+	for _, c := range r.Cells {
+		fmt.Fprintf(&b, "~ cell #%d: %s -> %s\n", c.Position, cellString(c.Old), cellString(c.New))
+	}
+	for _, c := range r.Metadata {
+		fmt.Fprintf(&b, "~ %s: %q -> %q\n", c.Field, c.Old, c.New)
+	}
+	return b.String()
+}
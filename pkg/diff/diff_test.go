@@ -0,0 +1,156 @@
+package diff
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+func statement(deps []slsa.ResourceDescriptor, cells []slsa.Cell, kernel *slsa.Kernel) *slsa.Statement {
+	return &slsa.Statement{
+		Type:          slsa.StatementType,
+		PredicateType: slsa.PredicateType,
+		Predicate: slsa.Predicate{
+			BuildDefinition: slsa.BuildDefinition{
+				BuildType:            slsa.BuildType,
+				ResolvedDependencies: deps,
+				ExternalParameters: slsa.ExternalParameters{
+					Kernel: kernel,
+					Cells:  cells,
+				},
+			},
+			RunDetails: slsa.RunDetails{
+				Builder: slsa.Builder{ID: "https://example.com/builder"},
+			},
+		},
+	}
+}
+
+func rd(uri, digest string) slsa.ResourceDescriptor {
+	return slsa.ResourceDescriptor{URI: uri, DigestSet: slsa.DigestSet{"sha256": digest}}
+}
+
+func cell(count int, digest string) slsa.Cell {
+	return slsa.Cell{ExecutionCount: count, DigestSet: slsa.DigestSet{"sha256": digest}}
+}
+
+func Test_Statements(t *testing.T) {
+	t.Parallel()
+	repo := func(commit string) slsa.ResourceDescriptor {
+		return slsa.ResourceDescriptor{Name: slsa.RepositoryName, DigestSet: slsa.DigestSet{"gitCommit": commit}}
+	}
+	kernel := &slsa.Kernel{Implementation: "ipython", Language: "python", LanguageVersion: "3.10.12"}
+	tests := []struct {
+		name     string
+		old      *slsa.Statement
+		new      *slsa.Statement
+		expected *Report
+	}{
+		{
+			name:     "same inputs",
+			old:      statement([]slsa.ResourceDescriptor{repo("aa"), rd("example.com/a", "aa")}, []slsa.Cell{cell(1, "aa")}, kernel),
+			new:      statement([]slsa.ResourceDescriptor{repo("bb"), rd("example.com/a", "AA")}, []slsa.Cell{cell(1, "aa")}, kernel),
+			expected: &Report{},
+		},
+		{
+			name: "dependencies",
+			old:  statement([]slsa.ResourceDescriptor{rd("example.com/a", "aa"), rd("example.com/b", "bb")}, nil, nil),
+			new:  statement([]slsa.ResourceDescriptor{rd("example.com/a", "a2"), rd("example.com/c", "cc")}, nil, nil),
+			expected: &Report{
+				Added:   []slsa.ResourceDescriptor{rd("example.com/c", "cc")},
+				Removed: []slsa.ResourceDescriptor{rd("example.com/b", "bb")},
+				Changed: []DigestChange{
+					{URI: "example.com/a", Old: slsa.DigestSet{"sha256": "aa"}, New: slsa.DigestSet{"sha256": "a2"}},
+				},
+			},
+		},
+		{
+			name: "cells",
+			old:  statement(nil, []slsa.Cell{cell(1, "aa"), cell(2, "bb")}, nil),
+			new:  statement(nil, []slsa.Cell{cell(1, "aa"), cell(2, "b2"), cell(3, "cc")}, nil),
+			expected: &Report{
+				Cells: []CellChange{
+					{Position: 2, Old: &slsa.Cell{ExecutionCount: 2, DigestSet: slsa.DigestSet{"sha256": "bb"}}, New: &slsa.Cell{ExecutionCount: 2, DigestSet: slsa.DigestSet{"sha256": "b2"}}},
+					{Position: 3, New: &slsa.Cell{ExecutionCount: 3, DigestSet: slsa.DigestSet{"sha256": "cc"}}},
+				},
+			},
+		},
+		{
+			name: "dependency with several digests",
+			old:  statement([]slsa.ResourceDescriptor{rd("example.com/a", "aa"), rd("example.com/a", "a2"), rd("example.com/b", "bb")}, nil, nil),
+			new:  statement([]slsa.ResourceDescriptor{rd("example.com/a", "A2"), rd("example.com/a", "a3"), rd("example.com/b", "bb"), rd("example.com/b", "b2")}, nil, nil),
+			expected: &Report{
+				Added: []slsa.ResourceDescriptor{rd("example.com/b", "b2")},
+				Changed: []DigestChange{
+					{URI: "example.com/a", Old: slsa.DigestSet{"sha256": "aa"}, New: slsa.DigestSet{"sha256": "a3"}},
+				},
+			},
+		},
+		{
+			name: "cell inserted",
+			old:  statement(nil, []slsa.Cell{cell(1, "aa"), cell(2, "bb"), cell(3, "cc")}, nil),
+			new:  statement(nil, []slsa.Cell{cell(1, "aa"), cell(2, "xx"), cell(3, "bb"), cell(4, "cc")}, nil),
+			expected: &Report{
+				Cells: []CellChange{
+					{Position: 2, New: &slsa.Cell{ExecutionCount: 2, DigestSet: slsa.DigestSet{"sha256": "xx"}}},
+				},
+			},
+		},
+		{
+			name: "cell removed and re-executed",
+			old:  statement(nil, []slsa.Cell{cell(1, "aa"), cell(2, "bb"), cell(3, "aa")}, nil),
+			new:  statement(nil, []slsa.Cell{cell(1, "aa"), cell(2, "aa")}, nil),
+			expected: &Report{
+				Cells: []CellChange{
+					{Position: 2, Old: &slsa.Cell{ExecutionCount: 2, DigestSet: slsa.DigestSet{"sha256": "bb"}}},
+				},
+			},
+		},
+		{
+			name: "kernel",
+			old:  statement(nil, nil, kernel),
+			new:  statement(nil, nil, &slsa.Kernel{Implementation: "ipython", Language: "python", LanguageVersion: "3.11.4"}),
+			expected: &Report{
+				Metadata: []FieldChange{
+					{Field: "kernel.languageVersion", Old: "3.10.12", New: "3.11.4"},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := Statements(tt.old, tt.new)
+			if diff := cmp.Diff(tt.expected, r, cmpopts.EquateEmpty()); diff != "" {
+				t.Fatalf("unexpected report (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Provenance(t *testing.T) {
+	t.Parallel()
+	b, err := json.Marshal(statement([]slsa.ResourceDescriptor{rd("example.com/a", "aa")}, nil, nil))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	r, err := Provenance(b, b)
+	if err != nil {
+		t.Fatalf("Provenance: %v", err)
+	}
+	if !r.IsEmpty() {
+		t.Fatalf("unexpected differences: %s", r)
+	}
+	if diff := cmp.Diff("no differences\n", r.String()); diff != "" {
+		t.Fatalf("unexpected string (-want +got): \n%s", diff)
+	}
+	_, err = Provenance(b, []byte(`{"_type":"other"}`))
+	if diff := cmp.Diff(errs.ErrorInvalid, err, cmpopts.EquateErrors()); diff != "" {
+		t.Fatalf("unexpected err (-want +got): \n%s", diff)
+	}
+}
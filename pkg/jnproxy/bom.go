@@ -0,0 +1,66 @@
+package jnproxy
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/cyclonedx"
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/spdx"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+// CycloneDX returns a CycloneDX 1.5 ML-BOM of the session, as an
+// alternative to Provenance. Its components are the runtime dependencies,
+// on which the subjects depend. Subjects are completed as in Provenance.
+func (s *JNProxy) CycloneDX(subjects []slsa.Subject) ([]byte, error) {
+	if s.state != stateFinished {
+		return nil, fmt.Errorf("%w: state %q", errs.ErrorInvalid, s.state)
+	}
+	subjects, deps, err := s.bomInputs(subjects)
+	if err != nil {
+		return nil, err
+	}
+	b, err := cyclonedx.New(subjects,
+		cyclonedx.WithSerialNumber(s.sessionID),
+		cyclonedx.WithTimestamp(time.Now()),
+		cyclonedx.AddDependencies(deps),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return b.ToBytes()
+}
+
+// SPDX returns an SPDX 3 document of the session with the AI and Dataset
+// profiles, as an alternative to Provenance. See CycloneDX.
+func (s *JNProxy) SPDX(subjects []slsa.Subject) ([]byte, error) {
+	if s.state != stateFinished {
+		return nil, fmt.Errorf("%w: state %q", errs.ErrorInvalid, s.state)
+	}
+	subjects, deps, err := s.bomInputs(subjects)
+	if err != nil {
+		return nil, err
+	}
+	d, err := spdx.New(subjects,
+		spdx.WithNamespace("urn:uuid:"+s.sessionID),
+		spdx.WithCreationTime(time.Now()),
+		spdx.AddDependencies(deps),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return d.ToBytes()
+}
+
+func (s *JNProxy) bomInputs(subjects []slsa.Subject) ([]slsa.Subject, []slsa.ResourceDescriptor, error) {
+	subjects, err := s.outputSubjects(subjects)
+	if err != nil {
+		return nil, nil, err
+	}
+	deps, err := s.runtimeDependencies()
+	if err != nil {
+		return nil, nil, err
+	}
+	return subjects, deps, nil
+}
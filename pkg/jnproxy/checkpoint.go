@@ -0,0 +1,96 @@
+package jnproxy
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
+	slsaimpl "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/slsa"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+// Checkpoint returns a provenance of the session so far, without stopping it,
+// e.g. for a model saved mid-session. It records the digest of the repository
+// as returned by repository.Client.Digest, which may commit the pending
+// changes to compute it, e.g. the git client of the CLI, and the
+// dependencies recorded so far. Each checkpoint, and the final
+// provenance, has the previous checkpoint as a resolved dependency named
+// slsa.CheckpointName, so that the attestations of a session form a chain.
+// Checkpoint may be called concurrently with the requests of the notebook,
+// but fails once Stop is called.
+func (s *JNProxy) Checkpoint(builder slsa.Builder, subjects []slsa.Subject, repoURI string) ([]byte, error) {
+	s.stateMu.Lock()
+	st := s.state
+	s.stateMu.Unlock()
+	if st != stateStarted {
+		return nil, fmt.Errorf("%w: state %q", errs.ErrorInvalid, st)
+	}
+	return s.attest(builder, subjects, repoURI)
+}
+
+// attest returns the provenance of the session so far,
+// and makes it the last checkpoint.
+func (s *JNProxy) attest(builder slsa.Builder, subjects []slsa.Subject, repoURI string) ([]byte, error) {
+	s.checkpointMu.Lock()
+	defer s.checkpointMu.Unlock()
+	s.repoMu.Lock()
+	digestSet, err := s.repoClient.Digest()
+	s.repoMu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("repository digest: %w", err)
+	}
+	repo := slsa.ResourceDescriptor{
+		Name:      slsa.RepositoryName,
+		DigestSet: digestSet,
+		URI:       repoURI,
+	}
+	// Get runtime dependencies.
+	deps, err := s.runtimeDependencies()
+	if err != nil {
+		return nil, err
+	}
+	if s.lastCheckpoint != nil {
+		deps = append([]slsa.ResourceDescriptor{*s.lastCheckpoint}, deps...)
+	}
+	byproducts, err := s.runtimeByproducts()
+	if err != nil {
+		return nil, err
+	}
+	subjects, err = s.outputSubjects(subjects)
+	if err != nil {
+		return nil, err
+	}
+	builder.BuilderDependencies = append(append([]slsa.ResourceDescriptor{}, builder.BuilderDependencies...),
+		s.builderDependencies...)
+
+	prov, err := slsaimpl.New(builder, subjects, repo,
+		slsaimpl.WithExternalParameters(s.externalParameters()),
+		slsaimpl.WithInternalParameters(s.internalParameters),
+		slsaimpl.WithInvocationID(s.sessionID),
+		slsaimpl.WithStartTime(s.startTime),
+		slsaimpl.WithFinishTime(time.Now()),
+		slsaimpl.AddDependencies(deps),
+		slsaimpl.AddByproducts(byproducts),
+	)
+	if err != nil {
+		return nil, err
+	}
+	content, err := prov.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+	digest, err := sha256Digest(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	s.checkpoints++
+	s.lastCheckpoint = &slsa.ResourceDescriptor{
+		Name:      slsa.CheckpointName,
+		DigestSet: digest,
+		Annotations: map[string]any{
+			"Sequence": s.checkpoints,
+		},
+	}
+	return content, nil
+}
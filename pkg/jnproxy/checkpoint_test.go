@@ -0,0 +1,180 @@
+package jnproxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
+	logimpl "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/logger"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+type repoClient struct {
+	commits int
+}
+
+func (c *repoClient) Init() error                                  { return nil }
+func (c *repoClient) CreateFile(path string, content []byte) error { return nil }
+func (c *repoClient) Close() error                                 { return nil }
+
+func (c *repoClient) Digest() (slsa.DigestSet, error) {
+	c.commits++
+	return slsa.DigestSet{"gitCommit": hex.EncodeToString([]byte{byte(c.commits)})}, nil
+}
+
+func Test_Checkpoint(t *testing.T) {
+	t.Parallel()
+	s := &JNProxy{
+		state:      stateStarted,
+		repoClient: &repoClient{},
+		logger:     logimpl.Logger{},
+	}
+	builder := slsa.Builder{ID: "https://example.com/builder"}
+	// checkpoints returns the checkpoint dependencies of a provenance.
+	checkpoints := func(content []byte) []slsa.ResourceDescriptor {
+		statement, err := slsa.ParseProvenance(content)
+		if err != nil {
+			t.Fatalf("ParseProvenance: %v", err)
+		}
+		var res []slsa.ResourceDescriptor
+		for _, rd := range statement.Predicate.BuildDefinition.ResolvedDependencies {
+			if rd.Name == slsa.CheckpointName {
+				res = append(res, rd)
+			}
+		}
+		return res
+	}
+	digest := func(content []byte) slsa.DigestSet {
+		h := sha256.Sum256(content)
+		return slsa.DigestSet{"sha256": hex.EncodeToString(h[:])}
+	}
+
+	first, err := s.Checkpoint(builder, nil, "")
+	if err != nil {
+		t.Fatalf("Checkpoint: %v", err)
+	}
+	if diff := cmp.Diff([]slsa.ResourceDescriptor(nil), checkpoints(first)); diff != "" {
+		t.Fatalf("unexpected checkpoints (-want +got): \n%s", diff)
+	}
+	second, err := s.Checkpoint(builder, nil, "")
+	if err != nil {
+		t.Fatalf("Checkpoint: %v", err)
+	}
+	expected := []slsa.ResourceDescriptor{
+		{
+			Name:        slsa.CheckpointName,
+			DigestSet:   digest(first),
+			Annotations: map[string]any{"Sequence": float64(1)},
+		},
+	}
+	if diff := cmp.Diff(expected, checkpoints(second)); diff != "" {
+		t.Fatalf("unexpected checkpoints (-want +got): \n%s", diff)
+	}
+
+	// The final provenance chains to the last checkpoint.
+	_, err = s.Provenance(builder, nil, "")
+	if diff := cmp.Diff(errs.ErrorInvalid, err, cmpopts.EquateErrors()); diff != "" {
+		t.Fatalf("unexpected err (-want +got): \n%s", diff)
+	}
+	if err := s.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	_, err = s.Checkpoint(builder, nil, "")
+	if diff := cmp.Diff(errs.ErrorInvalid, err, cmpopts.EquateErrors()); diff != "" {
+		t.Fatalf("unexpected err (-want +got): \n%s", diff)
+	}
+	prov, err := s.Provenance(builder, nil, "")
+	if err != nil {
+		t.Fatalf("Provenance: %v", err)
+	}
+	expected = []slsa.ResourceDescriptor{
+		{
+			Name:        slsa.CheckpointName,
+			DigestSet:   digest(second),
+			Annotations: map[string]any{"Sequence": float64(2)},
+		},
+	}
+	if diff := cmp.Diff(expected, checkpoints(prov)); diff != "" {
+		t.Fatalf("unexpected checkpoints (-want +got): \n%s", diff)
+	}
+}
+
+func Test_Checkpoint_stop(t *testing.T) {
+	t.Parallel()
+	s := &JNProxy{
+		state:      stateStarted,
+		repoClient: &repoClient{},
+		logger:     logimpl.Logger{},
+	}
+	builder := slsa.Builder{ID: "https://example.com/builder"}
+	// A checkpoint taken while the session stops either succeeds
+	// or sees the session finished.
+	done := make(chan error)
+	go func() {
+		_, err := s.Checkpoint(builder, nil, "")
+		done <- err
+	}()
+	if err := s.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if err := <-done; err != nil && !errors.Is(err, errs.ErrorInvalid) {
+		t.Fatalf("Checkpoint: %v", err)
+	}
+	_, err := s.Checkpoint(builder, nil, "")
+	if diff := cmp.Diff(errs.ErrorInvalid, err, cmpopts.EquateErrors()); diff != "" {
+		t.Fatalf("unexpected err (-want +got): \n%s", diff)
+	}
+}
+
+func Test_Checkpoint_outputs(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "model.safetensors")
+	s := &JNProxy{
+		state:          stateStarted,
+		repoClient:     &repoClient{},
+		logger:         logimpl.Logger{},
+		outputPatterns: []string{fn},
+	}
+	builder := slsa.Builder{ID: "https://example.com/builder"}
+	subjects := func(content []byte) []slsa.Subject {
+		statement, err := slsa.ParseProvenance(content)
+		if err != nil {
+			t.Fatalf("ParseProvenance: %v", err)
+		}
+		return statement.Subjects
+	}
+
+	// The notebook has not saved its model yet.
+	content, err := s.Checkpoint(builder, nil, "")
+	if err != nil {
+		t.Fatalf("Checkpoint: %v", err)
+	}
+	if diff := cmp.Diff([]slsa.Subject{}, subjects(content)); diff != "" {
+		t.Fatalf("unexpected subjects (-want +got): \n%s", diff)
+	}
+
+	if err := os.WriteFile(fn, []byte("weights"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	content, err = s.Checkpoint(builder, nil, "")
+	if err != nil {
+		t.Fatalf("Checkpoint: %v", err)
+	}
+	h := sha256.Sum256([]byte("weights"))
+	expected := []slsa.Subject{
+		{
+			Name:      filepath.ToSlash(fn),
+			DigestSet: slsa.DigestSet{"sha256": hex.EncodeToString(h[:])},
+		},
+	}
+	if diff := cmp.Diff(expected, subjects(content)); diff != "" {
+		t.Fatalf("unexpected subjects (-want +got): \n%s", diff)
+	}
+}
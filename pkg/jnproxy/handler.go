@@ -2,12 +2,25 @@ package jnproxy
 
 import (
 	"fmt"
+	"regexp"
 
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
 	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http"
 	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http/allow"
 	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http/deny"
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http/expression"
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http/huggingface"
 	hfdataset "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http/huggingface/dataset"
 	hfmodel "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http/huggingface/model"
+	hfupload "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http/huggingface/upload"
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http/kaggle"
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http/lock"
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http/objectstore"
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http/oci"
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http/policy"
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http/tfhub"
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http/torchhub"
+	"github.com/laurentsimon/jupyter-lineage/pkg/lockfile"
 )
 
 func InstallHandler(handler http.Handler) Option {
@@ -17,7 +30,31 @@ func InstallHandler(handler http.Handler) Option {
 }
 
 func (p *JNProxy) installHandler(handler http.Handler) error {
-	p.httpHandlers = append(p.httpHandlers, handler)
+	handlers := append(append([]http.Handler{}, p.httpHandlers...), handler)
+	if err := validateHandlers(handlers); err != nil {
+		return err
+	}
+	p.httpHandlers = handlers
+	return nil
+}
+
+// Handler names are versioned, e.g. HuggingfaceModel/v0.1.
+var handlerName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.-]*/v[0-9]+\.[0-9]+$`)
+
+// validateHandlers verifies that handlers have unique, versioned names,
+// since the name identifies the handler that recorded a dependency.
+func validateHandlers(handlers []http.Handler) error {
+	names := make(map[string]bool, len(handlers))
+	for _, h := range handlers {
+		name := h.Name()
+		if !handlerName.MatchString(name) {
+			return fmt.Errorf("%w: handler name (%q) not of the form Name/vX.Y", errs.ErrorInvalid, name)
+		}
+		if names[name] {
+			return fmt.Errorf("%w: duplicate handler name (%q)", errs.ErrorInvalid, name)
+		}
+		names[name] = true
+	}
 	return nil
 }
 
@@ -29,10 +66,32 @@ func InstallBuiltinHandlers() Option {
 
 func (p *JNProxy) installBuiltinHandlers() error {
 	p.httpHandlers = nil
+	// Huggingface upload handler. It must come before the model handler,
+	// which claims all requests to the Hub.
+	if err := p.installHuggingfaceUpload(); err != nil {
+		return err
+	}
 	// Huggingface model handler.
 	if err := p.installHuggingfaceModel(); err != nil {
 		return err
 	}
+	// Kaggle handler.
+	if err := p.installKaggle(); err != nil {
+		return err
+	}
+	// Tensorflow Hub handler.
+	if err := p.installTensorflowHub(); err != nil {
+		return err
+	}
+	// Pytorch Hub handler.
+	if err := p.installPytorchHub(); err != nil {
+		return err
+	}
+	// Object storage handler. It must come after the handlers above, since
+	// they claim some buckets.
+	if err := p.installObjectStorage(); err != nil {
+		return err
+	}
 	// Add handlers here.
 	return nil
 }
@@ -44,6 +103,15 @@ func RemoveBuiltinHandlers() Option {
 	}
 }
 
+// huggingfaceResolutions returns the LFS resolutions shared
+// by the Huggingface handlers of the proxy.
+func (p *JNProxy) huggingfaceResolutions() *huggingface.Resolutions {
+	if p.hfResolutions == nil {
+		p.hfResolutions = huggingface.NewResolutions()
+	}
+	return p.hfResolutions
+}
+
 func InstallHuggingfaceModel() Option {
 	return func(p *JNProxy) error {
 		return p.installHuggingfaceModel()
@@ -51,12 +119,28 @@ func InstallHuggingfaceModel() Option {
 }
 
 func (p *JNProxy) installHuggingfaceModel() error {
-	hf, err := hfmodel.New()
+	hf, err := hfmodel.New(hfmodel.WithResolutions(p.huggingfaceResolutions()))
 	if err != nil {
 		return fmt.Errorf("huggingface model new: %w", err)
 	}
-	p.httpHandlers = append(p.httpHandlers, hf)
-	return nil
+	return p.installHandler(hf)
+}
+
+// InstallHuggingfaceUpload records the files the notebook commits
+// to the Hugging Face Hub as subjects of the provenance.
+// It must be installed before the Huggingface model handler.
+func InstallHuggingfaceUpload() Option {
+	return func(p *JNProxy) error {
+		return p.installHuggingfaceUpload()
+	}
+}
+
+func (p *JNProxy) installHuggingfaceUpload() error {
+	hf, err := hfupload.New()
+	if err != nil {
+		return fmt.Errorf("huggingface upload new: %w", err)
+	}
+	return p.installHandler(hf)
 }
 
 func InstallHuggingfaceDataset() Option {
@@ -66,27 +150,99 @@ func InstallHuggingfaceDataset() Option {
 }
 
 func (p *JNProxy) installHuggingfaceDataset() error {
-	hf, err := hfdataset.New()
+	hf, err := hfdataset.New(hfdataset.WithResolutions(p.huggingfaceResolutions()))
 	if err != nil {
 		return fmt.Errorf("huggingface dataset new: %w", err)
 	}
-	p.httpHandlers = append(p.httpHandlers, hf)
-	return nil
+	return p.installHandler(hf)
+}
+
+func InstallKaggle() Option {
+	return func(p *JNProxy) error {
+		return p.installKaggle()
+	}
+}
+
+func (p *JNProxy) installKaggle() error {
+	k, err := kaggle.New()
+	if err != nil {
+		return fmt.Errorf("kaggle new: %w", err)
+	}
+	return p.installHandler(k)
+}
+
+func InstallTensorflowHub() Option {
+	return func(p *JNProxy) error {
+		return p.installTensorflowHub()
+	}
+}
+
+func (p *JNProxy) installTensorflowHub() error {
+	hub, err := tfhub.New()
+	if err != nil {
+		return fmt.Errorf("tensorflow hub new: %w", err)
+	}
+	return p.installHandler(hub)
+}
+
+func InstallPytorchHub() Option {
+	return func(p *JNProxy) error {
+		return p.installPytorchHub()
+	}
+}
+
+func (p *JNProxy) installPytorchHub() error {
+	hub, err := torchhub.New()
+	if err != nil {
+		return fmt.Errorf("pytorch hub new: %w", err)
+	}
+	return p.installHandler(hub)
+}
+
+func InstallObjectStorage() Option {
+	return func(p *JNProxy) error {
+		return p.installObjectStorage()
+	}
+}
+
+func (p *JNProxy) installObjectStorage() error {
+	storage, err := objectstore.New()
+	if err != nil {
+		return fmt.Errorf("object storage new: %w", err)
+	}
+	return p.installHandler(storage)
 }
 
-func InstallDenyHandler() Option {
+// InstallOCIRegistry records the manifests and blobs pulled from
+// container registries. It claims the registry API paths on any host,
+// so it must be installed after the handlers of specific hosts
+// that may serve such paths.
+func InstallOCIRegistry() Option {
 	return func(p *JNProxy) error {
-		return p.installDenyHandler()
+		return p.installOCIRegistry()
 	}
 }
 
-func (p *JNProxy) installDenyHandler() error {
-	denyHandler, err := deny.New()
+func (p *JNProxy) installOCIRegistry() error {
+	registry, err := oci.New()
+	if err != nil {
+		return fmt.Errorf("oci registry new: %w", err)
+	}
+	return p.installHandler(registry)
+}
+
+func InstallDenyHandler(opts ...deny.Option) Option {
+	return func(p *JNProxy) error {
+		return p.installDenyHandler(opts...)
+	}
+}
+
+func (p *JNProxy) installDenyHandler(opts ...deny.Option) error {
+	denyHandler, err := deny.New(opts...)
 	if err != nil {
 		return fmt.Errorf("deny new: %w", err)
 	}
-	p.httpHandlers = append(p.httpHandlers, denyHandler)
-	return nil
+	return p.installHandler(denyHandler)
 }
 
 func InstallAllowHandler(opts ...allow.Option) Option {
@@ -100,6 +256,90 @@ func (p *JNProxy) installAllowHandler(opts ...allow.Option) error {
 	if err != nil {
 		return fmt.Errorf("allow new: %w", err)
 	}
-	p.httpHandlers = append(p.httpHandlers, allowHandler)
+	return p.installHandler(allowHandler)
+}
+
+// InstallPolicy enforces the policy p on HTTP requests. The policy
+// is compiled into the handler chain: a policy handler is
+// installed first, and an allow handler last to record the requests
+// allowed and recorded that no other handler claims.
+func InstallPolicy(p *policy.Policy) Option {
+	return func(jp *JNProxy) error {
+		if p == nil {
+			return fmt.Errorf("%w: nil policy", errs.ErrorInvalid)
+		}
+		digest, err := p.Digest()
+		if err != nil {
+			return fmt.Errorf("policy digest: %w", err)
+		}
+		jp.policy = p
+		jp.internalParameters.Policy = digest
+		return nil
+	}
+}
+
+func (p *JNProxy) policyHandlers() ([]http.Handler, error) {
+	if p.policy == nil {
+		return p.httpHandlers, nil
+	}
+	gate, err := policy.New(p.policy)
+	if err != nil {
+		return nil, fmt.Errorf("policy new: %w", err)
+	}
+	recorder, err := allow.New(allow.WithName("PolicyRecorder/v0.1"))
+	if err != nil {
+		return nil, fmt.Errorf("allow new: %w", err)
+	}
+	handlers := []http.Handler{gate}
+	handlers = append(handlers, p.httpHandlers...)
+	handlers = append(handlers, recorder)
+	if err := validateHandlers(handlers); err != nil {
+		return nil, err
+	}
+	return handlers, nil
+}
+
+// InstallExpressionHandler installs rules written as expressions.
+// The handler is installed first so that it sees all requests.
+func InstallExpressionHandler(rules ...expression.Rule) Option {
+	return func(p *JNProxy) error {
+		return p.installExpressionHandler(rules...)
+	}
+}
+
+func (p *JNProxy) installExpressionHandler(rules ...expression.Rule) error {
+	h, err := expression.New(rules...)
+	if err != nil {
+		return fmt.Errorf("expression new: %w", err)
+	}
+	handlers := append([]http.Handler{h}, p.httpHandlers...)
+	if err := validateHandlers(handlers); err != nil {
+		return err
+	}
+	p.httpHandlers = handlers
+	return nil
+}
+
+// InstallLockfile refuses the requests whose URLs are not in the lockfile
+// and the downloads whose digests differ.
+func InstallLockfile(l *lockfile.Lockfile) Option {
+	return func(p *JNProxy) error {
+		return p.installLockfile(l)
+	}
+}
+
+func (p *JNProxy) installLockfile(l *lockfile.Lockfile) error {
+	h, err := lock.New(l)
+	if err != nil {
+		return fmt.Errorf("lockfile new: %w", err)
+	}
+	digest, err := l.Digest()
+	if err != nil {
+		return fmt.Errorf("lockfile digest: %w", err)
+	}
+	if err := p.installHandler(h); err != nil {
+		return err
+	}
+	p.internalParameters.Lockfile = digest
 	return nil
 }
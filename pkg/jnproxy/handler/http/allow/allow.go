@@ -30,7 +30,7 @@ type Handler struct {
 
 func New(options ...Option) (*Handler, error) {
 	self := &Handler{}
-	self.SetName("Handler/v0.1")
+	self.SetName("Allow/v0.1")
 	// Set optional parameters.
 	for _, option := range options {
 		err := option(self)
@@ -135,6 +135,7 @@ func (h *Handler) OnResponse(resp *http.Response, ctx handler.Context) (*http.Re
 		rd.Annotations["HTTPHeader"] = *headerRecord
 	}
 
+	rd.SetDependency(slsa.KindFile, slsa.EcosystemHTTP)
 	h.Store(ctx.ID, rd)
 	ctx.Logger.Debugf("[http]: RD %q", rd)
 	// TODO(#12): Overwrite the header with our own to prevent side channels like encoding
@@ -158,3 +159,13 @@ func WithConfig(c Config) Option {
 		return nil
 	}
 }
+
+// WithName overrides the name the dependencies recorded by the handler
+// are annotated with, e.g. Allow-Colab/v0.1, so that they can be told
+// apart from the dependencies recorded by dedicated handlers.
+func WithName(name string) Option {
+	return func(h *Handler) error {
+		h.SetName(name)
+		return nil
+	}
+}
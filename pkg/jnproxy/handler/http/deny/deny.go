@@ -1,41 +1,226 @@
 package deny
 
 import (
+	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
+	"strings"
 
 	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
 	handler "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http"
 	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
 )
 
-const name = "Handler/v0.1"
+const name = "Deny/v0.1"
 
+type Option func(*Handler) error
+
+// Handler refuses the requests that match its matchers,
+// and lets the others continue down the chain.
+// A request matches if its host matches any of the hosts, host suffixes
+// or CIDRs, and its path any of the path prefixes. Empty matchers match
+// every request, so a handler without matchers refuses every request.
 type Handler struct {
+	name         string
+	hosts        []string
+	hostSuffixes []string
+	cidrs        []*net.IPNet
+	pathPrefixes []string
+	message      string
 }
 
-func New() (*Handler, error) {
-	return &Handler{}, nil
+func New(options ...Option) (*Handler, error) {
+	self := &Handler{
+		name:    name,
+		message: "Forbidden",
+	}
+	// Set optional parameters.
+	for _, option := range options {
+		err := option(self)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return self, nil
 }
 
 func (h *Handler) Name() string {
-	return name
+	return h.name
+}
+
+// match returns the reason the request matches, if it does.
+func (h *Handler) match(req *http.Request) (string, bool) {
+	var reasons []string
+	host := handler.Hostname(req)
+	if len(h.hosts) > 0 || len(h.hostSuffixes) > 0 || len(h.cidrs) > 0 {
+		reason, ok := h.matchHost(host)
+		if !ok {
+			return "", false
+		}
+		reasons = append(reasons, reason)
+	}
+	if len(h.pathPrefixes) > 0 {
+		absPath, err := handler.AbsURLPath(req.URL.Path)
+		if err != nil {
+			// Fail closed.
+			return fmt.Sprintf("invalid path (%q)", req.URL.Path), true
+		}
+		reason, ok := h.matchPath(absPath)
+		if !ok {
+			return "", false
+		}
+		reasons = append(reasons, reason)
+	}
+	if len(reasons) == 0 {
+		return "deny all", true
+	}
+	return strings.Join(reasons, ", "), true
+}
+
+func (h *Handler) matchHost(host string) (string, bool) {
+	for _, v := range h.hosts {
+		if host == v {
+			return fmt.Sprintf("host (%q)", v), true
+		}
+	}
+	for _, v := range h.hostSuffixes {
+		if host == strings.TrimPrefix(v, ".") || strings.HasSuffix(host, "."+strings.TrimPrefix(v, ".")) {
+			return fmt.Sprintf("host suffix (%q)", v), true
+		}
+	}
+	// NOTE: Host names are not resolved, since the client
+	// and the upstream may not resolve them to the same address.
+	if ip := net.ParseIP(host); ip != nil {
+		for _, v := range h.cidrs {
+			if v.Contains(ip) {
+				return fmt.Sprintf("cidr (%q)", v.String()), true
+			}
+		}
+	}
+	return "", false
+}
+
+func (h *Handler) matchPath(absPath string) (string, bool) {
+	for _, v := range h.pathPrefixes {
+		if strings.HasPrefix(absPath, v) {
+			return fmt.Sprintf("path prefix (%q)", v), true
+		}
+	}
+	return "", false
+}
+
+// blocked is the structured event logged for denied requests.
+type blocked struct {
+	Event   string
+	Handler string
+	Method  string
+	URL     string
+	Reason  string
 }
 
 func (h *Handler) OnRequest(req *http.Request, ctx handler.Context) (*http.Request, *http.Response, bool, error) {
+	reason, ok := h.match(req)
+	if !ok {
+		return req, nil, false, nil
+	}
+	// NOTE: The event ends up in the notebook logs, so the query is left
+	// out of its URL, as it is in the provenance.
+	event, err := json.Marshal(blocked{
+		Event:   "blocked",
+		Handler: h.Name(),
+		Method:  req.Method,
+		URL:     req.Host + req.URL.Path,
+		Reason:  reason,
+	})
+	if err != nil {
+		return req, nil, false, fmt.Errorf("marshal: %w", err)
+	}
+	ctx.Logger.Infof("[http/%s] %s", h.Name(), event)
 	return req,
-		handler.NewResponse(req, handler.ContentTypeText, http.StatusForbidden, "Forbidden"),
+		handler.NewResponse(req, handler.ContentTypeText, http.StatusForbidden, h.message),
 		false, nil
 }
 
+// OnResponse does nothing: denied requests are answered in OnRequest,
+// and are never sent upstream.
 func (h *Handler) OnResponse(resp *http.Response, ctx handler.Context) (*http.Response, error) {
-	if resp.StatusCode != http.StatusForbidden {
-		return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, "InternServerError"),
-			fmt.Errorf("%w: received response (%q) not forbidden (%q)", errs.ErrorInvalid, ctx.Req.Host, resp.StatusCode)
-	}
 	return resp, nil
 }
 
 func (h *Handler) Dependencies(ctx handler.Context) ([]slsa.ResourceDescriptor, error) {
 	return nil, nil
 }
+
+// WithHosts denies requests to the hosts, e.g. example.com.
+func WithHosts(hosts ...string) Option {
+	return func(h *Handler) error {
+		for _, v := range hosts {
+			if v == "" {
+				return fmt.Errorf("%w: empty host", errs.ErrorInvalid)
+			}
+			h.hosts = append(h.hosts, strings.ToLower(v))
+		}
+		return nil
+	}
+}
+
+// WithHostSuffixes denies requests to the domains and their subdomains,
+// e.g. example.com matches example.com and www.example.com.
+func WithHostSuffixes(suffixes ...string) Option {
+	return func(h *Handler) error {
+		for _, v := range suffixes {
+			if strings.TrimPrefix(v, ".") == "" {
+				return fmt.Errorf("%w: empty host suffix", errs.ErrorInvalid)
+			}
+			h.hostSuffixes = append(h.hostSuffixes, strings.ToLower(v))
+		}
+		return nil
+	}
+}
+
+// WithCIDRs denies requests to IP addresses in the ranges, e.g. 10.0.0.0/8.
+func WithCIDRs(cidrs ...string) Option {
+	return func(h *Handler) error {
+		for _, v := range cidrs {
+			_, ipNet, err := net.ParseCIDR(v)
+			if err != nil {
+				return fmt.Errorf("%w: cidr (%q): %w", errs.ErrorInvalid, v, err)
+			}
+			h.cidrs = append(h.cidrs, ipNet)
+		}
+		return nil
+	}
+}
+
+// WithPathPrefixes denies requests whose path starts with the prefixes.
+func WithPathPrefixes(prefixes ...string) Option {
+	return func(h *Handler) error {
+		for _, v := range prefixes {
+			if !strings.HasPrefix(v, "/") {
+				return fmt.Errorf("%w: path prefix (%q) not absolute", errs.ErrorInvalid, v)
+			}
+			h.pathPrefixes = append(h.pathPrefixes, v)
+		}
+		return nil
+	}
+}
+
+// WithMessage sets the body of the responses to denied requests,
+// e.g. to explain the policy to the notebook user.
+func WithMessage(message string) Option {
+	return func(h *Handler) error {
+		h.message = message
+		return nil
+	}
+}
+
+// WithName overrides the name blocked requests are logged and recorded
+// under. Handler names are unique, so each deny handler installed
+// after the first needs its own name, e.g. Deny-Metadata/v0.1.
+func WithName(name string) Option {
+	return func(h *Handler) error {
+		h.name = name
+		return nil
+	}
+}
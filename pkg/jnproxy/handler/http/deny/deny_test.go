@@ -0,0 +1,128 @@
+package deny
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
+	handler "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http"
+	logimpl "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/logger"
+)
+
+func Test_OnRequest(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		options  []Option
+		url      string
+		denied   bool
+		expected error
+	}{
+		{
+			name:   "deny all",
+			url:    "https://example.com/a",
+			denied: true,
+		},
+		{
+			name:    "host",
+			options: []Option{WithHosts("Example.com")},
+			url:     "https://example.com:443/a",
+			denied:  true,
+		},
+		{
+			name:    "host not matching",
+			options: []Option{WithHosts("example.com")},
+			url:     "https://www.example.com/a",
+		},
+		{
+			name:    "host suffix",
+			options: []Option{WithHostSuffixes(".example.com")},
+			url:     "https://www.example.com/a",
+			denied:  true,
+		},
+		{
+			name:    "host suffix not matching",
+			options: []Option{WithHostSuffixes("example.com")},
+			url:     "https://badexample.com/a",
+		},
+		{
+			name:    "cidr",
+			options: []Option{WithCIDRs("10.0.0.0/8", "fd00::/8")},
+			url:     "http://10.1.2.3:8080/a",
+			denied:  true,
+		},
+		{
+			name:    "cidr ipv6",
+			options: []Option{WithCIDRs("10.0.0.0/8", "fd00::/8")},
+			url:     "http://[fd00::1]/a",
+			denied:  true,
+		},
+		{
+			name:    "cidr not matching",
+			options: []Option{WithCIDRs("10.0.0.0/8")},
+			url:     "http://example.com/a",
+		},
+		{
+			name:    "host and path prefix",
+			options: []Option{WithHosts("example.com"), WithPathPrefixes("/private/")},
+			url:     "https://example.com/private/../private/a",
+			denied:  true,
+		},
+		{
+			name:    "host and path prefix not matching",
+			options: []Option{WithHosts("example.com"), WithPathPrefixes("/private/")},
+			url:     "https://example.com/public/a",
+		},
+		{
+			name:     "invalid cidr",
+			options:  []Option{WithCIDRs("10.0.0.0")},
+			expected: errs.ErrorInvalid,
+		},
+		{
+			name:     "relative path prefix",
+			options:  []Option{WithPathPrefixes("private")},
+			expected: errs.ErrorInvalid,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			h, err := New(append(tt.options, WithMessage("denied by policy"))...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			req := httptest.NewRequest("GET", tt.url, nil)
+			_, resp, interested, err := h.OnRequest(req, handler.Context{Req: req, Logger: logimpl.Logger{}})
+			if err != nil {
+				t.Fatalf("OnRequest: %v", err)
+			}
+			if interested {
+				t.Fatalf("unexpected interest in request")
+			}
+			if diff := cmp.Diff(tt.denied, resp != nil); diff != "" {
+				t.Fatalf("unexpected denied (-want +got): \n%s", diff)
+			}
+			if resp == nil {
+				return
+			}
+			if diff := cmp.Diff(http.StatusForbidden, resp.StatusCode); diff != "" {
+				t.Fatalf("unexpected status (-want +got): \n%s", diff)
+			}
+			b, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("read body: %v", err)
+			}
+			if diff := cmp.Diff("denied by policy", string(b)); diff != "" {
+				t.Fatalf("unexpected body (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
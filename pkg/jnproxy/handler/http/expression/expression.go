@@ -0,0 +1,239 @@
+package expression
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
+	handler "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+// Variables available to expressions.
+const (
+	// Map with keys method, scheme, host, path, query and header.
+	// Query parameters and headers are maps of strings; multiple values
+	// are joined with ','. Header names are canonical, e.g. Content-Type.
+	varRequest = "request"
+	// Map with keys status, header and contentLength.
+	varResponse = "response"
+	// The JSON representation of the resource descriptor built
+	// by the handler of the response, e.g. resource.uri,
+	// resource.digest.sha256 or resource.annotations.Huggingface.Commit.
+	varResource = "resource"
+)
+
+// Rule rejects the requests for which its expression evaluates to true.
+// Expressions are written in CEL, see https://github.com/google/cel-spec.
+// E.g. to deny Hugging Face downloads whose revision is a branch or tag
+// rather than a commit:
+//
+//	has(resource.annotations.Huggingface) &&
+//	has(resource.annotations.Huggingface.Revision) &&
+//	!resource.annotations.Huggingface.Revision.matches('^[0-9a-f]{40}$')
+//
+// Or to keep responses over 10GB from the client unless the host is internal:
+//
+//	response.contentLength > 10000000000 && !request.host.endsWith('.internal')
+//
+// Rules that only use the request are evaluated before the request is sent.
+// Rules that use the response or the resource are evaluated on the response,
+// for each dependency recorded. Evaluation errors reject the request.
+// WARNING: Responses are evaluated after the handler has read the body,
+// so rules on the response do not limit what the proxy downloads
+// or holds in memory: they only keep the content from the client.
+type Rule struct {
+	Name       string
+	Expression string
+}
+
+type rule struct {
+	name       string
+	program    cel.Program
+	onResponse bool
+	onResource bool
+}
+
+type Handler struct {
+	handler.HandlerImpl
+	rules []rule
+}
+
+func New(rules ...Rule) (*Handler, error) {
+	env, err := cel.NewEnv(
+		cel.Variable(varRequest, cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable(varResponse, cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable(varResource, cel.MapType(cel.StringType, cel.DynType)),
+		cel.CrossTypeNumericComparisons(true),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("cel env: %w", err)
+	}
+	self := &Handler{}
+	self.SetName("Expression/v0.1")
+	names := make(map[string]bool)
+	for _, r := range rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("%w: rule (%q) has no name", errs.ErrorInvalid, r.Expression)
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("%w: duplicate rule (%q)", errs.ErrorInvalid, r.Name)
+		}
+		names[r.Name] = true
+		ast, iss := env.Compile(r.Expression)
+		if iss.Err() != nil {
+			return nil, fmt.Errorf("%w: rule (%q) compile: %w", errs.ErrorInvalid, r.Name, iss.Err())
+		}
+		if ast.OutputType() != cel.BoolType {
+			return nil, fmt.Errorf("%w: rule (%q) returns (%v), not bool", errs.ErrorInvalid, r.Name, ast.OutputType())
+		}
+		program, err := env.Program(ast)
+		if err != nil {
+			return nil, fmt.Errorf("%w: rule (%q) program: %w", errs.ErrorInvalid, r.Name, err)
+		}
+		compiled := rule{name: r.Name, program: program}
+		for _, ref := range ast.NativeRep().ReferenceMap() {
+			switch ref.Name {
+			case varResponse:
+				compiled.onResponse = true
+			case varResource:
+				compiled.onResource = true
+			}
+		}
+		self.rules = append(self.rules, compiled)
+	}
+	return self, nil
+}
+
+func joinValues(values map[string][]string) map[string]any {
+	res := make(map[string]any, len(values))
+	for k, v := range values {
+		res[k] = strings.Join(v, ",")
+	}
+	return res
+}
+
+func requestVar(req *http.Request) map[string]any {
+	return map[string]any{
+		"method": req.Method,
+		"scheme": req.URL.Scheme,
+		"host":   req.URL.Hostname(),
+		"path":   req.URL.Path,
+		"query":  joinValues(req.URL.Query()),
+		"header": joinValues(req.Header),
+	}
+}
+
+func responseVar(resp *http.Response) map[string]any {
+	return map[string]any{
+		"status":        resp.StatusCode,
+		"header":        joinValues(resp.Header),
+		"contentLength": resp.ContentLength,
+	}
+}
+
+// resourceVar returns the JSON representation of rd,
+// which is what users see in the provenance.
+func resourceVar(rd slsa.ResourceDescriptor) (map[string]any, error) {
+	b, err := json.Marshal(rd)
+	if err != nil {
+		return nil, fmt.Errorf("marshal: %w", err)
+	}
+	var res map[string]any
+	if err := json.Unmarshal(b, &res); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+	return res, nil
+}
+
+// eval returns true if the rule rejects the request.
+func (r *rule) eval(vars map[string]any) (bool, error) {
+	out, _, err := r.program.Eval(vars)
+	if err != nil {
+		return true, err
+	}
+	reject, ok := out.Value().(bool)
+	if !ok {
+		return true, fmt.Errorf("%w: result type (%T)", errs.ErrorInvalid, out.Value())
+	}
+	return reject, nil
+}
+
+func (h *Handler) forbidden(req *http.Request, name string, err error, ctx handler.Context) *http.Response {
+	msg := fmt.Sprintf("[http/%s] rule (%q) denied (%q)", h.Name(), name, req.Host+req.URL.Path)
+	if err != nil {
+		msg += fmt.Sprintf(": %v", err)
+	}
+	ctx.Logger.Infof(msg)
+	return handler.NewResponse(req, handler.ContentTypeText, http.StatusForbidden, msg)
+}
+
+// OnRequest rejects the requests denied by the rules that only use the request.
+// Other requests continue down the chain.
+func (h *Handler) OnRequest(req *http.Request, ctx handler.Context) (*http.Request, *http.Response, bool, error) {
+	vars := map[string]any{
+		varRequest: requestVar(req),
+	}
+	for i := range h.rules {
+		r := &h.rules[i]
+		if r.onResponse || r.onResource {
+			continue
+		}
+		reject, err := r.eval(vars)
+		if reject {
+			return req, h.forbidden(req, r.name, err, ctx), false, nil
+		}
+	}
+	return req, nil, false, nil
+}
+
+// OnResponse does nothing: rules on the response are evaluated
+// in FilterResponse, once the dependencies are identified.
+func (h *Handler) OnResponse(resp *http.Response, ctx handler.Context) (*http.Response, error) {
+	return resp, nil
+}
+
+// FilterResponse rejects the responses denied by the rules
+// that use the response or the resource.
+func (h *Handler) FilterResponse(resp *http.Response, deps []slsa.ResourceDescriptor, ctx handler.Context) (*http.Response, bool, error) {
+	vars := map[string]any{
+		varRequest:  requestVar(ctx.Req),
+		varResponse: responseVar(resp),
+	}
+	for i := range h.rules {
+		r := &h.rules[i]
+		switch {
+		case r.onResource:
+			// Evaluated for each resource below.
+			continue
+		case !r.onResponse:
+			// Evaluated by OnRequest.
+			continue
+		}
+		reject, err := r.eval(vars)
+		if reject {
+			return h.forbidden(ctx.Req, r.name, err, ctx), false, nil
+		}
+	}
+	for _, rd := range deps {
+		resource, err := resourceVar(rd)
+		if err != nil {
+			return nil, false, fmt.Errorf("resource (%q): %w", rd.URI, err)
+		}
+		vars[varResource] = resource
+		for i := range h.rules {
+			r := &h.rules[i]
+			if !r.onResource {
+				continue
+			}
+			reject, err := r.eval(vars)
+			if reject {
+				return h.forbidden(ctx.Req, r.name, err, ctx), false, nil
+			}
+		}
+	}
+	return nil, true, nil
+}
@@ -0,0 +1,175 @@
+package expression
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
+	handler "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http"
+	logimpl "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/logger"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		rules    []Rule
+		expected error
+	}{
+		{
+			name:  "valid",
+			rules: []Rule{{Name: "post", Expression: "request.method == 'POST'"}},
+		},
+		{
+			name:     "no name",
+			rules:    []Rule{{Expression: "true"}},
+			expected: errs.ErrorInvalid,
+		},
+		{
+			name:     "duplicate name",
+			rules:    []Rule{{Name: "a", Expression: "true"}, {Name: "a", Expression: "false"}},
+			expected: errs.ErrorInvalid,
+		},
+		{
+			name:     "syntax error",
+			rules:    []Rule{{Name: "a", Expression: "request.method =="}},
+			expected: errs.ErrorInvalid,
+		},
+		{
+			name:     "unknown variable",
+			rules:    []Rule{{Name: "a", Expression: "req.method == 'POST'"}},
+			expected: errs.ErrorInvalid,
+		},
+		{
+			name:     "not bool",
+			rules:    []Rule{{Name: "a", Expression: "request.method"}},
+			expected: errs.ErrorInvalid,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := New(tt.rules...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Handler(t *testing.T) {
+	t.Parallel()
+	h, err := New(
+		Rule{
+			Name:       "post",
+			Expression: "request.method == 'POST'",
+		},
+		Rule{
+			Name:       "large",
+			Expression: "response.contentLength > 10000000000 && !request.host.endsWith('.internal')",
+		},
+		Rule{
+			Name: "hf-revision",
+			Expression: "has(resource.annotations.Huggingface) && " +
+				"has(resource.annotations.Huggingface.Revision) && " +
+				"!resource.annotations.Huggingface.Revision.matches('^[0-9a-f]{40}$')",
+		},
+	)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	const commit = "55eb2010aeaaa246defc329d42939e0253d55c99"
+	huggingface := func(revision string) slsa.ResourceDescriptor {
+		return slsa.ResourceDescriptor{
+			URI: "hf://owner/name@" + revision + "/config.json",
+			Annotations: map[string]any{
+				// The commit is always resolved.
+				"Huggingface": map[string]any{
+					"Revision": revision,
+					"Commit":   commit,
+				},
+			},
+		}
+	}
+	tests := []struct {
+		name          string
+		method        string
+		url           string
+		contentLength int64
+		deps          []slsa.ResourceDescriptor
+		onRequest     string
+		onResponse    string
+	}{
+		{
+			name:      "post",
+			method:    "POST",
+			url:       "https://example.com/upload",
+			onRequest: "post",
+		},
+		{
+			name:          "large",
+			method:        "GET",
+			url:           "https://example.com:443/model.bin",
+			contentLength: 20000000000,
+			onResponse:    "large",
+		},
+		{
+			name:          "large internal",
+			method:        "GET",
+			url:           "https://models.internal/model.bin",
+			contentLength: 20000000000,
+		},
+		{
+			name:   "hf pinned revision",
+			method: "GET",
+			url:    "https://huggingface.co/owner/name/resolve/" + commit + "/config.json",
+			deps:   []slsa.ResourceDescriptor{huggingface(commit)},
+		},
+		{
+			name:       "hf branch revision",
+			method:     "GET",
+			url:        "https://huggingface.co/owner/name/resolve/main/config.json",
+			deps:       []slsa.ResourceDescriptor{{URI: "example.com/a"}, huggingface("main")},
+			onResponse: "hf-revision",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(tt.method, tt.url, nil)
+			ctx := handler.Context{Req: req, Logger: logimpl.Logger{}}
+			_, resp, interested, err := h.OnRequest(req, ctx)
+			if err != nil {
+				t.Fatalf("OnRequest: %v", err)
+			}
+			if interested {
+				t.Fatalf("unexpected interest in request")
+			}
+			if diff := cmp.Diff(tt.onRequest != "", resp != nil); diff != "" {
+				t.Fatalf("unexpected OnRequest rejection (-want +got): \n%s", diff)
+			}
+			if resp != nil {
+				if diff := cmp.Diff(http.StatusForbidden, resp.StatusCode); diff != "" {
+					t.Fatalf("unexpected status (-want +got): \n%s", diff)
+				}
+				return
+			}
+			resp, record, err := h.FilterResponse(&http.Response{StatusCode: http.StatusOK, ContentLength: tt.contentLength}, tt.deps, ctx)
+			if err != nil {
+				t.Fatalf("FilterResponse: %v", err)
+			}
+			if diff := cmp.Diff(tt.onResponse != "", resp != nil); diff != "" {
+				t.Fatalf("unexpected FilterResponse rejection (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.onResponse == "", record); diff != "" {
+				t.Fatalf("unexpected record (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
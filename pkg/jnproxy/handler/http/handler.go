@@ -17,6 +17,25 @@ type Context struct {
 	ID     int64         // Unique ID identifying the request <-> response
 	Req    *http.Request // Request that led to the callback.
 	Logger logger.Logger
+	// Redirects that led the client to Req, oldest first.
+	// Set in OnRequest and OnResponse.
+	Redirects []Redirect
+}
+
+// Redirect describes a response that redirected the client.
+type Redirect struct {
+	Req        *http.Request // Request that was redirected.
+	StatusCode int
+	Header     http.Header
+}
+
+// Origin returns the request the client originally made,
+// before following redirects.
+func (c *Context) Origin() *http.Request {
+	if len(c.Redirects) > 0 {
+		return c.Redirects[0].Req
+	}
+	return c.Req
 }
 
 type Handler interface {
@@ -29,16 +48,38 @@ type Handler interface {
 	// - bool indicates whether the handler want to receive
 	// the response via OnResponse().
 	OnRequest(req *http.Request, ctx Context) (*http.Request, *http.Response, bool, error)
-	// OnResponse is called when a server responds to a client request.
+	// OnResponse is called when a server responds to a client request
+	// the handler claimed in OnRequest, or that follows a redirect of one.
+	// Handlers that never claim requests, e.g. filters, are never called.
 	// ctx.Req point to the origial request
 	OnResponse(resp *http.Response, ctx Context) (*http.Response, error)
 	// Dependencies returns the results identified by the handler.
 	// On return, the function must erase the dependencies from its internal state.
-	// TODO(#13): structure to host other info, like the type of dependencies
-	// (sw package, model, dataset, etc)
+	// Dependencies should be annotated with their kind and ecosystem
+	// via slsa.ResourceDescriptor.SetDependency. Dependencies without them
+	// are recorded as files downloaded over HTTP.
 	Dependencies(ctx Context) ([]slsa.ResourceDescriptor, error)
 }
 
+// ResponseFilter is implemented by handlers that vet responses,
+// including the ones handled by other handlers.
+// FilterResponse is called for every response after the handler
+// that handles it, and sees the dependencies it identified.
+// - Response: The response to send the client instead. nil to accept the response.
+// - bool indicates whether the dependencies should be recorded.
+// Filters are called in the order handlers are installed.
+type ResponseFilter interface {
+	FilterResponse(resp *http.Response, deps []slsa.ResourceDescriptor, ctx Context) (*http.Response, bool, error)
+}
+
+// SubjectProvider is implemented by handlers that identify artifacts
+// the notebook publishes, e.g. uploads to a model hub. Subjects is called
+// after OnResponse and returns the subjects identified for the request ctx.ID.
+// On return, the function must erase them from its internal state.
+type SubjectProvider interface {
+	Subjects(ctx Context) ([]slsa.Subject, error)
+}
+
 func NewResponse(r *http.Request, contentType string, status int, body string) *http.Response {
 	resp := &http.Response{}
 	resp.Request = r
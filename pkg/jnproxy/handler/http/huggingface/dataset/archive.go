@@ -0,0 +1,208 @@
+package dataset
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+// member describes a file contained in an archive.
+type member struct {
+	Name      string         `json:"Name"`
+	Size      uint64         `json:"Size"`
+	DigestSet slsa.DigestSet `json:"Digest"`
+}
+
+// archiveFormat returns the archive format of a file, if any.
+// Buckets often serve archives as binary/octet-stream, so we fall back
+// to the file extension.
+func archiveFormat(contentType, filename string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/zip", "application/x-zip-compressed":
+		return "zip"
+	case "application/x-tar":
+		return "tar"
+	case "application/gzip", "application/x-gzip":
+		return "gzip"
+	case "binary/octet-stream", "application/octet-stream":
+		// E.g. parquet files.
+		// https://huggingface.co/docs/datasets-server/en/parquet
+		// https://huggingface.co/docs/datasets/en/index
+		switch {
+		case strings.HasSuffix(filename, ".zip"):
+			return "zip"
+		case strings.HasSuffix(filename, ".tar"):
+			return "tar"
+		case strings.HasSuffix(filename, ".gz") || strings.HasSuffix(filename, ".tgz"):
+			return "gzip"
+		}
+	}
+	return ""
+}
+
+// limits bounds the work done to hash the members of an archive,
+// so that archive bombs cannot exhaust the proxy.
+type limits struct {
+	// Total number of bytes decompressed.
+	Size int64
+	// Number of members.
+	Members int
+}
+
+var defaultLimits = limits{
+	Size:    4 << 30,
+	Members: 10000,
+}
+
+var errLimit = errors.New("limit exceeded")
+
+// budget is what remains of the limits while reading an archive.
+type budget struct {
+	limits
+	size    int64
+	members int
+}
+
+func newBudget(l limits) *budget {
+	return &budget{limits: l, size: l.Size, members: l.Members}
+}
+
+func (b *budget) member() error {
+	if b.members <= 0 {
+		return fmt.Errorf("%w: more than %d members", errLimit, b.limits.Members)
+	}
+	b.members--
+	return nil
+}
+
+// digest hashes r without buffering it, so that large
+// archives do not need to be decompressed in memory.
+func (b *budget) digest(r io.Reader) (slsa.DigestSet, uint64, error) {
+	hash := sha256.New()
+	n, err := io.Copy(hash, io.LimitReader(r, b.size+1))
+	if err != nil {
+		return nil, 0, err
+	}
+	if n > b.size {
+		return nil, 0, fmt.Errorf("%w: more than %d bytes", errLimit, b.limits.Size)
+	}
+	b.size -= n
+	return slsa.DigestSet{"sha256": fmt.Sprintf("%x", hash.Sum(nil))}, uint64(n), nil
+}
+
+// archiveMembers returns the members of the archive b of the given format,
+// as returned by archiveFormat.
+func archiveMembers(format string, b []byte, filename string, l limits) ([]member, error) {
+	switch format {
+	case "zip":
+		return zipMembers(b, newBudget(l))
+	case "tar":
+		return tarMembers(bytes.NewReader(b), newBudget(l))
+	case "gzip":
+		return gzipMembers(b, filename, newBudget(l))
+	}
+	return nil, fmt.Errorf("unknown archive format (%q)", format)
+}
+
+// https://pkg.go.dev/archive/zip#NewReader
+func zipMembers(b []byte, l *budget) ([]member, error) {
+	zipReader, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, fmt.Errorf("zip reader: %w", err)
+	}
+	var members []member
+	for _, f := range zipReader.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if err := l.member(); err != nil {
+			return nil, err
+		}
+		fileInArchive, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("zip open (%q): %w", f.Name, err)
+		}
+		digestSet, size, err := l.digest(fileInArchive)
+		fileInArchive.Close()
+		if err != nil {
+			return nil, fmt.Errorf("zip read (%q): %w", f.Name, err)
+		}
+		members = append(members, member{Name: f.Name, Size: size, DigestSet: digestSet})
+	}
+	return members, nil
+}
+
+// https://pkg.go.dev/archive/tar#Reader
+func tarMembers(r io.Reader, l *budget) ([]member, error) {
+	tarReader := tar.NewReader(r)
+	var members []member
+	for {
+		hdr, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("tar next: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := l.member(); err != nil {
+			return nil, err
+		}
+		digestSet, size, err := l.digest(tarReader)
+		if err != nil {
+			return nil, fmt.Errorf("tar read (%q): %w", hdr.Name, err)
+		}
+		members = append(members, member{Name: hdr.Name, Size: size, DigestSet: digestSet})
+	}
+	return members, nil
+}
+
+// gzipMembers returns the members of a gzipped tarball,
+// or the single decompressed file otherwise.
+// https://pkg.go.dev/compress/gzip#Reader.Read
+func gzipMembers(b []byte, filename string, l *budget) ([]member, error) {
+	gzipReader, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("gzip reader: %w", err)
+	}
+	defer gzipReader.Close()
+	reader := bufio.NewReader(gzipReader)
+	if isTar(reader) {
+		return tarMembers(reader, l)
+	}
+	name := gzipReader.Name
+	if name == "" {
+		name = strings.TrimSuffix(filename, ".gz")
+	}
+	if err := l.member(); err != nil {
+		return nil, err
+	}
+	digestSet, size, err := l.digest(reader)
+	if err != nil {
+		return nil, fmt.Errorf("gzip read: %w", err)
+	}
+	return []member{{Name: name, Size: size, DigestSet: digestSet}}, nil
+}
+
+// isTar looks for the ustar magic in the header of the first entry.
+// See https://www.gnu.org/software/tar/manual/html_node/Standard.html.
+func isTar(r *bufio.Reader) bool {
+	header, err := r.Peek(512)
+	if err != nil {
+		return false
+	}
+	return bytes.HasPrefix(header[257:], []byte("ustar"))
+}
@@ -0,0 +1,216 @@
+package dataset
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+const (
+	content1 = "content1"
+	content2 = "content2"
+	// echo -n content1 | sha256sum
+	digest1 = "d0b425e00e15a0d36b9b361f02bab63563aed6cb4665083905386c55d5b679fa"
+	// echo -n content2 | sha256sum
+	digest2 = "dab741b6289e7dccc1ed42330cae1accc2b755ce8079c2cd5d4b5366c9f769a6"
+)
+
+func zipArchive(t *testing.T) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, f := range []struct{ name, content string }{{"a.txt", content1}, {"dir/b.txt", content2}} {
+		fw, err := w.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write([]byte(f.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarArchive(t *testing.T) []byte {
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for _, f := range []struct{ name, content string }{{"a.txt", content1}, {"dir/b.txt", content2}} {
+		hdr := &tar.Header{Name: f.name, Mode: 0600, Size: int64(len(f.content)), Typeflag: tar.TypeReg}
+		if err := w.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(f.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func gzipFile(t *testing.T, name string, b []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Name = name
+	if _, err := w.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func Test_archiveMembers(t *testing.T) {
+	t.Parallel()
+	twoMembers := []member{
+		{Name: "a.txt", Size: uint64(len(content1)), DigestSet: slsa.DigestSet{"sha256": digest1}},
+		{Name: "dir/b.txt", Size: uint64(len(content2)), DigestSet: slsa.DigestSet{"sha256": digest2}},
+	}
+	tests := []struct {
+		name        string
+		contentType string
+		filename    string
+		archive     func(t *testing.T) []byte
+		limits      *limits
+		format      string
+		members     []member
+		expected    error
+	}{
+		{
+			name:        "zip",
+			contentType: "application/zip",
+			filename:    "data.zip",
+			archive:     zipArchive,
+			format:      "zip",
+			members:     twoMembers,
+		},
+		{
+			name:        "tar",
+			contentType: "application/x-tar",
+			filename:    "data.tar",
+			archive:     tarArchive,
+			format:      "tar",
+			members:     twoMembers,
+		},
+		{
+			name:        "gzipped tar",
+			contentType: "application/x-gzip",
+			filename:    "data.tar.gz",
+			archive: func(t *testing.T) []byte {
+				return gzipFile(t, "", tarArchive(t))
+			},
+			format:  "gzip",
+			members: twoMembers,
+		},
+		{
+			name:        "gzipped file",
+			contentType: "application/gzip",
+			filename:    "data.json.gz",
+			archive: func(t *testing.T) []byte {
+				return gzipFile(t, "", []byte(content1))
+			},
+			format: "gzip",
+			members: []member{
+				{Name: "data.json", Size: uint64(len(content1)), DigestSet: slsa.DigestSet{"sha256": digest1}},
+			},
+		},
+		{
+			name:        "gzipped file with name",
+			contentType: "application/gzip",
+			filename:    "data.json.gz",
+			archive: func(t *testing.T) []byte {
+				return gzipFile(t, "other.json", []byte(content2))
+			},
+			format: "gzip",
+			members: []member{
+				{Name: "other.json", Size: uint64(len(content2)), DigestSet: slsa.DigestSet{"sha256": digest2}},
+			},
+		},
+		{
+			name:        "zip served as octet-stream",
+			contentType: "binary/octet-stream",
+			filename:    "data.zip",
+			archive:     zipArchive,
+			format:      "zip",
+			members:     twoMembers,
+		},
+		{
+			name:        "too many members",
+			contentType: "application/zip",
+			filename:    "data.zip",
+			archive:     zipArchive,
+			limits:      &limits{Size: defaultLimits.Size, Members: 1},
+			format:      "zip",
+			expected:    errLimit,
+		},
+		{
+			name:        "too large",
+			contentType: "application/x-tar",
+			filename:    "data.tar",
+			archive:     tarArchive,
+			limits:      &limits{Size: int64(len(content1) + 1), Members: defaultLimits.Members},
+			format:      "tar",
+			expected:    errLimit,
+		},
+		{
+			name:        "gzip bomb",
+			contentType: "application/gzip",
+			filename:    "data.json.gz",
+			archive: func(t *testing.T) []byte {
+				return gzipFile(t, "", make([]byte, 1<<20))
+			},
+			limits:   &limits{Size: 1 << 10, Members: defaultLimits.Members},
+			format:   "gzip",
+			expected: errLimit,
+		},
+		{
+			name:        "not a zip",
+			contentType: "binary/octet-stream",
+			filename:    "data.zip",
+			archive: func(t *testing.T) []byte {
+				return []byte(content1)
+			},
+			format:   "zip",
+			expected: cmpopts.AnyError,
+		},
+		{
+			name:        "parquet",
+			contentType: "binary/octet-stream",
+			filename:    "data.parquet",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			format := archiveFormat(tt.contentType, tt.filename)
+			if diff := cmp.Diff(tt.format, format); diff != "" {
+				t.Fatalf("unexpected format (-want +got): \n%s", diff)
+			}
+			if format == "" {
+				return
+			}
+			l := defaultLimits
+			if tt.limits != nil {
+				l = *tt.limits
+			}
+			members, err := archiveMembers(format, tt.archive(t), tt.filename, l)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.members, members); diff != "" {
+				t.Fatalf("unexpected members (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
@@ -1,27 +1,52 @@
 package dataset
 
 import (
-	"archive/zip"
-	"bytes"
-	"compress/gzip"
-	"crypto/sha256"
 	"fmt"
-	"io/ioutil"
 	"net/http"
-	"strconv"
+	"path"
 	"strings"
 
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
 	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
 
 	handler "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http"
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http/huggingface"
 )
 
 type Dataset struct {
 	handler.HandlerImpl
+	resolutions *huggingface.Resolutions
+	limits      limits
 }
 
-func New() (*Dataset, error) {
-	self := &Dataset{}
+type Option func(*Dataset) error
+
+// WithResolutions hands the LFS files the handler resolves on the Hub
+// over to the model handler, which claims their download from CDN URLs
+// that do not mention datasets. Only cdn-lfs.huggingface.co/datasets/
+// downloads are claimed by this handler.
+func WithResolutions(resolutions *huggingface.Resolutions) Option {
+	return func(h *Dataset) error {
+		if resolutions == nil {
+			return fmt.Errorf("%w: nil resolutions", errs.ErrorInvalid)
+		}
+		h.resolutions = resolutions
+		return nil
+	}
+}
+
+func New(options ...Option) (*Dataset, error) {
+	self := &Dataset{
+		resolutions: huggingface.NewResolutions(),
+		limits:      defaultLimits,
+	}
+	// Set optional parameters.
+	for _, option := range options {
+		err := option(self)
+		if err != nil {
+			return nil, err
+		}
+	}
 	self.SetName("HuggingfaceDataset/v0.1")
 	return self, nil
 }
@@ -49,103 +74,76 @@ func (h *Dataset) OnRequest(req *http.Request, ctx handler.Context) (*http.Reque
 }
 
 func (h *Dataset) OnResponse(resp *http.Response, ctx handler.Context) (*http.Response, error) {
-	b, _ := ioutil.ReadAll(resp.Body)
-	//ctx.Logger.Debugf("[http]: received (%q):\nHeader:\n%q\nBody:\n%q", ctx.Req.Host, resp.Header, b)
-	ctx.Logger.Debugf("[http]: received (%q %q):\nHeader:\n%q", ctx.Req.Method, ctx.Req.Host+ctx.Req.URL.Path, resp.Header)
-	if ctx.Req.Method == "HEAD" {
-		return resp, nil
-	}
-	resp.Body.Close()
-	resp.Body = ioutil.NopCloser(bytes.NewBufferString(string(b)))
-
-	// Parse headers.
-	header := resp.Header
-	hLen, err := strconv.Atoi(header.Get("Content-Length"))
+	b, err := handler.ReadBody(resp)
 	if err != nil {
-		msg := fmt.Sprintf("[http/%s] conversion to int: %v", h.Name(), err)
+		msg := fmt.Sprintf("[http/%s] %v", h.Name(), err)
 		ctx.Logger.Errorf(msg)
 		return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
 	}
-	if hLen != len(b) {
-		msg := fmt.Sprintf("length mismatch. Header (%v) != actual (%v)", hLen, len(b))
+	ctx.Logger.Debugf("[http]: received (%q %q):\nHeader:\n%q", ctx.Req.Method, ctx.Req.Host+ctx.Req.URL.Path, resp.Header)
+
+	absPath, err := handler.AbsURLPath(ctx.Req.URL.Path)
+	if err != nil {
+		msg := fmt.Sprintf("[http/%s] %v", h.Name(), err)
 		ctx.Logger.Errorf(msg)
 		return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
 	}
-	contentType, ok := header["Content-Type"]
-	if !ok {
-		msg := "Content-Type is empty"
-		ctx.Logger.Errorf(msg)
-		return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
+	resource, ok := huggingface.ParseURL(ctx.Req.URL.Hostname(), absPath)
+	if ok && resource.Repo != "" {
+		resource.Commit = resp.Header.Get("X-Repo-Commit")
+		resource.LFSSHA256 = huggingface.LinkedEtag(resp.Header)
 	}
-
-	var rd slsa.ResourceDescriptor
-	switch contentType[0] {
-	case "todo/zip":
-		// Extract zipped files.
-		// https://pkg.go.dev/archive/zip#NewReader
-		reader := bytes.NewReader(b)
-		zipReader, err := zip.NewReader(reader, int64(len(b)))
-		if err != nil {
-			msg := fmt.Sprintf("zip reader: %v", err)
+	// HEAD requests and redirects to the CDN resolve the revision and the LFS file.
+	if ctx.Req.Method == "HEAD" || resp.StatusCode != http.StatusOK {
+		if ok && huggingface.Resolves(resp) {
+			h.resolutions.Resolved(*resource)
+		}
+		return resp, nil
+	}
+	if ok && resource.Repo == "" {
+		// LFS files are content-addressed.
+		if hh := handler.SHA256(b); hh != resource.LFSSHA256 {
+			msg := fmt.Sprintf("[http/%s] digest mismatch. Expected (%v) != actual (%v)", h.Name(), resource.LFSSHA256, hh)
 			ctx.Logger.Errorf(msg)
 			return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
 		}
-		for _, f := range zipReader.File {
-			// f.FileInfo().IsDir()
-			// fileInArchive, err := f.Open()
-			// io.Copy(dstFile, fileInArchive)
-			ctx.Logger.Debugf("unzipping file ", f.Name, f.CompressedSize, f.UncompressedSize64)
+		if r, found := h.resolutions.Resolution(resource.LFSSHA256); found {
+			resource = r
 		}
-	case "binary/octet-stream":
-		// https://huggingface.co/docs/datasets-server/en/parquet
-		// https://huggingface.co/docs/datasets/en/index
-	case "application/x-gzip":
-		// https://pkg.go.dev/compress/gzip#Reader.Read
-		reader := bytes.NewReader(b)
-		outputBytes := make([]byte, len(b))
-		gzipReader, err := gzip.NewReader(reader)
-		if err != nil {
-			msg := fmt.Sprintf("gzip reader: %v", err)
-			ctx.Logger.Errorf(msg)
-			return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
+	}
+
+	// Record the downloaded artifact, whatever its type.
+	rd, err := handler.NewResourceDescriptor(resp, b, ctx, h.Name())
+	if err != nil {
+		msg := fmt.Sprintf("[http/%s] %v", h.Name(), err)
+		ctx.Logger.Errorf(msg)
+		return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
+	}
+
+	// Record the members of archives. This is best-effort: the digest
+	// of the archive itself is recorded even if its members cannot be read,
+	// e.g. for a file sniffed as an archive by its extension only.
+	if format := archiveFormat(rd.MediaType, ctx.Req.URL.Path); format != "" {
+		archive := map[string]any{
+			"Format": format,
 		}
-		_, err = gzipReader.Read(outputBytes)
+		members, err := archiveMembers(format, b, path.Base(ctx.Req.URL.Path), h.limits)
 		if err != nil {
-			msg := fmt.Sprintf("gzip read: %v", err)
-			ctx.Logger.Errorf(msg)
-			return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
-		}
-	default:
-		hash := sha256.New()
-		hash.Write(b)
-		hh := fmt.Sprintf("%x", hash.Sum(nil))
-		aLen64 := uint64(len(b))
-		rd = slsa.ResourceDescriptor{
-			// WARNING: We're not recording GET parameters.
-			DownloadLocation: ctx.Req.URL.Host + ctx.Req.URL.Path,
-			URI:              ctx.Req.URL.Host + ctx.Req.URL.Path,
-			ContentLength:    &aLen64,
-			DigestSet: slsa.DigestSet{
-				"sha256": hh,
-			},
-			Annotations: map[string]any{
-				// NOTE: No header recorded.
-				"Handler": h.Name(),
-				"HTTP": map[string]any{
-					"Method": ctx.Req.Method,
-					"Header": map[string]any{
-						"Content-Length": hLen,
-						"Content-Type":   strings.Join(contentType, ";"),
-					},
-				},
-			},
+			ctx.Logger.Warnf("[http/%s] %s archive (%q): %v", h.Name(), format, ctx.Req.URL.Host+ctx.Req.URL.Path, err)
+			archive["Error"] = err.Error()
+		} else {
+			archive["Members"] = members
 		}
+		rd.Annotations["Archive"] = archive
 	}
 
-	xRepoCommit := header.Get("X-Repo-Commit")
-	if xRepoCommit != "" {
-		rd.DigestSet["hint:gitCommit"] = xRepoCommit
+	if ok && resource != nil {
+		if resource.Repo != "" {
+			rd.URI = resource.URI()
+		}
+		rd.Annotations["Huggingface"] = resource.Annotations()
 	}
+	rd.SetDependency(huggingface.Kind(resource, slsa.KindDataset), slsa.EcosystemHuggingface)
 	h.Store(ctx.ID, rd)
 	ctx.Logger.Debugf("[http]: RD %q", rd)
 	return resp, nil
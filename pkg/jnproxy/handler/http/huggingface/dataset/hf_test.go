@@ -0,0 +1,178 @@
+package dataset
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	handler "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http"
+	logimpl "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/logger"
+)
+
+func Test_OnResponse(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		url         string
+		contentType string
+		uri         string
+		huggingface map[string]any
+		archive     map[string]any
+	}{
+		{
+			name:        "hub",
+			url:         "https://huggingface.co/datasets/owner/name/resolve/main/data.json",
+			uri:         "hf://datasets/owner/name@main/data.json",
+			huggingface: map[string]any{"Type": "dataset", "Repo": "owner/name", "Revision": "main", "File": "data.json"},
+		},
+		{
+			name:        "not an archive",
+			url:         "https://huggingface.co/datasets/owner/name/resolve/main/data.zip",
+			contentType: "application/octet-stream",
+			uri:         "hf://datasets/owner/name@main/data.zip",
+			huggingface: map[string]any{"Type": "dataset", "Repo": "owner/name", "Revision": "main", "File": "data.zip"},
+			archive:     map[string]any{"Format": "zip", "Error": "zip reader: zip: not a valid zip file"},
+		},
+		{
+			name: "datasets server",
+			url:  "https://datasets-server.huggingface.co/parquet?dataset=owner/name",
+			uri:  "datasets-server.huggingface.co/parquet",
+		},
+		{
+			name: "s3 bucket",
+			url:  "https://s3.amazonaws.com/datasets.huggingface.co/name/data.json",
+			uri:  "s3.amazonaws.com/datasets.huggingface.co/name/data.json",
+		},
+		{
+			name: "gcs bucket",
+			url:  "https://storage.googleapis.com/huggingface-nlp/name/data.json",
+			uri:  "storage.googleapis.com/huggingface-nlp/name/data.json",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			h, err := New()
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			req := httptest.NewRequest("GET", tt.url, nil)
+			ctx := handler.Context{ID: 1, Req: req, Logger: logimpl.Logger{}}
+			if _, _, ok, err := h.OnRequest(req, ctx); err != nil || !ok {
+				t.Fatalf("OnRequest: %v %v", ok, err)
+			}
+			contentType := tt.contentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+			resp := handler.NewResponse(req, contentType, http.StatusOK, content1)
+			resp.Header.Set("Content-Length", strconv.Itoa(len(content1)))
+			r, err := h.OnResponse(resp, ctx)
+			if err != nil {
+				t.Fatalf("OnResponse: %v", err)
+			}
+			if r.StatusCode != http.StatusOK {
+				t.Fatalf("unexpected status: %d", r.StatusCode)
+			}
+			deps, err := h.Dependencies(ctx)
+			if err != nil {
+				t.Fatalf("Dependencies: %v", err)
+			}
+			if len(deps) != 1 {
+				t.Fatalf("unexpected dependencies: %v", deps)
+			}
+			if diff := cmp.Diff(tt.uri, deps[0].URI); diff != "" {
+				t.Fatalf("unexpected uri (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(digest1, deps[0].DigestSet["sha256"]); diff != "" {
+				t.Fatalf("unexpected digest (-want +got): \n%s", diff)
+			}
+			archive, _ := deps[0].Annotations["Archive"].(map[string]any)
+			if diff := cmp.Diff(tt.archive, archive); diff != "" {
+				t.Fatalf("unexpected archive (-want +got): \n%s", diff)
+			}
+			hf, _ := deps[0].Annotations["Huggingface"].(map[string]any)
+			if diff := cmp.Diff(tt.huggingface, hf); diff != "" {
+				t.Fatalf("unexpected annotations (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_OnResponse_lfs(t *testing.T) {
+	t.Parallel()
+	const commit = "55eb2010aeaaa246defc329d42939e0253d55c99"
+	cdn := "https://cdn-lfs.huggingface.co/datasets/owner/name/"
+	tests := []struct {
+		name          string
+		resolveStatus int
+		oid           string
+		status        int
+		uri           string
+	}{
+		{
+			name:          "resolved",
+			resolveStatus: http.StatusFound,
+			oid:           digest1,
+			status:        http.StatusOK,
+			uri:           "hf://datasets/owner/name@main/data.bin",
+		},
+		{
+			name:          "not found",
+			resolveStatus: http.StatusNotFound,
+			oid:           digest1,
+			status:        http.StatusOK,
+			uri:           "cdn-lfs.huggingface.co/datasets/owner/name/" + digest1,
+		},
+		{
+			name:          "digest mismatch",
+			resolveStatus: http.StatusFound,
+			oid:           strings.Repeat("ab", 32),
+			status:        http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			h, err := New()
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			req := httptest.NewRequest("GET", "https://huggingface.co/datasets/owner/name/resolve/main/data.bin", nil)
+			resp := handler.NewResponse(req, handler.ContentTypeText, tt.resolveStatus, "")
+			resp.Header.Set("X-Repo-Commit", commit)
+			resp.Header.Set("X-Linked-Etag", `"`+tt.oid+`"`)
+			if _, err := h.OnResponse(resp, handler.Context{ID: 1, Req: req, Logger: logimpl.Logger{}}); err != nil {
+				t.Fatalf("OnResponse: %v", err)
+			}
+			// The CDN response has no Content-Length, e.g. when chunked.
+			req = httptest.NewRequest("GET", cdn+tt.oid, nil)
+			ctx := handler.Context{ID: 2, Req: req, Logger: logimpl.Logger{}}
+			r, err := h.OnResponse(handler.NewResponse(req, "application/octet-stream", http.StatusOK, content1), ctx)
+			if err != nil {
+				t.Fatalf("OnResponse: %v", err)
+			}
+			if diff := cmp.Diff(tt.status, r.StatusCode); diff != "" {
+				t.Fatalf("unexpected status (-want +got): \n%s", diff)
+			}
+			if r.StatusCode != http.StatusOK {
+				return
+			}
+			deps, err := h.Dependencies(ctx)
+			if err != nil {
+				t.Fatalf("Dependencies: %v", err)
+			}
+			if len(deps) != 1 {
+				t.Fatalf("unexpected dependencies: %v", deps)
+			}
+			if diff := cmp.Diff(tt.uri, deps[0].URI); diff != "" {
+				t.Fatalf("unexpected uri (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
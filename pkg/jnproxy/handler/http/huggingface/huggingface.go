@@ -0,0 +1,333 @@
+package huggingface
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+// Repository types.
+// See https://huggingface.co/docs/huggingface_hub/guides/repository.
+const (
+	TypeModel   = "model"
+	TypeDataset = "dataset"
+	TypeSpace   = "space"
+)
+
+// Resource identifies a file in a Hugging Face Hub repository.
+type Resource struct {
+	Type string
+	// Repository ID, e.g. microsoft/trocr-small-handwritten.
+	Repo string
+	// Revision requested by the client, e.g. main.
+	Revision string
+	// Commit the revision resolved to.
+	Commit string
+	// Path of the file in the repository.
+	File string
+	// sha256 of files stored via LFS.
+	LFSSHA256 string
+}
+
+// IsHost returns true for the host of the Hub.
+func IsHost(host string) bool {
+	return host == "huggingface.co" || host == "hf.co"
+}
+
+// IsCDNHost returns true for the hosts LFS files are downloaded from.
+func IsCDNHost(host string) bool {
+	return host == "cdn-lfs.huggingface.co" || host == "cdn-lfs.hf.co" ||
+		(strings.HasPrefix(host, "cdn-lfs-") && (strings.HasSuffix(host, ".huggingface.co") || strings.HasSuffix(host, ".hf.co")))
+}
+
+// ParseURL parses the path of Hub URLs:
+// - /[{datasets,spaces}/]{repo}/resolve/{revision}/{file}
+// - /api/{models,datasets,spaces}/{repo}[/revision/{revision}]
+// - /api/{models,datasets,spaces}/{repo}/tree/{revision}[/{path}]
+// and of LFS CDN URLs, which end with the sha256 of the file.
+// WARNING: absPath must be absolute.
+func ParseURL(host, absPath string) (*Resource, bool) {
+	p := strings.TrimPrefix(absPath, "/")
+	switch {
+	case IsCDNHost(host):
+		oid := p[strings.LastIndex(p, "/")+1:]
+		if !isHex(oid, 64) {
+			return nil, false
+		}
+		return &Resource{LFSSHA256: oid}, true
+	case IsHost(host):
+		if rest, ok := strings.CutPrefix(p, "api/"); ok {
+			return parseAPI(rest)
+		}
+		return parseResolve(p)
+	}
+	return nil, false
+}
+
+func parseType(p string) (string, string) {
+	if rest, ok := strings.CutPrefix(p, "datasets/"); ok {
+		return TypeDataset, rest
+	}
+	if rest, ok := strings.CutPrefix(p, "spaces/"); ok {
+		return TypeSpace, rest
+	}
+	return TypeModel, p
+}
+
+// parseRevision splits {revision}/{path}. Revisions contain slashes
+// only for special refs, e.g. refs/pr/1 or refs/convert/parquet.
+func parseRevision(p string) (string, string) {
+	parts := strings.SplitN(p, "/", 4)
+	if parts[0] == "refs" && len(parts) >= 3 {
+		revision := strings.Join(parts[:3], "/")
+		return revision, strings.TrimPrefix(strings.TrimPrefix(p, revision), "/")
+	}
+	revision, path, _ := strings.Cut(p, "/")
+	return revision, path
+}
+
+func parseResolve(p string) (*Resource, bool) {
+	typ, p := parseType(p)
+	repo, rest, ok := strings.Cut(p, "/resolve/")
+	if !ok || repo == "" || strings.Count(repo, "/") > 1 {
+		return nil, false
+	}
+	revision, file := parseRevision(rest)
+	if revision == "" || file == "" {
+		return nil, false
+	}
+	return &Resource{Type: typ, Repo: repo, Revision: revision, File: file}, true
+}
+
+func parseAPI(p string) (*Resource, bool) {
+	var typ string
+	switch {
+	case strings.HasPrefix(p, "models/"):
+		typ = TypeModel
+	case strings.HasPrefix(p, "datasets/"):
+		typ = TypeDataset
+	case strings.HasPrefix(p, "spaces/"):
+		typ = TypeSpace
+	default:
+		return nil, false
+	}
+	_, p, _ = strings.Cut(p, "/")
+	r := Resource{Type: typ}
+	for _, sep := range []string{"/revision/", "/tree/"} {
+		if repo, rest, ok := strings.Cut(p, sep); ok {
+			r.Repo = repo
+			r.Revision, r.File = parseRevision(rest)
+			break
+		}
+	}
+	if r.Repo == "" {
+		r.Repo = p
+	}
+	if r.Repo == "" || strings.Count(r.Repo, "/") > 1 {
+		return nil, false
+	}
+	return &r, true
+}
+
+// URI returns the hf:// URI of the resource, as used by
+// https://huggingface.co/docs/huggingface_hub/guides/hf_file_system.
+// The revision requested by the client is used, so that the URI
+// is stable across sessions.
+func (r *Resource) URI() string {
+	uri := "hf://"
+	if r.Type != TypeModel {
+		uri += r.Type + "s/"
+	}
+	uri += r.Repo
+	if r.Revision != "" {
+		uri += "@" + url.PathEscape(r.Revision)
+	}
+	if r.File != "" {
+		uri += "/" + r.File
+	}
+	return uri
+}
+
+// PackageURL returns the purl of the resource pinned at its commit.
+// See https://github.com/package-url/purl-spec/blob/master/PURL-TYPES.rst#huggingface.
+// Only models have a purl type.
+func (r *Resource) PackageURL() string {
+	if r.Type != TypeModel || r.Commit == "" {
+		return ""
+	}
+	purl := "pkg:huggingface/" + r.Repo + "@" + r.Commit
+	if r.File != "" {
+		purl += "#" + r.File
+	}
+	return purl
+}
+
+// Annotations returns the fields of the resource
+// to record in a resource descriptor.
+func (r *Resource) Annotations() map[string]any {
+	annotations := map[string]any{}
+	for k, v := range map[string]string{
+		"Type":      r.Type,
+		"Repo":      r.Repo,
+		"Revision":  r.Revision,
+		"Commit":    r.Commit,
+		"File":      r.File,
+		"LFSSHA256": r.LFSSHA256,
+	} {
+		if v != "" {
+			annotations[k] = v
+		}
+	}
+	return annotations
+}
+
+// Kind returns the kind of dependency of the repository type of r,
+// or fallback if unknown, e.g. for LFS files not resolved.
+func Kind(r *Resource, fallback slsa.DependencyKind) slsa.DependencyKind {
+	if r == nil {
+		return fallback
+	}
+	switch r.Type {
+	case TypeModel:
+		return slsa.KindModel
+	case TypeDataset:
+		return slsa.KindDataset
+	case TypeSpace:
+		return slsa.KindCode
+	}
+	return fallback
+}
+
+// ParseURI parses hf://, pkg:huggingface and https://huggingface.co URIs.
+func ParseURI(uri string) (*Resource, error) {
+	switch {
+	case strings.HasPrefix(uri, "hf://"):
+		typ, p := parseType(strings.TrimPrefix(uri, "hf://"))
+		r := Resource{Type: typ}
+		// Repository IDs are {owner}/{name} or, for legacy models, {name}.
+		repo, rest, hasRevision := strings.Cut(p, "@")
+		if hasRevision {
+			revision, file, _ := strings.Cut(rest, "/")
+			r.Repo = repo
+			unescaped, err := url.PathUnescape(revision)
+			if err != nil {
+				return nil, fmt.Errorf("%w: revision (%q): %w", errs.ErrorInvalid, revision, err)
+			}
+			r.Revision, r.File = unescaped, file
+		} else {
+			parts := strings.SplitN(p, "/", 3)
+			r.Repo = strings.Join(parts[:min(2, len(parts))], "/")
+			if len(parts) == 3 {
+				r.File = parts[2]
+			}
+		}
+		if r.Repo == "" {
+			return nil, fmt.Errorf("%w: empty repository (%q)", errs.ErrorInvalid, uri)
+		}
+		return &r, nil
+	case strings.HasPrefix(uri, "pkg:huggingface/"):
+		p := strings.TrimPrefix(uri, "pkg:huggingface/")
+		p, file, _ := strings.Cut(p, "#")
+		p, _, _ = strings.Cut(p, "?")
+		repo, commit, _ := strings.Cut(p, "@")
+		if repo == "" {
+			return nil, fmt.Errorf("%w: empty repository (%q)", errs.ErrorInvalid, uri)
+		}
+		return &Resource{Type: TypeModel, Repo: repo, Commit: commit, File: file}, nil
+	case strings.HasPrefix(uri, "https://") || strings.HasPrefix(uri, "http://"):
+		u, err := url.Parse(uri)
+		if err != nil {
+			return nil, fmt.Errorf("%w: url parse (%q): %w", errs.ErrorInvalid, uri, err)
+		}
+		r, ok := ParseURL(u.Hostname(), u.Path)
+		if !ok {
+			return nil, fmt.Errorf("%w: url (%q)", errs.ErrorInvalid, uri)
+		}
+		return r, nil
+	}
+	return nil, fmt.Errorf("%w: uri scheme (%q)", errs.ErrorInvalid, uri)
+}
+
+// LinkedEtag returns the sha256 of LFS files from the headers of Hub responses.
+// See https://huggingface.co/docs/huggingface_hub/package_reference/file_download#huggingface_hub.get_hf_file_metadata.
+func LinkedEtag(header http.Header) string {
+	etag := header.Get("X-Linked-Etag")
+	etag = strings.TrimPrefix(etag, "W/")
+	etag = strings.Trim(etag, "\"")
+	if !isHex(etag, 64) {
+		// Not an LFS file: the etag is the git blob sha1.
+		return ""
+	}
+	return etag
+}
+
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// Resolves returns true for the Hub responses that resolve a file,
+// i.e. successful HEAD requests and redirects to the CDN.
+// Errors, e.g. for files that do not exist, resolve nothing.
+func Resolves(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusOK ||
+		(resp.StatusCode >= http.StatusMultipleChoices && resp.StatusCode < http.StatusBadRequest)
+}
+
+// IsCommit returns true if revision is a full commit hash.
+func IsCommit(revision string) bool {
+	return isHex(revision, 40)
+}
+
+// Resolutions maps LFS files to the resources that resolved to them.
+// Clients resolve LFS files via the Hub, which redirects them
+// to the CDN with the sha256 of the file in the X-Linked-Etag header.
+// The CDN URL does not identify the repository, so we remember
+// the resolutions to identify the download.
+// NOTE: The resolution and the download may be handled by different
+// handlers, which must then share the same Resolutions.
+type Resolutions struct {
+	mu        sync.Mutex
+	resources map[string]Resource
+}
+
+func NewResolutions() *Resolutions {
+	return &Resolutions{
+		resources: make(map[string]Resource),
+	}
+}
+
+// Resolved records that r resolved to the LFS file r.LFSSHA256.
+func (rs *Resolutions) Resolved(r Resource) {
+	if r.LFSSHA256 == "" {
+		return
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.resources[r.LFSSHA256] = r
+}
+
+// Resolution returns the resource that resolved to the LFS file oid,
+// and forgets it: each resolution is followed by a single download.
+func (rs *Resolutions) Resolution(oid string) (*Resource, bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	r, ok := rs.resources[oid]
+	if !ok {
+		return nil, false
+	}
+	delete(rs.resources, oid)
+	return &r, true
+}
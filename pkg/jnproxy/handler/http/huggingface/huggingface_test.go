@@ -0,0 +1,176 @@
+package huggingface
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
+)
+
+func Test_ParseURI(t *testing.T) {
+	t.Parallel()
+	const commit = "55eb2010aeaaa246defc329d42939e0253d55c99"
+	const oid = "9fa50ec37ab9f93c5f9d90e65827d3af0d5d40439fa50ec37ab9f93c5f9d90e6"
+	tests := []struct {
+		name     string
+		uri      string
+		resource *Resource
+		hfURI    string
+		purl     string
+		expected error
+	}{
+		{
+			name: "resolve url",
+			uri:  "https://huggingface.co/microsoft/trocr-small-handwritten/resolve/main/tokenizer_config.json",
+			resource: &Resource{
+				Type:     TypeModel,
+				Repo:     "microsoft/trocr-small-handwritten",
+				Revision: "main",
+				File:     "tokenizer_config.json",
+			},
+			hfURI: "hf://microsoft/trocr-small-handwritten@main/tokenizer_config.json",
+		},
+		{
+			name: "resolve url with port and legacy repo",
+			uri:  "https://huggingface.co:443/gpt2/resolve/" + commit + "/onnx/decoder_model.onnx",
+			resource: &Resource{
+				Type:     TypeModel,
+				Repo:     "gpt2",
+				Revision: commit,
+				File:     "onnx/decoder_model.onnx",
+			},
+			hfURI: "hf://gpt2@" + commit + "/onnx/decoder_model.onnx",
+		},
+		{
+			name: "dataset resolve url with pr ref",
+			uri:  "https://huggingface.co/datasets/owner/name/resolve/refs%2Fpr%2F1/data/train.parquet",
+			resource: &Resource{
+				Type:     TypeDataset,
+				Repo:     "owner/name",
+				Revision: "refs/pr/1",
+				File:     "data/train.parquet",
+			},
+			hfURI: "hf://datasets/owner/name@refs%2Fpr%2F1/data/train.parquet",
+		},
+		{
+			name: "api url",
+			uri:  "https://huggingface.co/api/models/owner/name/revision/main",
+			resource: &Resource{
+				Type:     TypeModel,
+				Repo:     "owner/name",
+				Revision: "main",
+			},
+			hfURI: "hf://owner/name@main",
+		},
+		{
+			name: "cdn url",
+			uri:  "https://cdn-lfs-us-1.huggingface.co/repos/96/8b/968b/" + oid + "?response-content-disposition=inline",
+			resource: &Resource{
+				LFSSHA256: oid,
+			},
+		},
+		{
+			name: "hf uri",
+			uri:  "hf://datasets/owner/name@refs%2Fpr%2F1/data/train.parquet",
+			resource: &Resource{
+				Type:     TypeDataset,
+				Repo:     "owner/name",
+				Revision: "refs/pr/1",
+				File:     "data/train.parquet",
+			},
+			hfURI: "hf://datasets/owner/name@refs%2Fpr%2F1/data/train.parquet",
+		},
+		{
+			name: "hf uri without revision",
+			uri:  "hf://owner/name/config.json",
+			resource: &Resource{
+				Type: TypeModel,
+				Repo: "owner/name",
+				File: "config.json",
+			},
+			hfURI: "hf://owner/name/config.json",
+		},
+		{
+			name: "purl",
+			uri:  "pkg:huggingface/microsoft/deberta-v3-base@" + commit + "#config.json",
+			resource: &Resource{
+				Type:   TypeModel,
+				Repo:   "microsoft/deberta-v3-base",
+				Commit: commit,
+				File:   "config.json",
+			},
+			hfURI: "hf://microsoft/deberta-v3-base/config.json",
+			purl:  "pkg:huggingface/microsoft/deberta-v3-base@" + commit + "#config.json",
+		},
+		{
+			name:     "not a hub url",
+			uri:      "https://huggingface.co/docs/hub/index",
+			expected: errs.ErrorInvalid,
+		},
+		{
+			name:     "unknown scheme",
+			uri:      "s3://bucket/key",
+			expected: errs.ErrorInvalid,
+		},
+		{
+			name:     "empty repository",
+			uri:      "pkg:huggingface/@" + commit,
+			expected: errs.ErrorInvalid,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r, err := ParseURI(tt.uri)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.resource, r); diff != "" {
+				t.Fatalf("unexpected resource (-want +got): \n%s", diff)
+			}
+			if r.Repo == "" {
+				return
+			}
+			if diff := cmp.Diff(tt.hfURI, r.URI()); diff != "" {
+				t.Fatalf("unexpected uri (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.purl, r.PackageURL()); diff != "" {
+				t.Fatalf("unexpected purl (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Resolutions(t *testing.T) {
+	t.Parallel()
+	const oid = "5ad53fbc2a5d7ec3fb6e3ac4dba1bd8ae8da2a2c6bd0a1b7c0b6f5f1a8f2d6aa"
+	resolutions := NewResolutions()
+	// Resources without LFS file are not recorded.
+	resolutions.Resolved(Resource{Type: TypeModel, Repo: "owner/name", Revision: "main", File: "config.json"})
+	if _, ok := resolutions.Resolution(""); ok {
+		t.Fatalf("unexpected resolution without LFS file")
+	}
+	expected := Resource{Type: TypeModel, Repo: "owner/name", Revision: "main", File: "model.bin", LFSSHA256: oid}
+	resolutions.Resolved(expected)
+	r, ok := resolutions.Resolution(oid)
+	if !ok {
+		t.Fatalf("resolution not found")
+	}
+	if diff := cmp.Diff(&expected, r); diff != "" {
+		t.Fatalf("unexpected resource (-want +got): \n%s", diff)
+	}
+	// Resolutions are used once.
+	if _, ok := resolutions.Resolution(oid); ok {
+		t.Fatalf("resolution not deleted")
+	}
+	// Resolutions are not shared across instances.
+	resolutions.Resolved(expected)
+	if _, ok := NewResolutions().Resolution(oid); ok {
+		t.Fatalf("resolution shared across instances")
+	}
+}
@@ -3,23 +3,52 @@ package model
 import (
 	"bytes"
 	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strconv"
 	"strings"
 
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
 	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
 
 	handler "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http"
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http/huggingface"
 )
 
 type Model struct {
 	handler.HandlerImpl
+	resolutions *huggingface.Resolutions
 }
 
-func New() (*Model, error) {
-	self := &Model{}
+type Option func(*Model) error
+
+// WithResolutions lets the handler identify the LFS files of datasets.
+// The dataset handler claims their resolution on the Hub, but this handler
+// claims their download from CDN URLs that do not mention datasets,
+// e.g. cdn-lfs-us-1.hf.co/repos/{xx}/{yy}/{sha256}.
+func WithResolutions(resolutions *huggingface.Resolutions) Option {
+	return func(h *Model) error {
+		if resolutions == nil {
+			return fmt.Errorf("%w: nil resolutions", errs.ErrorInvalid)
+		}
+		h.resolutions = resolutions
+		return nil
+	}
+}
+
+func New(options ...Option) (*Model, error) {
+	self := &Model{
+		resolutions: huggingface.NewResolutions(),
+	}
+	// Set optional parameters.
+	for _, option := range options {
+		err := option(self)
+		if err != nil {
+			return nil, err
+		}
+	}
 	self.SetName("HuggingfaceModel/v0.1")
 	return self, nil
 }
@@ -32,8 +61,8 @@ func (h *Model) OnRequest(req *http.Request, ctx handler.Context) (*http.Request
 		return req, handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), false, nil
 	}
 	// WARNING: absPath prefix must start and and with '/'.
-	interested := (req.Host == "huggingface.co" && !strings.Contains(absPath, "/datasets/")) ||
-		(req.Host == "cdn-lfs.huggingface.co" && !strings.Contains(absPath, "/datasets/"))
+	interested := (huggingface.IsHost(req.Host) && !strings.Contains(absPath, "/datasets/")) ||
+		(huggingface.IsCDNHost(req.Host) && !strings.Contains(absPath, "/datasets/"))
 	return req, nil, interested, nil
 }
 
@@ -41,9 +70,48 @@ func (h *Model) OnResponse(resp *http.Response, ctx handler.Context) (*http.Resp
 	b, _ := ioutil.ReadAll(resp.Body)
 	//ctx.Logger.Debugf("[http]: received (%q):\nHeader:\n%q\nBody:\n%q", ctx.Req.Host, resp.Header, b)
 	ctx.Logger.Debugf("[http]: received (%q %q):\nHeader:\n%q", ctx.Req.Method, ctx.Req.Host+ctx.Req.URL.Path, resp.Header)
-	if ctx.Req.Method == "HEAD" {
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewBufferString(string(b)))
+
+	absPath, err := handler.AbsURLPath(ctx.Req.URL.Path)
+	if err != nil {
+		msg := fmt.Sprintf("[http/%s] %v", h.Name(), err)
+		ctx.Logger.Errorf(msg)
+		return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
+	}
+	header := resp.Header
+	resource, ok := huggingface.ParseURL(ctx.Req.URL.Hostname(), absPath)
+	if ok && resource.Repo != "" {
+		resource.Commit = header.Get("X-Repo-Commit")
+		resource.LFSSHA256 = huggingface.LinkedEtag(header)
+	}
+	// HEAD requests and redirects to the CDN resolve the revision and the LFS file.
+	if ctx.Req.Method == "HEAD" || resp.StatusCode != http.StatusOK {
+		if ok && huggingface.Resolves(resp) {
+			h.resolutions.Resolved(*resource)
+		}
 		return resp, nil
 	}
+	if ok && resource.Repo == "" {
+		// LFS files are content-addressed.
+		if hh := handler.SHA256(b); hh != resource.LFSSHA256 {
+			msg := fmt.Sprintf("[http/%s] digest mismatch. Expected (%v) != actual (%v)", h.Name(), resource.LFSSHA256, hh)
+			ctx.Logger.Errorf(msg)
+			return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
+		}
+		if r, found := h.resolutions.Resolution(resource.LFSSHA256); found {
+			resource = r
+		}
+	}
+	if ok && resource.Commit == "" && strings.HasPrefix(absPath, "/api/") {
+		// The API returns the commit in the body.
+		var info struct {
+			SHA string `json:"sha"`
+		}
+		if err := json.Unmarshal(b, &info); err == nil {
+			resource.Commit = info.SHA
+		}
+	}
 	// TODO: handle error
 	// TODO: Dispatch here?
 	/*
@@ -76,11 +144,7 @@ func (h *Model) OnResponse(resp *http.Response, ctx handler.Context) (*http.Resp
 		"Content-Disposition":["inline; filename*=UTF-8''special_tokens_map.json; filename=\"special_tokens_map.json\";"] "Content-Length":["238"]
 		"Content-Type":["text/plain; charset=utf-8"]
 	*/
-	resp.Body.Close()
-	resp.Body = ioutil.NopCloser(bytes.NewBufferString(string(b)))
-
 	// Parse headers.
-	header := resp.Header
 	hLen, err := strconv.Atoi(header.Get("Content-Length"))
 	if err != nil {
 		msg := fmt.Sprintf("[http/%s] conversion to int: %v", h.Name(), err)
@@ -92,13 +156,12 @@ func (h *Model) OnResponse(resp *http.Response, ctx handler.Context) (*http.Resp
 		ctx.Logger.Errorf(msg)
 		return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
 	}
-	contentType, ok := header["Content-Type"]
-	if !ok {
+	contentType, ctOK := header["Content-Type"]
+	if !ctOK {
 		msg := "Content-Type is empty"
 		ctx.Logger.Errorf(msg)
 		return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
 	}
-	xRepoCommit := header.Get("X-Repo-Commit")
 	hash := sha256.New()
 	hash.Write(b)
 	hh := fmt.Sprintf("%x", hash.Sum(nil))
@@ -123,9 +186,14 @@ func (h *Model) OnResponse(resp *http.Response, ctx handler.Context) (*http.Resp
 			},
 		},
 	}
-	if xRepoCommit != "" {
-		rd.DigestSet["hint:gitCommit"] = xRepoCommit
+	if ok && resource != nil {
+		if resource.Repo != "" {
+			rd.URI = resource.URI()
+		}
+		rd.Name = resource.PackageURL()
+		rd.Annotations["Huggingface"] = resource.Annotations()
 	}
+	rd.SetDependency(huggingface.Kind(resource, slsa.KindModel), slsa.EcosystemHuggingface)
 	h.Store(ctx.ID, rd)
 	ctx.Logger.Debugf("[http]: RD %q", rd)
 	return resp, nil
@@ -0,0 +1,160 @@
+package model
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	handler "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http"
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http/huggingface"
+	logimpl "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/logger"
+)
+
+func Test_OnResponse(t *testing.T) {
+	t.Parallel()
+	const commit = "55eb2010aeaaa246defc329d42939e0253d55c99"
+	tests := []struct {
+		name        string
+		url         string
+		uri         string
+		purl        string
+		huggingface map[string]any
+	}{
+		{
+			name:        "resolve",
+			url:         "https://huggingface.co/owner/name/resolve/main/config.json",
+			uri:         "hf://owner/name@main/config.json",
+			purl:        "pkg:huggingface/owner/name@" + commit + "#config.json",
+			huggingface: map[string]any{"Type": "model", "Repo": "owner/name", "Revision": "main", "Commit": commit, "File": "config.json"},
+		},
+		{
+			name: "path not recognized",
+			url:  "https://huggingface.co/api/whoami-v2",
+			uri:  "huggingface.co/api/whoami-v2",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			h, err := New()
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			body := `{"name": "x"}`
+			req := httptest.NewRequest("GET", tt.url, nil)
+			resp := handler.NewResponse(req, "application/json", http.StatusOK, body)
+			resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+			resp.Header.Set("X-Repo-Commit", commit)
+			ctx := handler.Context{ID: 1, Req: req, Logger: logimpl.Logger{}}
+			r, err := h.OnResponse(resp, ctx)
+			if err != nil {
+				t.Fatalf("OnResponse: %v", err)
+			}
+			if r.StatusCode != http.StatusOK {
+				t.Fatalf("unexpected status: %d", r.StatusCode)
+			}
+			deps, err := h.Dependencies(ctx)
+			if err != nil {
+				t.Fatalf("Dependencies: %v", err)
+			}
+			if len(deps) != 1 {
+				t.Fatalf("unexpected dependencies: %v", deps)
+			}
+			if diff := cmp.Diff(tt.uri, deps[0].URI); diff != "" {
+				t.Fatalf("unexpected uri (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.purl, deps[0].Name); diff != "" {
+				t.Fatalf("unexpected name (-want +got): \n%s", diff)
+			}
+			hf, _ := deps[0].Annotations["Huggingface"].(map[string]any)
+			if diff := cmp.Diff(tt.huggingface, hf); diff != "" {
+				t.Fatalf("unexpected annotations (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_OnResponse_resolutions(t *testing.T) {
+	t.Parallel()
+	const content = "weights"
+	oid := handler.SHA256([]byte(content))
+	cdn := "https://cdn-lfs.huggingface.co/repos/aa/bb/" + oid
+	tests := []struct {
+		name          string
+		shared        bool
+		resolveStatus int
+		uris          []string
+	}{
+		{
+			name:          "shared",
+			shared:        true,
+			resolveStatus: http.StatusFound,
+			// The resolution is used by the first download only.
+			uris: []string{"hf://owner/name@main/model.bin", "cdn-lfs.huggingface.co/repos/aa/bb/" + oid},
+		},
+		{
+			name:          "not shared",
+			resolveStatus: http.StatusFound,
+			uris:          []string{"cdn-lfs.huggingface.co/repos/aa/bb/" + oid, "cdn-lfs.huggingface.co/repos/aa/bb/" + oid},
+		},
+		{
+			name:          "not found",
+			shared:        true,
+			resolveStatus: http.StatusNotFound,
+			uris:          []string{"cdn-lfs.huggingface.co/repos/aa/bb/" + oid},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var options []Option
+			if tt.shared {
+				options = append(options, WithResolutions(huggingface.NewResolutions()))
+			}
+			resolver, err := New(options...)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			downloader, err := New(options...)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			// The Hub redirects to the CDN.
+			req := httptest.NewRequest("GET", "https://huggingface.co/owner/name/resolve/main/model.bin", nil)
+			resp := handler.NewResponse(req, handler.ContentTypeText, tt.resolveStatus, "")
+			resp.Header.Set("X-Repo-Commit", "55eb2010aeaaa246defc329d42939e0253d55c99")
+			resp.Header.Set("X-Linked-Etag", `"`+oid+`"`)
+			ctx := handler.Context{ID: 1, Req: req, Logger: logimpl.Logger{}}
+			if _, err := resolver.OnResponse(resp, ctx); err != nil {
+				t.Fatalf("OnResponse: %v", err)
+			}
+			for i, uri := range tt.uris {
+				req := httptest.NewRequest("GET", cdn, nil)
+				resp := handler.NewResponse(req, "application/octet-stream", http.StatusOK, content)
+				resp.Header.Set("Content-Length", strconv.Itoa(len(content)))
+				ctx := handler.Context{ID: int64(i + 1), Req: req, Logger: logimpl.Logger{}}
+				r, err := downloader.OnResponse(resp, ctx)
+				if err != nil {
+					t.Fatalf("OnResponse: %v", err)
+				}
+				if r.StatusCode != http.StatusOK {
+					t.Fatalf("unexpected status: %d", r.StatusCode)
+				}
+				deps, err := downloader.Dependencies(ctx)
+				if err != nil {
+					t.Fatalf("Dependencies: %v", err)
+				}
+				if len(deps) != 1 {
+					t.Fatalf("unexpected dependencies: %v", deps)
+				}
+				if diff := cmp.Diff(uri, deps[0].URI); diff != "" {
+					t.Fatalf("unexpected uri (-want +got): \n%s", diff)
+				}
+			}
+		})
+	}
+}
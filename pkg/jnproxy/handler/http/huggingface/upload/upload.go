@@ -0,0 +1,283 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
+	handler "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http"
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http/huggingface"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+const name = "HuggingfaceUpload/v0.1"
+
+// Upload records the files the notebook commits to the Hub,
+// e.g. via huggingface_hub's upload_file, upload_folder or push_to_hub,
+// as subjects of the provenance.
+// See https://huggingface.co/docs/huggingface_hub/guides/upload.
+// Clients upload LFS files to storage before committing, and the commit
+// names them by their sha256. The Hub verifies that the objects exist
+// before accepting the commit, so the handler uses the commit payload
+// only, and does not inspect the uploads to storage.
+// It claims the other requests of the upload flow, i.e. preupload and
+// LFS batch requests, so that they are not recorded as downloads.
+// NOTE: It must be installed before the Huggingface model handler,
+// which claims all requests to the Hub.
+type Upload struct {
+	handler.HandlerImpl
+	commits  sync.Map // Files of the pending commits.
+	subjects sync.Map // Subjects of the accepted commits.
+}
+
+func New() (*Upload, error) {
+	self := &Upload{}
+	self.SetName(name)
+	return self, nil
+}
+
+// File is a file added or updated by a commit.
+type File struct {
+	Path   string
+	SHA256 string
+}
+
+// commit is a pending commit request.
+type commit struct {
+	resource huggingface.Resource
+	files    []File
+}
+
+// IsUpload returns true for the POST requests of the upload flow:
+// - /api/{models,datasets,spaces}/{repo}/{preupload,commit}/{revision}
+// - /[{datasets,spaces}/]{repo}.git/info/lfs/...
+// WARNING: absPath must be absolute.
+func IsUpload(method, host, absPath string) bool {
+	if method != http.MethodPost || !huggingface.IsHost(host) {
+		return false
+	}
+	if strings.Contains(absPath, ".git/info/lfs/") {
+		return true
+	}
+	_, ok := parseCommitURL(absPath)
+	return ok || (strings.HasPrefix(absPath, "/api/") && strings.Contains(absPath, "/preupload/"))
+}
+
+// parseCommitURL parses /api/{models,datasets,spaces}/{repo}/commit/{revision}.
+func parseCommitURL(absPath string) (*huggingface.Resource, bool) {
+	p, ok := strings.CutPrefix(absPath, "/api/")
+	if !ok {
+		return nil, false
+	}
+	var typ string
+	switch {
+	case strings.HasPrefix(p, "models/"):
+		typ = huggingface.TypeModel
+	case strings.HasPrefix(p, "datasets/"):
+		typ = huggingface.TypeDataset
+	case strings.HasPrefix(p, "spaces/"):
+		typ = huggingface.TypeSpace
+	default:
+		return nil, false
+	}
+	_, p, _ = strings.Cut(p, "/")
+	repo, revision, ok := strings.Cut(p, "/commit/")
+	if !ok || repo == "" || strings.Count(repo, "/") > 1 || revision == "" {
+		return nil, false
+	}
+	return &huggingface.Resource{Type: typ, Repo: repo, Revision: revision}, true
+}
+
+// commitEntry is an entry of the NDJSON payload of commits.
+// The legacy payload is a single object with the files and LFS files.
+// See https://github.com/huggingface/huggingface_hub/blob/main/src/huggingface_hub/_commit_api.py.
+type commitEntry struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+	// Legacy payload.
+	Files    []commitFile `json:"files"`
+	LFSFiles []commitFile `json:"lfsFiles"`
+}
+
+type commitFile struct {
+	Path     string `json:"path"`
+	Content  string `json:"content"`
+	Encoding string `json:"encoding"`
+	Algo     string `json:"algo"`
+	OID      string `json:"oid"`
+}
+
+func (f *commitFile) regular() (File, error) {
+	if f.Encoding != "base64" {
+		return File{}, fmt.Errorf("%w: file (%q) encoding (%q)", errs.ErrorInvalid, f.Path, f.Encoding)
+	}
+	b, err := base64.StdEncoding.DecodeString(f.Content)
+	if err != nil {
+		return File{}, fmt.Errorf("%w: file (%q) content: %w", errs.ErrorInvalid, f.Path, err)
+	}
+	return File{Path: f.Path, SHA256: handler.SHA256(b)}, nil
+}
+
+func (f *commitFile) lfs() (File, error) {
+	if f.Algo != "sha256" {
+		return File{}, fmt.Errorf("%w: lfs file (%q) algorithm (%q)", errs.ErrorInvalid, f.Path, f.Algo)
+	}
+	oid := strings.ToLower(f.OID)
+	if len(oid) != 64 || strings.Trim(oid, "0123456789abcdef") != "" {
+		return File{}, fmt.Errorf("%w: lfs file (%q) oid (%q)", errs.ErrorInvalid, f.Path, f.OID)
+	}
+	return File{Path: f.Path, SHA256: oid}, nil
+}
+
+// ParseCommit returns the files added or updated by the commit payload r.
+// Deleted files are ignored. Copied files are ignored too,
+// since the payload does not contain their digest.
+func ParseCommit(r io.Reader) ([]File, error) {
+	var files []File
+	add := func(f commitFile, lfs bool) error {
+		if f.Path == "" {
+			return fmt.Errorf("%w: empty file path", errs.ErrorInvalid)
+		}
+		var file File
+		var err error
+		if lfs {
+			file, err = f.lfs()
+		} else {
+			file, err = f.regular()
+		}
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+		return nil
+	}
+	decoder := json.NewDecoder(r)
+	for {
+		var e commitEntry
+		err := decoder.Decode(&e)
+		if errors.Is(err, io.EOF) {
+			return files, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: decode: %w", errs.ErrorInvalid, err)
+		}
+		for _, f := range e.Files {
+			if err := add(f, false); err != nil {
+				return nil, err
+			}
+		}
+		for _, f := range e.LFSFiles {
+			if err := add(f, true); err != nil {
+				return nil, err
+			}
+		}
+		if e.Key != "file" && e.Key != "lfsFile" {
+			continue
+		}
+		var f commitFile
+		if err := json.Unmarshal(e.Value, &f); err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", errs.ErrorInvalid, e.Key, err)
+		}
+		if err := add(f, e.Key == "lfsFile"); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Subjects returns the subjects for the files committed to r.
+// Subjects are named after the hf:// URI of the files pinned
+// at the commit, if known.
+func Subjects(r huggingface.Resource, files []File) []slsa.Subject {
+	if r.Commit != "" {
+		r.Revision = r.Commit
+	}
+	subjects := make([]slsa.Subject, 0, len(files))
+	for _, f := range files {
+		r.File = f.Path
+		subjects = append(subjects, slsa.Subject{
+			Name:      r.URI(),
+			DigestSet: slsa.DigestSet{"sha256": f.SHA256},
+		})
+	}
+	return subjects
+}
+
+func (h *Upload) error(req *http.Request, err error, ctx handler.Context) *http.Response {
+	msg := fmt.Sprintf("[http/%s] %v", h.Name(), err)
+	ctx.Logger.Errorf(msg)
+	return handler.NewResponse(req, handler.ContentTypeText, http.StatusInternalServerError, msg)
+}
+
+func (h *Upload) OnRequest(req *http.Request, ctx handler.Context) (*http.Request, *http.Response, bool, error) {
+	absPath, err := handler.AbsURLPath(req.URL.Path)
+	if err != nil {
+		return req, h.error(req, err, ctx), false, nil
+	}
+	host := req.URL.Hostname()
+	if host == "" {
+		host = req.Host
+	}
+	if !IsUpload(req.Method, host, absPath) {
+		return req, nil, false, nil
+	}
+	resource, ok := parseCommitURL(absPath)
+	if !ok {
+		return req, nil, true, nil
+	}
+	b, err := handler.ReadRequestBody(req)
+	if err != nil {
+		return req, h.error(req, err, ctx), false, nil
+	}
+	if req.Header.Get("Content-Encoding") != "" {
+		return req, h.error(req, fmt.Errorf("commit (%q) content encoding (%q) not supported", resource.URI(), req.Header.Get("Content-Encoding")), ctx), false, nil
+	}
+	files, err := ParseCommit(bytes.NewReader(b))
+	if err != nil {
+		// Fail closed, so that artifacts are not published without provenance.
+		return req, h.error(req, fmt.Errorf("commit (%q): %w", resource.URI(), err), ctx), false, nil
+	}
+	h.commits.Store(ctx.ID, commit{resource: *resource, files: files})
+	return req, nil, true, nil
+}
+
+func (h *Upload) OnResponse(resp *http.Response, ctx handler.Context) (*http.Response, error) {
+	v, ok := h.commits.LoadAndDelete(ctx.ID)
+	if !ok || resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+	c := v.(commit)
+	b, err := handler.ReadBody(resp)
+	if err != nil {
+		return h.error(ctx.Req, err, ctx), nil
+	}
+	var info struct {
+		CommitOID string `json:"commitOid"`
+	}
+	if err := json.Unmarshal(b, &info); err != nil {
+		ctx.Logger.Warnf("[http/%s] commit (%q) response: %v", h.Name(), c.resource.URI(), err)
+	}
+	if huggingface.IsCommit(info.CommitOID) {
+		c.resource.Commit = info.CommitOID
+	}
+	subjects := Subjects(c.resource, c.files)
+	ctx.Logger.Infof("[http/%s] commit (%q) uploaded %d files", h.Name(), c.resource.URI(), len(subjects))
+	h.subjects.Store(ctx.ID, subjects)
+	return resp, nil
+}
+
+// Subjects returns the files uploaded by the request ctx.ID,
+// and erases them from the internal state.
+func (h *Upload) Subjects(ctx handler.Context) ([]slsa.Subject, error) {
+	v, ok := h.subjects.LoadAndDelete(ctx.ID)
+	if !ok {
+		return nil, nil
+	}
+	return v.([]slsa.Subject), nil
+}
@@ -0,0 +1,258 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
+	handler "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http"
+	logimpl "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/logger"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+const (
+	commitOID = "55eb2010aeaaa246defc329d42939e0253d55c99"
+	oid       = "9fa50ec37ab9f93c5f9d90e65827d3af0d5d40439fa50ec37ab9f93c5f9d90e6"
+)
+
+func Test_ParseCommit(t *testing.T) {
+	t.Parallel()
+	content := base64.StdEncoding.EncodeToString([]byte(`{"model_type": "bert"}`))
+	tests := []struct {
+		name     string
+		payload  string
+		files    []File
+		expected error
+	}{
+		{
+			name: "ndjson",
+			payload: `{"key": "header", "value": {"summary": "Upload model", "description": ""}}
+{"key": "file", "value": {"content": "` + content + `", "path": "config.json", "encoding": "base64"}}
+{"key": "lfsFile", "value": {"path": "model.safetensors", "algo": "sha256", "oid": "` + oid + `"}}
+{"key": "deletedFile", "value": {"path": "old.bin"}}
+{"key": "copyFile", "value": {"path": "copy.bin", "srcPath": "model.bin"}}
+`,
+			files: []File{
+				{Path: "config.json", SHA256: handler.SHA256([]byte(`{"model_type": "bert"}`))},
+				{Path: "model.safetensors", SHA256: oid},
+			},
+		},
+		{
+			name: "legacy",
+			payload: `{"summary": "Upload model", "files": [{"path": "config.json", "content": "` + content + `", "encoding": "base64"}],` +
+				`"lfsFiles": [{"path": "model.safetensors", "algo": "sha256", "oid": "` + oid + `"}]}`,
+			files: []File{
+				{Path: "config.json", SHA256: handler.SHA256([]byte(`{"model_type": "bert"}`))},
+				{Path: "model.safetensors", SHA256: oid},
+			},
+		},
+		{
+			name:    "header only",
+			payload: `{"key": "header", "value": {"summary": "Delete file"}}`,
+		},
+		{
+			name:     "invalid oid",
+			payload:  `{"key": "lfsFile", "value": {"path": "model.safetensors", "algo": "sha256", "oid": "abc"}}`,
+			expected: errs.ErrorInvalid,
+		},
+		{
+			name:     "unsupported algorithm",
+			payload:  `{"key": "lfsFile", "value": {"path": "model.safetensors", "algo": "sha1", "oid": "` + oid + `"}}`,
+			expected: errs.ErrorInvalid,
+		},
+		{
+			name:     "unsupported encoding",
+			payload:  `{"key": "file", "value": {"content": "{}", "path": "config.json", "encoding": "utf-8"}}`,
+			expected: errs.ErrorInvalid,
+		},
+		{
+			name:     "empty path",
+			payload:  `{"key": "file", "value": {"content": "` + content + `", "encoding": "base64"}}`,
+			expected: errs.ErrorInvalid,
+		},
+		{
+			name:     "invalid json",
+			payload:  `{"key": "file"`,
+			expected: errs.ErrorInvalid,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			files, err := ParseCommit(strings.NewReader(tt.payload))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.files, files); diff != "" {
+				t.Fatalf("unexpected files (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_IsUpload(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		method   string
+		host     string
+		path     string
+		expected bool
+	}{
+		{
+			name:     "commit",
+			method:   "POST",
+			host:     "huggingface.co",
+			path:     "/api/models/owner/name/commit/main",
+			expected: true,
+		},
+		{
+			name:     "preupload",
+			method:   "POST",
+			host:     "huggingface.co",
+			path:     "/api/datasets/owner/name/preupload/main",
+			expected: true,
+		},
+		{
+			name:     "lfs batch",
+			method:   "POST",
+			host:     "huggingface.co",
+			path:     "/owner/name.git/info/lfs/objects/batch",
+			expected: true,
+		},
+		{
+			name:   "download",
+			method: "GET",
+			host:   "huggingface.co",
+			path:   "/owner/name/resolve/main/config.json",
+		},
+		{
+			name:   "commit with get",
+			method: "GET",
+			host:   "huggingface.co",
+			path:   "/api/models/owner/name/commit/main",
+		},
+		{
+			name:   "other host",
+			method: "POST",
+			host:   "example.com",
+			path:   "/api/models/owner/name/commit/main",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := IsUpload(tt.method, tt.host, tt.path); got != tt.expected {
+				t.Fatalf("unexpected IsUpload: %v", got)
+			}
+		})
+	}
+}
+
+func Test_Upload(t *testing.T) {
+	t.Parallel()
+	content := base64.StdEncoding.EncodeToString([]byte("hello"))
+	payload := `{"key": "header", "value": {"summary": "Upload"}}
+{"key": "file", "value": {"content": "` + content + `", "path": "README.md", "encoding": "base64"}}
+{"key": "lfsFile", "value": {"path": "model.safetensors", "algo": "sha256", "oid": "` + oid + `"}}
+`
+	tests := []struct {
+		name     string
+		path     string
+		status   int
+		response string
+		subjects []slsa.Subject
+	}{
+		{
+			name:     "model",
+			path:     "/api/models/owner/name/commit/main",
+			status:   http.StatusOK,
+			response: `{"commitUrl": "https://huggingface.co/owner/name/commit/` + commitOID + `", "commitOid": "` + commitOID + `"}`,
+			subjects: []slsa.Subject{
+				{Name: "hf://owner/name@" + commitOID + "/README.md", DigestSet: slsa.DigestSet{"sha256": handler.SHA256([]byte("hello"))}},
+				{Name: "hf://owner/name@" + commitOID + "/model.safetensors", DigestSet: slsa.DigestSet{"sha256": oid}},
+			},
+		},
+		{
+			name:     "dataset pr without commit",
+			path:     "/api/datasets/owner/name/commit/refs%2Fpr%2F1",
+			status:   http.StatusOK,
+			response: `{}`,
+			subjects: []slsa.Subject{
+				{Name: "hf://datasets/owner/name@refs%2Fpr%2F1/README.md", DigestSet: slsa.DigestSet{"sha256": handler.SHA256([]byte("hello"))}},
+				{Name: "hf://datasets/owner/name@refs%2Fpr%2F1/model.safetensors", DigestSet: slsa.DigestSet{"sha256": oid}},
+			},
+		},
+		{
+			name:     "rejected",
+			path:     "/api/models/owner/name/commit/main",
+			status:   http.StatusForbidden,
+			response: `{"error": "forbidden"}`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			h, err := New()
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			req := httptest.NewRequest("POST", "https://huggingface.co"+tt.path, strings.NewReader(payload))
+			ctx := handler.Context{ID: 1, Req: req, Logger: logimpl.Logger{}}
+			_, resp, ok, err := h.OnRequest(req, ctx)
+			if err != nil || resp != nil || !ok {
+				t.Fatalf("OnRequest: %v %v %v", resp, ok, err)
+			}
+			// The request can still be sent upstream.
+			b, err := io.ReadAll(req.Body)
+			if err != nil {
+				t.Fatalf("read body: %v", err)
+			}
+			if diff := cmp.Diff(payload, string(b)); diff != "" {
+				t.Fatalf("unexpected body (-want +got): \n%s", diff)
+			}
+			upstream := handler.NewResponse(req, "application/json", tt.status, tt.response)
+			if _, err := h.OnResponse(upstream, ctx); err != nil {
+				t.Fatalf("OnResponse: %v", err)
+			}
+			subjects, err := h.Subjects(ctx)
+			if err != nil {
+				t.Fatalf("Subjects: %v", err)
+			}
+			if diff := cmp.Diff(tt.subjects, subjects, cmpopts.EquateEmpty()); diff != "" {
+				t.Fatalf("unexpected subjects (-want +got): \n%s", diff)
+			}
+			// Subjects are erased.
+			subjects, err = h.Subjects(ctx)
+			if err != nil || len(subjects) != 0 {
+				t.Fatalf("Subjects not erased: %v %v", subjects, err)
+			}
+		})
+	}
+}
+
+func Test_Upload_invalid(t *testing.T) {
+	t.Parallel()
+	h, err := New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	req := httptest.NewRequest("POST", "https://huggingface.co/api/models/owner/name/commit/main", bytes.NewReader([]byte(`{"key": "file"`)))
+	_, resp, _, err := h.OnRequest(req, handler.Context{ID: 1, Req: req, Logger: logimpl.Logger{}})
+	if err != nil {
+		t.Fatalf("OnRequest: %v", err)
+	}
+	if resp == nil || resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("unexpected response: %v", resp)
+	}
+}
@@ -0,0 +1,180 @@
+package kaggle
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	handler "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+const (
+	kindModel   = "model"
+	kindDataset = "dataset"
+)
+
+type Kaggle struct {
+	handler.HandlerImpl
+}
+
+func New() (*Kaggle, error) {
+	self := &Kaggle{}
+	self.SetName("Kaggle/v0.1")
+	return self, nil
+}
+
+type resource struct {
+	kind    string
+	owner   string
+	slug    string
+	version string
+	file    string
+}
+
+func (r *resource) id() string {
+	id := r.owner + "/" + r.slug
+	if r.version != "" {
+		id += "/" + r.version
+	}
+	return id
+}
+
+func isAPIHost(host string) bool {
+	return host == "www.kaggle.com" || host == "kaggle.com"
+}
+
+// Downloads from the API are redirected to buckets with opaque numeric IDs.
+// The redirect is followed by the client, so we must claim the bucket downloads
+// as well.
+// WARNING: google bucket is not under kaggle.com so we need to match the path prefix.
+func isStorage(host, absPath string) bool {
+	return host == "storage.googleapis.com" &&
+		(strings.HasPrefix(absPath, "/kaggle-data-sets/") ||
+			strings.HasPrefix(absPath, "/kaggle-models-data/") ||
+			strings.HasPrefix(absPath, "/kaggle-competitions-data/"))
+}
+
+// parseAPIPath parses the download endpoints of the Kaggle API:
+// - /api/v1/datasets/download/{owner}/{slug}[/{file}]?datasetVersionNumber={version}
+// - /api/v1/models/{owner}/{model}/{framework}/{variation}/{version}/download[/{file}]
+// - /api/v1/competitions/data/download/{competition}/{file}
+// - /api/v1/competitions/data/download-all/{competition}
+// See https://github.com/Kaggle/kaggle-api and https://github.com/Kaggle/kagglehub.
+func parseAPIPath(absPath, datasetVersion string) (*resource, bool) {
+	rest, ok := strings.CutPrefix(absPath, "/api/v1/")
+	if !ok {
+		return nil, false
+	}
+	parts := strings.Split(rest, "/")
+	switch {
+	case len(parts) >= 4 && parts[0] == "datasets" && parts[1] == "download":
+		return &resource{
+			kind:    kindDataset,
+			owner:   parts[2],
+			slug:    parts[3],
+			version: datasetVersion,
+			file:    strings.Join(parts[4:], "/"),
+		}, true
+	case len(parts) >= 7 && parts[0] == "models" && parts[6] == "download":
+		return &resource{
+			kind:  kindModel,
+			owner: parts[1],
+			// Model handles are {model}/{framework}/{variation}.
+			slug:    strings.Join(parts[2:5], "/"),
+			version: parts[5],
+			file:    strings.Join(parts[7:], "/"),
+		}, true
+	case len(parts) >= 4 && parts[0] == "competitions" && parts[1] == "data" &&
+		(parts[2] == "download" || parts[2] == "download-all"):
+		return &resource{
+			kind:  kindDataset,
+			owner: "competitions",
+			slug:  parts[3],
+			file:  strings.Join(parts[4:], "/"),
+		}, true
+	}
+	return nil, false
+}
+
+func (h *Kaggle) OnRequest(req *http.Request, ctx handler.Context) (*http.Request, *http.Response, bool, error) {
+	absPath, err := handler.AbsURLPath(req.URL.Path)
+	if err != nil {
+		msg := fmt.Sprintf("[http/%s] %v", h.Name(), err)
+		ctx.Logger.Errorf(msg)
+		return req, handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), false, nil
+	}
+	_, api := parseAPIPath(absPath, "")
+	interested := (isAPIHost(req.Host) && api) || isStorage(req.Host, absPath)
+	return req, nil, interested, nil
+}
+
+func (h *Kaggle) OnResponse(resp *http.Response, ctx handler.Context) (*http.Response, error) {
+	b, err := handler.ReadBody(resp)
+	if err != nil {
+		msg := fmt.Sprintf("[http/%s] %v", h.Name(), err)
+		ctx.Logger.Errorf(msg)
+		return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
+	}
+	ctx.Logger.Debugf("[http]: received (%q %q):\nHeader:\n%q", ctx.Req.Method, ctx.Req.Host+ctx.Req.URL.Path, resp.Header)
+	// NOTE: The API redirects to the bucket, so there's nothing to record
+	// for the redirect itself.
+	if ctx.Req.Method == "HEAD" || resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+	// Downloads redirected to a bucket are identified by the API request.
+	origin := ctx.Origin()
+	originPath, err := handler.AbsURLPath(origin.URL.Path)
+	if err != nil {
+		msg := fmt.Sprintf("[http/%s] %v", h.Name(), err)
+		ctx.Logger.Errorf(msg)
+		return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
+	}
+	absPath, err := handler.AbsURLPath(ctx.Req.URL.Path)
+	if err != nil {
+		msg := fmt.Sprintf("[http/%s] %v", h.Name(), err)
+		ctx.Logger.Errorf(msg)
+		return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
+	}
+	rd, err := handler.NewResourceDescriptor(resp, b, ctx, h.Name())
+	if err != nil {
+		msg := fmt.Sprintf("[http/%s] %v", h.Name(), err)
+		ctx.Logger.Errorf(msg)
+		return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
+	}
+
+	var kaggleAnnotations map[string]any
+	if r, ok := parseAPIPath(originPath, origin.URL.Query().Get("datasetVersionNumber")); ok && isAPIHost(origin.Host) {
+		kaggleAnnotations = map[string]any{
+			"Kind":  r.kind,
+			"ID":    r.id(),
+			"Owner": r.owner,
+			"Slug":  r.slug,
+		}
+		if r.version != "" {
+			kaggleAnnotations["Version"] = r.version
+		}
+		if r.file != "" {
+			kaggleAnnotations["File"] = r.file
+		}
+		rd.Name = "kaggle://" + r.kind + "s/" + r.id()
+		rd.SetDependency(slsa.DependencyKind(r.kind), slsa.EcosystemKaggle)
+	} else {
+		// Bucket paths are /{bucket}/{id}/{version}/...
+		bucket, object, _ := strings.Cut(strings.TrimPrefix(absPath, "/"), "/")
+		kind := kindDataset
+		if bucket == "kaggle-models-data" {
+			kind = kindModel
+		}
+		kaggleAnnotations = map[string]any{
+			"Kind":   kind,
+			"Bucket": bucket,
+			"Object": object,
+		}
+		rd.SetDependency(slsa.DependencyKind(kind), slsa.EcosystemKaggle)
+	}
+	rd.Annotations["Kaggle"] = kaggleAnnotations
+	h.Store(ctx.ID, rd)
+	ctx.Logger.Debugf("[http]: RD %q", rd)
+	return resp, nil
+}
@@ -0,0 +1,129 @@
+package kaggle
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_parseAPIPath(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		path     string
+		version  string
+		resource *resource
+		id       string
+	}{
+		{
+			name:     "dataset",
+			path:     "/api/v1/datasets/download/owner/slug",
+			version:  "3",
+			resource: &resource{kind: kindDataset, owner: "owner", slug: "slug", version: "3"},
+			id:       "owner/slug/3",
+		},
+		{
+			name:     "dataset file",
+			path:     "/api/v1/datasets/download/owner/slug/dir/train.csv",
+			resource: &resource{kind: kindDataset, owner: "owner", slug: "slug", file: "dir/train.csv"},
+			id:       "owner/slug",
+		},
+		{
+			name:     "model",
+			path:     "/api/v1/models/google/gemma/pyTorch/2b/1/download",
+			resource: &resource{kind: kindModel, owner: "google", slug: "gemma/pyTorch/2b", version: "1"},
+			id:       "google/gemma/pyTorch/2b/1",
+		},
+		{
+			name:     "model file",
+			path:     "/api/v1/models/google/gemma/pyTorch/2b/1/download/config.json",
+			resource: &resource{kind: kindModel, owner: "google", slug: "gemma/pyTorch/2b", version: "1", file: "config.json"},
+			id:       "google/gemma/pyTorch/2b/1",
+		},
+		{
+			name:     "competition file",
+			path:     "/api/v1/competitions/data/download/titanic/train.csv",
+			resource: &resource{kind: kindDataset, owner: "competitions", slug: "titanic", file: "train.csv"},
+			id:       "competitions/titanic",
+		},
+		{
+			name:     "competition",
+			path:     "/api/v1/competitions/data/download-all/titanic",
+			resource: &resource{kind: kindDataset, owner: "competitions", slug: "titanic"},
+			id:       "competitions/titanic",
+		},
+		{
+			name: "dataset list",
+			path: "/api/v1/datasets/list",
+		},
+		{
+			name: "model metadata",
+			path: "/api/v1/models/google/gemma/get",
+		},
+		{
+			name: "not api",
+			path: "/datasets/owner/slug",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r, ok := parseAPIPath(tt.path, tt.version)
+			if ok != (tt.resource != nil) {
+				t.Fatalf("unexpected ok: %v", ok)
+			}
+			if diff := cmp.Diff(tt.resource, r, cmp.AllowUnexported(resource{})); diff != "" {
+				t.Fatalf("unexpected resource (-want +got): \n%s", diff)
+			}
+			if r == nil {
+				return
+			}
+			if diff := cmp.Diff(tt.id, r.id()); diff != "" {
+				t.Fatalf("unexpected id (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_isStorage(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		host     string
+		path     string
+		expected bool
+	}{
+		{
+			name:     "datasets",
+			host:     "storage.googleapis.com",
+			path:     "/kaggle-data-sets/123/456/archive.zip",
+			expected: true,
+		},
+		{
+			name:     "models",
+			host:     "storage.googleapis.com",
+			path:     "/kaggle-models-data/123/456/model.tar.gz",
+			expected: true,
+		},
+		{
+			name: "other bucket",
+			host: "storage.googleapis.com",
+			path: "/other/123",
+		},
+		{
+			name: "other host",
+			host: "example.com",
+			path: "/kaggle-data-sets/123",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := isStorage(tt.host, tt.path); got != tt.expected {
+				t.Fatalf("unexpected isStorage: %v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package lock
+
+import (
+	"fmt"
+	"net/http"
+
+	handler "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http"
+	"github.com/laurentsimon/jupyter-lineage/pkg/lockfile"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+// Handler enforces a lockfile. It refuses the responses whose
+// dependencies are not in the lockfile or whose digests differ.
+// It does not claim requests: the dependencies are identified
+// and hashed by the other handlers, and the handler vets them
+// as a response filter.
+// Responses without dependencies, e.g. HEAD requests, errors or
+// requests allowed unrecorded by a policy, are refused unless
+// the URL requested, or the first URL of its redirect chain,
+// is in the lockfile. The URIs the lockfile lists as unpinned
+// are not verified, since their content changes.
+// NOTE: Redirects are not vetted: the response they lead to is.
+type Handler struct {
+	handler.HandlerImpl
+	lockfile *lockfile.Lockfile
+}
+
+func New(l *lockfile.Lockfile) (*Handler, error) {
+	if l == nil {
+		return nil, fmt.Errorf("nil lockfile")
+	}
+	self := &Handler{lockfile: l}
+	self.SetName("Lockfile/v0.1")
+	return self, nil
+}
+
+func (h *Handler) OnRequest(req *http.Request, ctx handler.Context) (*http.Request, *http.Response, bool, error) {
+	return req, nil, false, nil
+}
+
+// OnResponse does nothing: the lockfile is enforced in FilterResponse,
+// on the dependencies identified by the handler of the response.
+func (h *Handler) OnResponse(resp *http.Response, ctx handler.Context) (*http.Response, error) {
+	return resp, nil
+}
+
+func (h *Handler) FilterResponse(resp *http.Response, deps []slsa.ResourceDescriptor, ctx handler.Context) (*http.Response, bool, error) {
+	if len(deps) == 0 {
+		origin := ctx.Origin()
+		url := ctx.Req.URL.Host + ctx.Req.URL.Path
+		if !h.lockfile.Contains(url) && !h.lockfile.Contains(origin.URL.Host+origin.URL.Path) {
+			msg := fmt.Sprintf("[http/%s] url (%q) not in lockfile", h.Name(), url)
+			ctx.Logger.Errorf(msg)
+			return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusForbidden, msg), false, nil
+		}
+		ctx.Logger.Debugf("[http/%s] url (%q) in lockfile", h.Name(), url)
+		return nil, true, nil
+	}
+	for _, rd := range deps {
+		e, ok := h.lockfile.Lookup(rd.URI)
+		if !ok && h.lockfile.IsUnpinned(rd.URI) {
+			ctx.Logger.Debugf("[http/%s] uri (%q) unpinned in lockfile", h.Name(), rd.URI)
+			continue
+		}
+		if !ok {
+			msg := fmt.Sprintf("[http/%s] uri (%q) not in lockfile", h.Name(), rd.URI)
+			ctx.Logger.Errorf(msg)
+			return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusForbidden, msg), false, nil
+		}
+		if err := e.Verify(rd.DigestSet); err != nil {
+			msg := fmt.Sprintf("[http/%s] uri (%q): %v", h.Name(), rd.URI, err)
+			ctx.Logger.Errorf(msg)
+			return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusForbidden, msg), false, nil
+		}
+		ctx.Logger.Debugf("[http/%s] uri (%q) matches lockfile", h.Name(), rd.URI)
+	}
+	return nil, true, nil
+}
@@ -0,0 +1,124 @@
+package lock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	handler "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http"
+	logimpl "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/logger"
+	"github.com/laurentsimon/jupyter-lineage/pkg/lockfile"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+func Test_FilterResponse(t *testing.T) {
+	t.Parallel()
+	l, err := lockfile.Parse(strings.NewReader(`{
+		"version": 1,
+		"entries": [
+			{"uri": "hf://owner/name@main/config.json", "digest": {"sha256": "aa"}, "location": "huggingface.co/owner/name/resolve/main/config.json"},
+			{"uri": "example.com/a", "digest": {"sha256": "bb"}}
+		],
+		"unpinned": ["example.com/api"]
+	}`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	tests := []struct {
+		name      string
+		method    string
+		url       string
+		redirects []string
+		deps      []slsa.ResourceDescriptor
+		denied    bool
+	}{
+		{
+			name: "pinned",
+			url:  "https://example.com/a",
+			deps: []slsa.ResourceDescriptor{{URI: "example.com/a", DigestSet: slsa.DigestSet{"sha256": "bb"}}},
+		},
+		{
+			name:   "digest mismatch",
+			url:    "https://example.com/a",
+			deps:   []slsa.ResourceDescriptor{{URI: "example.com/a", DigestSet: slsa.DigestSet{"sha256": "cc"}}},
+			denied: true,
+		},
+		{
+			name:   "dependency not in lockfile",
+			url:    "https://example.com/b",
+			deps:   []slsa.ResourceDescriptor{{URI: "example.com/b", DigestSet: slsa.DigestSet{"sha256": "bb"}}},
+			denied: true,
+		},
+		{
+			name: "unpinned dependency",
+			url:  "https://example.com/api",
+			deps: []slsa.ResourceDescriptor{{URI: "example.com/api", DigestSet: slsa.DigestSet{"sha256": "dd"}}},
+		},
+		{
+			name:   "no dependency at location",
+			method: "HEAD",
+			url:    "https://huggingface.co/owner/name/resolve/main/config.json",
+		},
+		{
+			name: "no dependency at uri",
+			url:  "https://example.com/a",
+		},
+		{
+			name:   "no dependency not in lockfile",
+			method: "HEAD",
+			url:    "https://huggingface.co/owner/name/resolve/main/model.bin",
+			denied: true,
+		},
+		{
+			name:      "no dependency redirected from lockfile",
+			url:       "https://cdn.example.com/a",
+			redirects: []string{"https://example.com/a"},
+		},
+		{
+			name:      "no dependency redirected from elsewhere",
+			url:       "https://cdn.example.com/a",
+			redirects: []string{"https://example.com/b"},
+			denied:    true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			h, err := New(l)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			method := tt.method
+			if method == "" {
+				method = "GET"
+			}
+			req := httptest.NewRequest(method, tt.url, nil)
+			ctx := handler.Context{ID: 1, Req: req, Logger: logimpl.Logger{}}
+			for _, u := range tt.redirects {
+				ctx.Redirects = append(ctx.Redirects, handler.Redirect{
+					Req:        httptest.NewRequest(method, u, nil),
+					StatusCode: http.StatusFound,
+				})
+			}
+			resp := handler.NewResponse(req, handler.ContentTypeText, http.StatusOK, "")
+			r, record, err := h.FilterResponse(resp, tt.deps, ctx)
+			if err != nil {
+				t.Fatalf("FilterResponse: %v", err)
+			}
+			if tt.denied {
+				if r == nil || r.StatusCode != http.StatusForbidden {
+					t.Fatalf("response not denied: %v", r)
+				}
+				return
+			}
+			if r != nil {
+				t.Fatalf("unexpected response: %d", r.StatusCode)
+			}
+			if !record {
+				t.Fatalf("dependencies not recorded")
+			}
+		})
+	}
+}
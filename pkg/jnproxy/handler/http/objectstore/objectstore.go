@@ -0,0 +1,285 @@
+package objectstore
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"net/http"
+	"net/url"
+	"strings"
+
+	handler "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+const (
+	providerS3  = "s3"
+	providerGCS = "gcs"
+)
+
+type Storage struct {
+	handler.HandlerImpl
+}
+
+func New() (*Storage, error) {
+	self := &Storage{}
+	self.SetName("ObjectStorage/v0.1")
+	return self, nil
+}
+
+type object struct {
+	provider string
+	bucket   string
+	key      string
+	// versionId for S3, generation for GCS.
+	version string
+}
+
+func (o *object) uri() string {
+	switch o.provider {
+	case providerS3:
+		uri := "s3://" + o.bucket + "/" + o.key
+		if o.version != "" {
+			uri += "?versionId=" + o.version
+		}
+		return uri
+	default:
+		// Same format as gsutil.
+		uri := "gs://" + o.bucket + "/" + o.key
+		if o.version != "" {
+			uri += "#" + o.version
+		}
+		return uri
+	}
+}
+
+func (o *object) ecosystem() string {
+	switch o.provider {
+	case providerS3:
+		return slsa.EcosystemS3
+	default:
+		return slsa.EcosystemGCS
+	}
+}
+
+// isCompressed returns true if the content received differs from
+// the object stored, e.g. with GCS decompressive transcoding or a
+// transport that decompresses transparently. The checksums of the
+// object do not apply to the content then.
+// See https://cloud.google.com/storage/docs/transcoding.
+func isCompressed(resp *http.Response) bool {
+	return resp.Uncompressed ||
+		strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") ||
+		strings.EqualFold(resp.Header.Get("X-Goog-Stored-Content-Encoding"), "gzip")
+}
+
+// parse returns the object requested by a URL.
+// WARNING: host must not contain a port.
+func parse(host, absPath string, query url.Values) (*object, bool) {
+	switch {
+	case strings.HasSuffix(host, ".amazonaws.com") || strings.HasSuffix(host, ".amazonaws.com.cn"):
+		return parseS3(host, absPath, query)
+	case host == "storage.googleapis.com" || strings.HasSuffix(host, ".storage.googleapis.com"):
+		return parseGCS(host, absPath, query)
+	}
+	return nil, false
+}
+
+// See https://docs.aws.amazon.com/AmazonS3/latest/userguide/VirtualHosting.html.
+func parseS3(host, absPath string, query url.Values) (*object, bool) {
+	o := object{
+		provider: providerS3,
+		version:  query.Get("versionId"),
+	}
+	p := strings.TrimPrefix(absPath, "/")
+	if strings.HasPrefix(host, "s3.") || strings.HasPrefix(host, "s3-") {
+		// Path-style: s3.{region}.amazonaws.com/{bucket}/{key}.
+		o.bucket, o.key, _ = strings.Cut(p, "/")
+	} else {
+		// Virtual-hosted-style: {bucket}.s3.{region}.amazonaws.com/{key}.
+		// NOTE: Bucket names may contain dots.
+		i := strings.LastIndex(host, ".s3.")
+		if j := strings.LastIndex(host, ".s3-"); j > i {
+			i = j
+		}
+		if i <= 0 {
+			return nil, false
+		}
+		o.bucket, o.key = host[:i], p
+	}
+	if o.bucket == "" || o.key == "" || strings.HasSuffix(o.key, "/") {
+		return nil, false
+	}
+	return &o, true
+}
+
+// See https://cloud.google.com/storage/docs/request-endpoints.
+func parseGCS(host, absPath string, query url.Values) (*object, bool) {
+	o := object{
+		provider: providerGCS,
+		version:  query.Get("generation"),
+	}
+	p := strings.TrimPrefix(absPath, "/")
+	switch {
+	case host != "storage.googleapis.com":
+		// XML API, virtual-hosted-style: {bucket}.storage.googleapis.com/{object}.
+		o.bucket, o.key = strings.TrimSuffix(host, ".storage.googleapis.com"), p
+	case strings.HasPrefix(p, "download/storage/v1/b/") || strings.HasPrefix(p, "storage/v1/b/"):
+		// JSON API: /download/storage/v1/b/{bucket}/o/{object} or /storage/v1/b/{bucket}/o/{object}?alt=media.
+		// Without alt=media, the response contains the metadata only.
+		if strings.HasPrefix(p, "storage/") && query.Get("alt") != "media" {
+			return nil, false
+		}
+		rest := p[strings.Index(p, "/b/")+len("/b/"):]
+		bucket, obj, ok := strings.Cut(rest, "/o/")
+		if !ok {
+			return nil, false
+		}
+		o.bucket, o.key = bucket, obj
+	case strings.HasPrefix(p, "upload/") || strings.HasPrefix(p, "batch/"):
+		return nil, false
+	default:
+		// XML API, path-style: storage.googleapis.com/{bucket}/{object}.
+		o.bucket, o.key, _ = strings.Cut(p, "/")
+	}
+	if o.bucket == "" || o.key == "" || strings.HasSuffix(o.key, "/") {
+		return nil, false
+	}
+	return &o, true
+}
+
+func (h *Storage) OnRequest(req *http.Request, ctx handler.Context) (*http.Request, *http.Response, bool, error) {
+	absPath, err := handler.AbsURLPath(req.URL.Path)
+	if err != nil {
+		msg := fmt.Sprintf("[http/%s] %v", h.Name(), err)
+		ctx.Logger.Errorf(msg)
+		return req, handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), false, nil
+	}
+	_, interested := parse(req.URL.Hostname(), absPath, req.URL.Query())
+	return req, nil, interested, nil
+}
+
+func (h *Storage) OnResponse(resp *http.Response, ctx handler.Context) (*http.Response, error) {
+	b, err := handler.ReadBody(resp)
+	if err != nil {
+		msg := fmt.Sprintf("[http/%s] %v", h.Name(), err)
+		ctx.Logger.Errorf(msg)
+		return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
+	}
+	ctx.Logger.Debugf("[http]: received (%q %q):\nHeader:\n%q", ctx.Req.Method, ctx.Req.Host+ctx.Req.URL.Path, resp.Header)
+	// NOTE: Partial content (206) cannot be verified against the object's checksums.
+	if ctx.Req.Method == "HEAD" || resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+	absPath, err := handler.AbsURLPath(ctx.Req.URL.Path)
+	if err != nil {
+		msg := fmt.Sprintf("[http/%s] %v", h.Name(), err)
+		ctx.Logger.Errorf(msg)
+		return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
+	}
+	o, ok := parse(ctx.Req.URL.Hostname(), absPath, ctx.Req.URL.Query())
+	if !ok {
+		msg := fmt.Sprintf("[http/%s] invalid path (%q)", h.Name(), absPath)
+		ctx.Logger.Errorf(msg)
+		return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
+	}
+	rd, err := handler.NewResourceDescriptor(resp, b, ctx, h.Name())
+	if err != nil {
+		msg := fmt.Sprintf("[http/%s] %v", h.Name(), err)
+		ctx.Logger.Errorf(msg)
+		return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
+	}
+
+	// The version served may differ from the one requested if none was requested.
+	header := resp.Header
+	checksums := map[string]string{}
+	switch o.provider {
+	case providerS3:
+		if v := header.Get("X-Amz-Version-Id"); v != "" && v != "null" {
+			o.version = v
+		}
+		// See https://docs.aws.amazon.com/AmazonS3/latest/userguide/checking-object-integrity.html.
+		for _, algo := range []string{"sha256", "sha1", "crc32", "crc32c"} {
+			// NOTE: Checksums of multipart uploads are composite, i.e. {checksum}-{parts}.
+			if v := header.Get("X-Amz-Checksum-" + algo); v != "" && !strings.Contains(v, "-") {
+				checksums[algo] = v
+			}
+		}
+	case providerGCS:
+		if v := header.Get("X-Goog-Generation"); v != "" {
+			o.version = v
+		}
+		// See https://cloud.google.com/storage/docs/xml-api/reference-headers#xgooghash.
+		for _, value := range header.Values("X-Goog-Hash") {
+			for _, kv := range strings.Split(value, ",") {
+				if algo, v, ok := strings.Cut(strings.TrimSpace(kv), "="); ok {
+					checksums[algo] = v
+				}
+			}
+		}
+	}
+	if isCompressed(resp) {
+		ctx.Logger.Debugf("[http/%s] object (%q) compressed: checksums not verified", h.Name(), o.uri())
+		checksums = map[string]string{}
+	} else if err := verifyChecksums(checksums, b); err != nil {
+		msg := fmt.Sprintf("[http/%s] object (%q): %v", h.Name(), o.uri(), err)
+		ctx.Logger.Errorf(msg)
+		return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
+	}
+
+	storageAnnotations := map[string]any{
+		"Provider": o.provider,
+		"Bucket":   o.bucket,
+		"Key":      o.key,
+	}
+	if o.version != "" {
+		storageAnnotations["Version"] = o.version
+	}
+	if etag := header.Get("ETag"); etag != "" {
+		storageAnnotations["ETag"] = strings.Trim(etag, "\"")
+	}
+	if len(checksums) > 0 {
+		storageAnnotations["Checksums"] = checksums
+	}
+	rd.Name = o.uri()
+	rd.Annotations["ObjectStorage"] = storageAnnotations
+	rd.SetDependency(slsa.KindFile, o.ecosystem())
+	h.Store(ctx.ID, rd)
+	ctx.Logger.Debugf("[http]: RD %q", rd)
+	return resp, nil
+}
+
+// verifyChecksums verifies the base64-encoded checksums
+// advertised by the server. Checksums for algorithms we do not support
+// are removed, so that only verified checksums are recorded.
+func verifyChecksums(checksums map[string]string, b []byte) error {
+	for algo, expected := range checksums {
+		var actual []byte
+		switch algo {
+		case "md5":
+			sum := md5.Sum(b)
+			actual = sum[:]
+		case "sha1":
+			sum := sha1.Sum(b)
+			actual = sum[:]
+		case "sha256":
+			sum := sha256.Sum256(b)
+			actual = sum[:]
+		case "crc32":
+			actual = binary.BigEndian.AppendUint32(nil, crc32.ChecksumIEEE(b))
+		case "crc32c":
+			actual = binary.BigEndian.AppendUint32(nil, crc32.Checksum(b, crc32.MakeTable(crc32.Castagnoli)))
+		default:
+			delete(checksums, algo)
+			continue
+		}
+		if v := base64.StdEncoding.EncodeToString(actual); v != expected {
+			return fmt.Errorf("%s mismatch. Header (%v) != actual (%v)", algo, expected, v)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,313 @@
+package objectstore
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	handler "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http"
+	logimpl "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/logger"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+func Test_parse(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		host   string
+		path   string
+		query  string
+		object *object
+		uri    string
+	}{
+		{
+			name:   "s3 path style",
+			host:   "s3.us-east-1.amazonaws.com",
+			path:   "/bucket/dir/key.bin",
+			object: &object{provider: providerS3, bucket: "bucket", key: "dir/key.bin"},
+			uri:    "s3://bucket/dir/key.bin",
+		},
+		{
+			name:   "s3 legacy path style",
+			host:   "s3-us-west-2.amazonaws.com",
+			path:   "/bucket/key.bin",
+			object: &object{provider: providerS3, bucket: "bucket", key: "key.bin"},
+			uri:    "s3://bucket/key.bin",
+		},
+		{
+			name:   "s3 virtual hosted",
+			host:   "bucket.s3.us-east-1.amazonaws.com",
+			path:   "/key.bin",
+			query:  "versionId=v1",
+			object: &object{provider: providerS3, bucket: "bucket", key: "key.bin", version: "v1"},
+			uri:    "s3://bucket/key.bin?versionId=v1",
+		},
+		{
+			name:   "s3 virtual hosted with dots",
+			host:   "my.bucket.example.s3.us-east-1.amazonaws.com",
+			path:   "/key.bin",
+			object: &object{provider: providerS3, bucket: "my.bucket.example", key: "key.bin"},
+			uri:    "s3://my.bucket.example/key.bin",
+		},
+		{
+			name:   "s3 legacy virtual hosted with dots",
+			host:   "my.bucket.s3-eu-west-1.amazonaws.com",
+			path:   "/key.bin",
+			object: &object{provider: providerS3, bucket: "my.bucket", key: "key.bin"},
+			uri:    "s3://my.bucket/key.bin",
+		},
+		{
+			name:   "s3 china",
+			host:   "bucket.s3.cn-north-1.amazonaws.com.cn",
+			path:   "/key.bin",
+			object: &object{provider: providerS3, bucket: "bucket", key: "key.bin"},
+			uri:    "s3://bucket/key.bin",
+		},
+		{
+			name:   "s3 china path style",
+			host:   "s3.cn-north-1.amazonaws.com.cn",
+			path:   "/bucket/key.bin",
+			object: &object{provider: providerS3, bucket: "bucket", key: "key.bin"},
+			uri:    "s3://bucket/key.bin",
+		},
+		{
+			name: "s3 bucket listing",
+			host: "bucket.s3.us-east-1.amazonaws.com",
+			path: "/",
+		},
+		{
+			name: "s3 directory",
+			host: "s3.us-east-1.amazonaws.com",
+			path: "/bucket/dir/",
+		},
+		{
+			name: "other aws service",
+			host: "ec2.us-east-1.amazonaws.com",
+			path: "/key.bin",
+		},
+		{
+			name:   "gcs path style",
+			host:   "storage.googleapis.com",
+			path:   "/bucket/dir/object.bin",
+			query:  "generation=123",
+			object: &object{provider: providerGCS, bucket: "bucket", key: "dir/object.bin", version: "123"},
+			uri:    "gs://bucket/dir/object.bin#123",
+		},
+		{
+			name:   "gcs virtual hosted with dots",
+			host:   "my.bucket.storage.googleapis.com",
+			path:   "/object.bin",
+			object: &object{provider: providerGCS, bucket: "my.bucket", key: "object.bin"},
+			uri:    "gs://my.bucket/object.bin",
+		},
+		{
+			name:   "gcs json api media",
+			host:   "storage.googleapis.com",
+			path:   "/storage/v1/b/bucket/o/dir%2Fobject.bin",
+			query:  "alt=media",
+			object: &object{provider: providerGCS, bucket: "bucket", key: "dir/object.bin"},
+			uri:    "gs://bucket/dir/object.bin",
+		},
+		{
+			name:   "gcs json api download",
+			host:   "storage.googleapis.com",
+			path:   "/download/storage/v1/b/bucket/o/object.bin",
+			object: &object{provider: providerGCS, bucket: "bucket", key: "object.bin"},
+			uri:    "gs://bucket/object.bin",
+		},
+		{
+			name: "gcs json api metadata",
+			host: "storage.googleapis.com",
+			path: "/storage/v1/b/bucket/o/object.bin",
+		},
+		{
+			name: "gcs json api listing",
+			host: "storage.googleapis.com",
+			path: "/storage/v1/b/bucket/o",
+		},
+		{
+			name: "gcs upload",
+			host: "storage.googleapis.com",
+			path: "/upload/storage/v1/b/bucket/o",
+		},
+		{
+			name: "other host",
+			host: "example.com",
+			path: "/bucket/key.bin",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			u, err := url.Parse("https://" + tt.host + tt.path + "?" + tt.query)
+			if err != nil {
+				t.Fatalf("url parse: %v", err)
+			}
+			o, ok := parse(u.Hostname(), u.Path, u.Query())
+			if ok != (tt.object != nil) {
+				t.Fatalf("unexpected ok: %v", ok)
+			}
+			if diff := cmp.Diff(tt.object, o, cmp.AllowUnexported(object{})); diff != "" {
+				t.Fatalf("unexpected object (-want +got): \n%s", diff)
+			}
+			if o == nil {
+				return
+			}
+			if diff := cmp.Diff(tt.uri, o.uri()); diff != "" {
+				t.Fatalf("unexpected uri (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_verifyChecksums(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		checksums map[string]string
+		verified  map[string]string
+		err       bool
+	}{
+		{
+			name: "all algorithms",
+			checksums: map[string]string{
+				"md5":    "XUFAKrxLKna5cZ2REBfFkg==",
+				"sha1":   "qvTGHdzF6KLavt4PO0gs2a6pQ00=",
+				"sha256": "LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ=",
+				"crc32":  "NhCmhg==",
+				"crc32c": "mnG7TA==",
+			},
+			verified: map[string]string{
+				"md5":    "XUFAKrxLKna5cZ2REBfFkg==",
+				"sha1":   "qvTGHdzF6KLavt4PO0gs2a6pQ00=",
+				"sha256": "LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ=",
+				"crc32":  "NhCmhg==",
+				"crc32c": "mnG7TA==",
+			},
+		},
+		{
+			name:      "unsupported algorithm",
+			checksums: map[string]string{"crc64nvme": "AAAAAAAAAAA="},
+			verified:  map[string]string{},
+		},
+		{
+			name:      "sha1 mismatch",
+			checksums: map[string]string{"sha1": "AAAAAAAAAAAAAAAAAAAAAAAAAAA="},
+			err:       true,
+		},
+		{
+			name:      "crc32 mismatch",
+			checksums: map[string]string{"crc32": "AAAAAA=="},
+			err:       true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := verifyChecksums(tt.checksums, []byte("hello"))
+			if (err != nil) != tt.err {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.verified, tt.checksums); diff != "" {
+				t.Fatalf("unexpected checksums (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_OnResponse(t *testing.T) {
+	t.Parallel()
+	const md5 = "XUFAKrxLKna5cZ2REBfFkg=="
+	const mismatch = "AAAAAAAAAAAAAAAAAAAAAA=="
+	tests := []struct {
+		name      string
+		url       string
+		header    map[string]string
+		status    int
+		ecosystem string
+		checksums map[string]string
+	}{
+		{
+			name:      "gcs verified",
+			url:       "https://storage.googleapis.com/bucket/object.bin",
+			header:    map[string]string{"X-Goog-Hash": "md5=" + md5},
+			status:    http.StatusOK,
+			ecosystem: slsa.EcosystemGCS,
+			checksums: map[string]string{"md5": md5},
+		},
+		{
+			name:   "gcs mismatch",
+			url:    "https://storage.googleapis.com/bucket/object.bin",
+			header: map[string]string{"X-Goog-Hash": "md5=" + mismatch},
+			status: http.StatusInternalServerError,
+		},
+		{
+			name: "gcs decompressive transcoding",
+			url:  "https://storage.googleapis.com/bucket/object.bin",
+			header: map[string]string{
+				"X-Goog-Hash":                    "md5=" + mismatch,
+				"X-Goog-Stored-Content-Encoding": "gzip",
+			},
+			status:    http.StatusOK,
+			ecosystem: slsa.EcosystemGCS,
+		},
+		{
+			name: "s3 gzip",
+			url:  "https://bucket.s3.us-east-1.amazonaws.com/object.bin",
+			header: map[string]string{
+				"X-Amz-Checksum-Sha1": "AAAAAAAAAAAAAAAAAAAAAAAAAAA=",
+				"Content-Encoding":    "gzip",
+			},
+			status:    http.StatusOK,
+			ecosystem: slsa.EcosystemS3,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			h, err := New()
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			req := httptest.NewRequest("GET", tt.url, nil)
+			resp := handler.NewResponse(req, "application/octet-stream", http.StatusOK, "hello")
+			for k, v := range tt.header {
+				resp.Header.Set(k, v)
+			}
+			ctx := handler.Context{ID: 1, Req: req, Logger: logimpl.Logger{}}
+			r, err := h.OnResponse(resp, ctx)
+			if err != nil {
+				t.Fatalf("OnResponse: %v", err)
+			}
+			if diff := cmp.Diff(tt.status, r.StatusCode); diff != "" {
+				t.Fatalf("unexpected status (-want +got): \n%s", diff)
+			}
+			if r.StatusCode != http.StatusOK {
+				return
+			}
+			deps, err := h.Dependencies(ctx)
+			if err != nil {
+				t.Fatalf("Dependencies: %v", err)
+			}
+			if len(deps) != 1 {
+				t.Fatalf("unexpected dependencies: %v", deps)
+			}
+			if _, ecosystem, _ := deps[0].Dependency(); ecosystem != tt.ecosystem {
+				t.Fatalf("unexpected ecosystem: %q", ecosystem)
+			}
+			annotations := deps[0].Annotations["ObjectStorage"].(map[string]any)
+			checksums, _ := annotations["Checksums"].(map[string]string)
+			if diff := cmp.Diff(tt.checksums, checksums); diff != "" {
+				t.Fatalf("unexpected checksums (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
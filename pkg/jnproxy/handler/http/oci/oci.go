@@ -0,0 +1,233 @@
+package oci
+
+import (
+	"crypto/sha512"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	handler "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+// See https://github.com/opencontainers/distribution-spec/blob/main/spec.md#endpoints
+const (
+	typeManifest = "manifests"
+	typeBlob     = "blobs"
+)
+
+// Tags resolved but not pulled within tagTimeout are forgotten.
+const tagTimeout = time.Minute
+
+type Registry struct {
+	handler.HandlerImpl
+	// Tags resolved via HEAD requests, keyed by host/name@digest.
+	// Clients like docker resolve a tag with a HEAD request,
+	// then pull the manifest by digest.
+	tags sync.Map
+}
+
+type resolution struct {
+	tag  string
+	time time.Time
+}
+
+// resolved records that the tag of name resolved to digest.
+func (h *Registry) resolved(host, name, tag, digest string) {
+	h.tags.Range(func(key, value any) bool {
+		if time.Since(value.(resolution).time) > tagTimeout {
+			h.tags.Delete(key)
+		}
+		return true
+	})
+	h.tags.Store(host+"/"+name+"@"+digest, resolution{tag: tag, time: time.Now()})
+}
+
+// resolution returns the tag of name that resolved to digest, if any.
+func (h *Registry) resolution(host, name, digest string) string {
+	v, ok := h.tags.LoadAndDelete(host + "/" + name + "@" + digest)
+	if !ok {
+		return ""
+	}
+	r := v.(resolution)
+	if time.Since(r.time) > tagTimeout {
+		return ""
+	}
+	return r.tag
+}
+
+func New() (*Registry, error) {
+	self := &Registry{}
+	self.SetName("OCIRegistry/v0.1")
+	return self, nil
+}
+
+type reference struct {
+	name  string // Repository name, e.g. library/ubuntu.
+	typ   string // typeManifest or typeBlob.
+	value string // Tag or digest.
+}
+
+func (r *reference) isDigest() bool {
+	return strings.Contains(r.value, ":")
+}
+
+// parsePath parses /v2/<name>/manifests/<reference> and /v2/<name>/blobs/<digest>.
+// WARNING: absPath must be absolute.
+func parsePath(absPath string) (*reference, bool) {
+	if !strings.HasPrefix(absPath, "/v2/") {
+		return nil, false
+	}
+	rest := strings.TrimPrefix(absPath, "/v2/")
+	for _, typ := range []string{typeManifest, typeBlob} {
+		sep := "/" + typ + "/"
+		i := strings.LastIndex(rest, sep)
+		if i <= 0 {
+			continue
+		}
+		ref := &reference{
+			name:  rest[:i],
+			typ:   typ,
+			value: rest[i+len(sep):],
+		}
+		if ref.value == "" || strings.Contains(ref.value, "/") {
+			return nil, false
+		}
+		// Blobs are always addressed by digest.
+		if typ == typeBlob && !ref.isDigest() {
+			return nil, false
+		}
+		return ref, true
+	}
+	return nil, false
+}
+
+func (h *Registry) OnRequest(req *http.Request, ctx handler.Context) (*http.Request, *http.Response, bool, error) {
+	absPath, err := handler.AbsURLPath(req.URL.Path)
+	if err != nil {
+		msg := fmt.Sprintf("[http/%s] %v", h.Name(), err)
+		ctx.Logger.Errorf(msg)
+		return req, handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), false, nil
+	}
+	// NOTE: Any host is a registry candidate: notebooks pull from public
+	// registries, e.g. ghcr.io or gcr.io, as well as from private ones
+	// whose hosts cannot be listed in advance. The paths of the distribution
+	// API are specific enough, and content pulled by digest is verified
+	// against it, so a server that is not a registry cannot pass off
+	// other content as a pinned image.
+	_, interested := parsePath(absPath)
+	return req, nil, interested, nil
+}
+
+func (h *Registry) OnResponse(resp *http.Response, ctx handler.Context) (*http.Response, error) {
+	b, err := handler.ReadBody(resp)
+	if err != nil {
+		msg := fmt.Sprintf("[http/%s] %v", h.Name(), err)
+		ctx.Logger.Errorf(msg)
+		return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
+	}
+	ctx.Logger.Debugf("[http]: received (%q %q):\nHeader:\n%q", ctx.Req.Method, ctx.Req.Host+ctx.Req.URL.Path, resp.Header)
+	// NOTE: Errors (e.g. 401 asking for a token) and redirects to blob storage
+	// carry no content to record. Registries redirect blob downloads to storage:
+	// the download is identified by the request made to the registry.
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+	origin := ctx.Origin()
+	registry := origin.Host
+	absPath, err := handler.AbsURLPath(origin.URL.Path)
+	if err != nil {
+		msg := fmt.Sprintf("[http/%s] %v", h.Name(), err)
+		ctx.Logger.Errorf(msg)
+		return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
+	}
+	ref, ok := parsePath(absPath)
+	if !ok {
+		msg := fmt.Sprintf("[http/%s] invalid path (%q)", h.Name(), absPath)
+		ctx.Logger.Errorf(msg)
+		return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
+	}
+	header := resp.Header
+	headerDigest := header.Get("Docker-Content-Digest")
+	if ctx.Req.Method == "HEAD" {
+		// Remember which tag resolved to which digest.
+		if ref.typ == typeManifest && !ref.isDigest() && headerDigest != "" {
+			h.resolved(registry, ref.name, ref.value, headerDigest)
+		}
+		return resp, nil
+	}
+
+	rd, err := handler.NewResourceDescriptor(resp, b, ctx, h.Name())
+	if err != nil {
+		msg := fmt.Sprintf("[http/%s] %v", h.Name(), err)
+		ctx.Logger.Errorf(msg)
+		return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
+	}
+
+	// Content addressed by digest must match it.
+	digest := "sha256:" + rd.DigestSet["sha256"]
+	expected := headerDigest
+	if len(ctx.Redirects) > 0 {
+		// The header comes from storage, not the registry.
+		expected = ""
+	}
+	if ref.isDigest() {
+		expected = ref.value
+	}
+	if expected != "" {
+		if err := verifyDigest(expected, b); err != nil {
+			msg := fmt.Sprintf("[http/%s] %s (%q): %v", h.Name(), ref.typ, ref.name, err)
+			ctx.Logger.Errorf(msg)
+			return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
+		}
+		digest = expected
+	}
+
+	// A pull is mutable if the client asked for a tag, either directly
+	// or by resolving the tag with a previous HEAD request.
+	var tag string
+	if ref.isDigest() {
+		tag = h.resolution(registry, ref.name, ref.value)
+	} else {
+		tag = ref.value
+	}
+	ociAnnotations := map[string]any{
+		"Registry":   registry,
+		"Repository": ref.name,
+		"Type":       strings.TrimSuffix(ref.typ, "s"),
+		"Reference":  ref.value,
+		"Digest":     digest,
+		"Mutable":    tag != "",
+	}
+	if tag != "" {
+		ociAnnotations["Tag"] = tag
+	}
+	rd.Name = registry + "/" + ref.name + "@" + digest
+	rd.Annotations["OCI"] = ociAnnotations
+	rd.SetDependency(slsa.KindPackage, slsa.EcosystemOCI)
+	h.Store(ctx.ID, rd)
+	ctx.Logger.Debugf("[http]: RD %q", rd)
+	return resp, nil
+}
+
+func verifyDigest(digest string, b []byte) error {
+	algo, value, ok := strings.Cut(digest, ":")
+	if !ok {
+		return fmt.Errorf("invalid digest (%q)", digest)
+	}
+	var actual string
+	switch algo {
+	case "sha256":
+		actual = handler.SHA256(b)
+	case "sha512":
+		actual = fmt.Sprintf("%x", sha512.Sum512(b))
+	default:
+		return fmt.Errorf("unsupported digest algorithm (%q)", algo)
+	}
+	if actual != value {
+		return fmt.Errorf("digest mismatch. Expected (%v) != actual (%v)", value, actual)
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package oci
+
+import (
+	"crypto/sha512"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	handler "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http"
+	logimpl "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/logger"
+)
+
+func Test_parsePath(t *testing.T) {
+	t.Parallel()
+	const digest = "sha256:9fa50ec37ab9f93c5f9d90e65827d3af0d5d40439fa50ec37ab9f93c5f9d90e6"
+	tests := []struct {
+		name string
+		path string
+		ref  *reference
+	}{
+		{
+			name: "manifest by tag",
+			path: "/v2/library/ubuntu/manifests/22.04",
+			ref:  &reference{name: "library/ubuntu", typ: typeManifest, value: "22.04"},
+		},
+		{
+			name: "manifest by digest",
+			path: "/v2/library/ubuntu/manifests/" + digest,
+			ref:  &reference{name: "library/ubuntu", typ: typeManifest, value: digest},
+		},
+		{
+			name: "blob",
+			path: "/v2/org/team/model/blobs/" + digest,
+			ref:  &reference{name: "org/team/model", typ: typeBlob, value: digest},
+		},
+		{
+			name: "repository named blobs",
+			path: "/v2/org/blobs/manifests/latest",
+			ref:  &reference{name: "org/blobs", typ: typeManifest, value: "latest"},
+		},
+		{
+			name: "blob by tag",
+			path: "/v2/library/ubuntu/blobs/latest",
+		},
+		{
+			name: "no name",
+			path: "/v2/manifests/latest",
+		},
+		{
+			name: "no reference",
+			path: "/v2/library/ubuntu/manifests/",
+		},
+		{
+			name: "tags list",
+			path: "/v2/library/ubuntu/tags/list",
+		},
+		{
+			name: "not v2",
+			path: "/v1/library/ubuntu/manifests/latest",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ref, ok := parsePath(tt.path)
+			if ok != (tt.ref != nil) {
+				t.Fatalf("unexpected ok: %v", ok)
+			}
+			if diff := cmp.Diff(tt.ref, ref, cmp.AllowUnexported(reference{})); diff != "" {
+				t.Fatalf("unexpected reference (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_verifyDigest(t *testing.T) {
+	t.Parallel()
+	content := []byte("content")
+	tests := []struct {
+		name   string
+		digest string
+		valid  bool
+	}{
+		{
+			name:   "sha256",
+			digest: "sha256:" + handler.SHA256(content),
+			valid:  true,
+		},
+		{
+			name:   "sha512",
+			digest: fmt.Sprintf("sha512:%x", sha512.Sum512(content)),
+			valid:  true,
+		},
+		{
+			name:   "mismatch",
+			digest: "sha256:" + handler.SHA256([]byte("other")),
+		},
+		{
+			name:   "unsupported algorithm",
+			digest: "md5:9a0364b9e99bb480dd25e1f0284c8555",
+		},
+		{
+			name:   "no algorithm",
+			digest: handler.SHA256(content),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if err := verifyDigest(tt.digest, content); (err == nil) != tt.valid {
+				t.Fatalf("unexpected err: %v", err)
+			}
+		})
+	}
+}
+
+func Test_OnResponse(t *testing.T) {
+	t.Parallel()
+	const (
+		registry = "https://registry.example.com"
+		storage  = "https://storage.example.com/blobs/aa?signature=x"
+		content  = `{"schemaVersion": 2}`
+	)
+	digest := "sha256:" + handler.SHA256([]byte(content))
+	response := func(req *http.Request, status int, body string, header map[string]string) *http.Response {
+		resp := handler.NewResponse(req, "application/json", status, body)
+		for k, v := range header {
+			resp.Header.Set(k, v)
+		}
+		return resp
+	}
+	tests := []struct {
+		name        string
+		resolve     bool // Resolve the tag with a HEAD request first.
+		url         string
+		redirected  string // URL of the request redirected to url.
+		header      map[string]string
+		body        string
+		status      int
+		uri         string
+		annotations map[string]any
+	}{
+		{
+			name:   "manifest by tag",
+			url:    registry + "/v2/library/ubuntu/manifests/22.04",
+			header: map[string]string{"Docker-Content-Digest": digest},
+			body:   content,
+			status: http.StatusOK,
+			uri:    "registry.example.com/v2/library/ubuntu/manifests/22.04",
+			annotations: map[string]any{
+				"Registry": "registry.example.com", "Repository": "library/ubuntu", "Type": "manifest",
+				"Reference": "22.04", "Digest": digest, "Mutable": true, "Tag": "22.04",
+			},
+		},
+		{
+			name:    "manifest by resolved tag",
+			resolve: true,
+			url:     registry + "/v2/library/ubuntu/manifests/" + digest,
+			body:    content,
+			status:  http.StatusOK,
+			uri:     "registry.example.com/v2/library/ubuntu/manifests/" + digest,
+			annotations: map[string]any{
+				"Registry": "registry.example.com", "Repository": "library/ubuntu", "Type": "manifest",
+				"Reference": digest, "Digest": digest, "Mutable": true, "Tag": "22.04",
+			},
+		},
+		{
+			name:       "blob redirected to storage",
+			url:        storage,
+			redirected: registry + "/v2/library/ubuntu/blobs/" + digest,
+			// Storage may return its own digests.
+			header: map[string]string{"Docker-Content-Digest": "sha256:other"},
+			body:   content,
+			status: http.StatusOK,
+			uri:    "storage.example.com/blobs/aa",
+			annotations: map[string]any{
+				"Registry": "registry.example.com", "Repository": "library/ubuntu", "Type": "blob",
+				"Reference": digest, "Digest": digest, "Mutable": false,
+			},
+		},
+		{
+			name:   "digest mismatch",
+			url:    registry + "/v2/library/ubuntu/blobs/" + digest,
+			body:   "tampered",
+			status: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			h, err := New()
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			if tt.resolve {
+				req := httptest.NewRequest("HEAD", registry+"/v2/library/ubuntu/manifests/22.04", nil)
+				ctx := handler.Context{ID: 1, Req: req, Logger: logimpl.Logger{}}
+				if _, err := h.OnResponse(response(req, http.StatusOK, "", map[string]string{"Docker-Content-Digest": digest}), ctx); err != nil {
+					t.Fatalf("OnResponse: %v", err)
+				}
+			}
+			req := httptest.NewRequest("GET", tt.url, nil)
+			ctx := handler.Context{ID: 2, Req: req, Logger: logimpl.Logger{}}
+			if tt.redirected != "" {
+				origin := httptest.NewRequest("GET", tt.redirected, nil)
+				ctx.Redirects = []handler.Redirect{{Req: origin, StatusCode: http.StatusTemporaryRedirect}}
+			}
+			resp, err := h.OnResponse(response(req, http.StatusOK, tt.body, tt.header), ctx)
+			if err != nil {
+				t.Fatalf("OnResponse: %v", err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Fatalf("unexpected status: %d", resp.StatusCode)
+			}
+			deps, err := h.Dependencies(ctx)
+			if err != nil {
+				t.Fatalf("Dependencies: %v", err)
+			}
+			if tt.status != http.StatusOK {
+				if len(deps) != 0 {
+					t.Fatalf("unexpected dependencies: %v", deps)
+				}
+				return
+			}
+			if len(deps) != 1 {
+				t.Fatalf("unexpected dependencies: %v", deps)
+			}
+			if diff := cmp.Diff(tt.uri, deps[0].URI); diff != "" {
+				t.Fatalf("unexpected uri (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.annotations, deps[0].Annotations["OCI"]); diff != "" {
+				t.Fatalf("unexpected annotations (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_resolution(t *testing.T) {
+	t.Parallel()
+	h, err := New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	h.resolved("registry.example.com", "library/ubuntu", "old", "sha256:aa")
+	v, _ := h.tags.Load("registry.example.com/library/ubuntu@sha256:aa")
+	r := v.(resolution)
+	r.time = time.Now().Add(-2 * tagTimeout)
+	h.tags.Store("registry.example.com/library/ubuntu@sha256:aa", r)
+	// Stale resolutions are forgotten when a tag is resolved.
+	h.resolved("registry.example.com", "library/ubuntu", "new", "sha256:bb")
+	if _, ok := h.tags.Load("registry.example.com/library/ubuntu@sha256:aa"); ok {
+		t.Fatalf("stale resolution not forgotten")
+	}
+	if tag := h.resolution("registry.example.com", "library/ubuntu", "sha256:bb"); tag != "new" {
+		t.Fatalf("unexpected tag: %q", tag)
+	}
+	// Resolutions are used once.
+	if tag := h.resolution("registry.example.com", "library/ubuntu", "sha256:bb"); tag != "" {
+		t.Fatalf("unexpected tag: %q", tag)
+	}
+}
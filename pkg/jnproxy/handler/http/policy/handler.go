@@ -0,0 +1,86 @@
+package policy
+
+import (
+	"fmt"
+	"net/http"
+
+	handler "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+// Handler enforces a policy. It must be installed first in the chain:
+// - It refuses denied requests and claims the requests allowed unrecorded.
+// Other requests continue down the chain.
+// - As a response filter, it re-evaluates the policy with the content type
+// of the response and decides whether the dependencies are recorded.
+type Handler struct {
+	handler.HandlerImpl
+	policy *Policy
+}
+
+func New(p *Policy) (*Handler, error) {
+	if p == nil {
+		return nil, fmt.Errorf("nil policy")
+	}
+	self := &Handler{policy: p}
+	self.SetName("Policy/v0.1")
+	return self, nil
+}
+
+func (h *Handler) forbidden(req *http.Request, d decision, ctx handler.Context) *http.Response {
+	msg := fmt.Sprintf("[http/%s] rule (%q) %s (%q)", h.Name(), d.rule, d.action, req.Host+req.URL.Path)
+	if d.action == ActionRequirePinned {
+		msg += ": not pinned"
+	}
+	ctx.Logger.Infof(msg)
+	return handler.NewResponse(req, handler.ContentTypeText, http.StatusForbidden, msg)
+}
+
+func (h *Handler) OnRequest(req *http.Request, ctx handler.Context) (*http.Request, *http.Response, bool, error) {
+	d, ok := h.policy.decide(req, nil)
+	if !ok {
+		ctx.Logger.Debugf("[http/%s] decision (%q) deferred to response", h.Name(), req.Host+req.URL.Path)
+		return req, nil, false, nil
+	}
+	switch {
+	case d.action == ActionRequirePinned && !isPinned(req) && len(ctx.Redirects) > 0:
+		// The request the client originally made may be pinned,
+		// e.g. for CDN downloads: FilterResponse checks it.
+		ctx.Logger.Debugf("[http/%s] rule (%q) %s (%q) deferred to response", h.Name(), d.rule, d.action, req.Host+req.URL.Path)
+		return req, nil, false, nil
+	case d.action == ActionDeny,
+		d.action == ActionRequirePinned && !isPinned(req):
+		return req, h.forbidden(req, d, ctx), false, nil
+	case d.action == ActionAllowUnrecorded:
+		ctx.Logger.Infof("[http/%s] rule (%q) %s (%q)", h.Name(), d.rule, d.action, req.Host+req.URL.Path)
+		return req, nil, true, nil
+	}
+	ctx.Logger.Debugf("[http/%s] rule (%q) %s (%q)", h.Name(), d.rule, d.action, req.Host+req.URL.Path)
+	return req, nil, false, nil
+}
+
+// OnResponse relays the responses to requests allowed unrecorded.
+func (h *Handler) OnResponse(resp *http.Response, ctx handler.Context) (*http.Response, error) {
+	return resp, nil
+}
+
+func (h *Handler) FilterResponse(resp *http.Response, deps []slsa.ResourceDescriptor, ctx handler.Context) (*http.Response, bool, error) {
+	d, _ := h.policy.decide(ctx.Req, resp)
+	switch d.action {
+	case ActionDeny:
+		return h.forbidden(ctx.Req, d, ctx), false, nil
+	case ActionRequirePinned:
+		// A request may be pinned by the request the client originally made,
+		// e.g. for CDN downloads.
+		if !isPinned(ctx.Req) && !isPinned(ctx.Origin()) {
+			return h.forbidden(ctx.Req, d, ctx), false, nil
+		}
+	case ActionAllowUnrecorded:
+		if len(deps) > 0 {
+			ctx.Logger.Infof("[http/%s] rule (%q) %s (%q): dropping %d dependencies", h.Name(), d.rule, d.action, ctx.Req.Host+ctx.Req.URL.Path, len(deps))
+		}
+		return nil, false, nil
+	}
+	ctx.Logger.Infof("[http/%s] rule (%q) %s (%q): recording %d dependencies", h.Name(), d.rule, d.action, ctx.Req.Host+ctx.Req.URL.Path, len(deps))
+	return nil, true, nil
+}
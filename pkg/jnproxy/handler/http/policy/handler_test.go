@@ -0,0 +1,103 @@
+package policy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	handler "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http"
+	logimpl "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/logger"
+)
+
+func Test_Handler_requirePinned(t *testing.T) {
+	t.Parallel()
+	const policy = `
+default: deny
+rules:
+  - name: models
+    hosts: ["models.example.com", "cdn.example.com"]
+    action: require-pinned
+`
+	p, err := Parse(strings.NewReader(policy))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	const commit = "55eb2010aeaaa246defc329d42939e0253d55c99"
+	tests := []struct {
+		name      string
+		url       string
+		redirects []string
+		onRequest bool
+		denied    bool
+	}{
+		{
+			name: "pinned",
+			url:  "https://models.example.com/owner/name/" + commit + "/model.bin",
+		},
+		{
+			name:      "not pinned",
+			url:       "https://models.example.com/owner/name/main/model.bin",
+			onRequest: true,
+			denied:    true,
+		},
+		{
+			name:      "redirected from pinned",
+			url:       "https://cdn.example.com/objects/1234",
+			redirects: []string{"https://models.example.com/owner/name/" + commit + "/model.bin"},
+		},
+		{
+			name:      "redirected from not pinned",
+			url:       "https://cdn.example.com/objects/1234",
+			redirects: []string{"https://models.example.com/owner/name/main/model.bin"},
+			denied:    true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			h, err := New(p)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			req := httptest.NewRequest("GET", tt.url, nil)
+			ctx := handler.Context{ID: 1, Req: req, Logger: logimpl.Logger{}}
+			for _, u := range tt.redirects {
+				ctx.Redirects = append(ctx.Redirects, handler.Redirect{
+					Req:        httptest.NewRequest("GET", u, nil),
+					StatusCode: http.StatusFound,
+				})
+			}
+			_, resp, _, err := h.OnRequest(req, ctx)
+			if err != nil {
+				t.Fatalf("OnRequest: %v", err)
+			}
+			if tt.onRequest {
+				if resp == nil || resp.StatusCode != http.StatusForbidden {
+					t.Fatalf("request not denied: %v", resp)
+				}
+				return
+			}
+			if resp != nil {
+				t.Fatalf("unexpected OnRequest response: %d", resp.StatusCode)
+			}
+			resp, record, err := h.FilterResponse(handler.NewResponse(req, "application/octet-stream", http.StatusOK, "weights"), nil, ctx)
+			if err != nil {
+				t.Fatalf("FilterResponse: %v", err)
+			}
+			if tt.denied {
+				if resp == nil || resp.StatusCode != http.StatusForbidden {
+					t.Fatalf("response not denied: %v", resp)
+				}
+				return
+			}
+			if resp != nil {
+				t.Fatalf("unexpected FilterResponse response: %d", resp.StatusCode)
+			}
+			if !record {
+				t.Fatalf("dependencies not recorded")
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package policy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
+	handler "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+	"gopkg.in/yaml.v3"
+)
+
+// Action is the decision taken for the requests a rule matches.
+type Action string
+
+const (
+	// Allow the request and record the dependency.
+	ActionAllowAndRecord Action = "allow-and-record"
+	// Allow the request without recording a dependency.
+	ActionAllowUnrecorded Action = "allow-unrecorded"
+	// Refuse the request.
+	ActionDeny Action = "deny"
+	// Allow and record the request only if it pins its content,
+	// e.g. by commit or digest. Refuse it otherwise.
+	ActionRequirePinned Action = "require-pinned"
+)
+
+func (a Action) isValid() bool {
+	switch a {
+	case ActionAllowAndRecord, ActionAllowUnrecorded, ActionDeny, ActionRequirePinned:
+		return true
+	}
+	return false
+}
+
+// Policy is a list of rules evaluated in order. The first rule
+// that matches a request decides its action. Requests matched
+// by no rule get the default action.
+// Policies are written in YAML or JSON, e.g.:
+//
+//	default: deny
+//	rules:
+//	  - name: huggingface
+//	    hosts: ["huggingface.co", "cdn-lfs*.huggingface.co"]
+//	    methods: [GET, HEAD]
+//	    action: require-pinned
+//	  - name: pypi
+//	    hosts: ["pypi.org", "files.pythonhosted.org"]
+//	    action: allow-and-record
+type Policy struct {
+	Default Action `yaml:"default"`
+	Rules   []Rule `yaml:"rules"`
+}
+
+// Rule matches requests on all its non-empty fields. A field
+// matches if any of its values matches.
+// Hosts, paths and content types are globs: '*' and '?' do not match
+// the separator ('.' for hosts, '/' for paths and content types),
+// and '**' matches anything.
+type Rule struct {
+	Name         string   `yaml:"name"`
+	Hosts        []string `yaml:"hosts"`
+	Paths        []string `yaml:"paths"`
+	Methods      []string `yaml:"methods"`
+	Schemes      []string `yaml:"schemes"`
+	ContentTypes []string `yaml:"contentTypes"`
+	Action       Action   `yaml:"action"`
+
+	hosts        []*regexp.Regexp
+	paths        []*regexp.Regexp
+	contentTypes []*regexp.Regexp
+}
+
+// Parse reads a policy in YAML or JSON format and validates it.
+func Parse(r io.Reader) (*Policy, error) {
+	var p Policy
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&p); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%w: empty policy", errs.ErrorInvalid)
+		}
+		return nil, fmt.Errorf("%w: decode: %w", errs.ErrorInvalid, err)
+	}
+	if err := p.compile(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (p *Policy) compile() error {
+	if !p.Default.isValid() {
+		return fmt.Errorf("%w: default action (%q)", errs.ErrorInvalid, p.Default)
+	}
+	names := make(map[string]bool)
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if r.Name == "" {
+			return fmt.Errorf("%w: rule #%d has no name", errs.ErrorInvalid, i)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("%w: duplicate rule (%q)", errs.ErrorInvalid, r.Name)
+		}
+		names[r.Name] = true
+		if !r.Action.isValid() {
+			return fmt.Errorf("%w: rule (%q) action (%q)", errs.ErrorInvalid, r.Name, r.Action)
+		}
+		var err error
+		if r.hosts, err = compileGlobs(r.Hosts, '.', true); err != nil {
+			return fmt.Errorf("%w: rule (%q) hosts: %w", errs.ErrorInvalid, r.Name, err)
+		}
+		if r.paths, err = compileGlobs(r.Paths, '/', false); err != nil {
+			return fmt.Errorf("%w: rule (%q) paths: %w", errs.ErrorInvalid, r.Name, err)
+		}
+		if r.contentTypes, err = compileGlobs(r.ContentTypes, '/', true); err != nil {
+			return fmt.Errorf("%w: rule (%q) content types: %w", errs.ErrorInvalid, r.Name, err)
+		}
+	}
+	return nil
+}
+
+func compileGlobs(globs []string, sep byte, fold bool) ([]*regexp.Regexp, error) {
+	var res []*regexp.Regexp
+	for _, g := range globs {
+		if g == "" {
+			return nil, fmt.Errorf("empty glob")
+		}
+		re, err := regexp.Compile(globToRegexp(g, sep, fold))
+		if err != nil {
+			return nil, fmt.Errorf("glob (%q): %w", g, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+func globToRegexp(glob string, sep byte, fold bool) string {
+	var sb strings.Builder
+	if fold {
+		sb.WriteString("(?i)")
+	}
+	sb.WriteString("^")
+	notSep := "[^" + regexp.QuoteMeta(string(sep)) + "]"
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case c == '*' && i+1 < len(glob) && glob[i+1] == '*':
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString(notSep + "*")
+		case c == '?':
+			sb.WriteString(notSep)
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return sb.String()
+}
+
+func matchGlobs(res []*regexp.Regexp, s string) bool {
+	if len(res) == 0 {
+		return true
+	}
+	for _, re := range res {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchFold(values []string, s string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRequest returns true if the request matches the fields of the rule
+// that do not depend on the response.
+func (r *Rule) matchRequest(req *http.Request) bool {
+	return matchGlobs(r.hosts, handler.Hostname(req)) &&
+		matchGlobs(r.paths, req.URL.Path) &&
+		matchFold(r.Methods, req.Method) &&
+		matchFold(r.Schemes, req.URL.Scheme)
+}
+
+func (r *Rule) matchResponse(resp *http.Response) bool {
+	if len(r.contentTypes) == 0 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return matchGlobs(r.contentTypes, mediaType)
+}
+
+const defaultRule = "default"
+
+type decision struct {
+	rule   string
+	action Action
+}
+
+// decide returns the action for req. At request time, resp is nil
+// and decide returns false if the action depends on the response.
+func (p *Policy) decide(req *http.Request, resp *http.Response) (decision, bool) {
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if !r.matchRequest(req) {
+			continue
+		}
+		if resp == nil && len(r.contentTypes) > 0 {
+			return decision{}, false
+		}
+		if resp != nil && !r.matchResponse(resp) {
+			continue
+		}
+		return decision{rule: r.Name, action: r.Action}, true
+	}
+	return decision{rule: defaultRule, action: p.Default}, true
+}
+
+var (
+	// Full git commits, sha256 digests and OCI digests.
+	pinnedPath = regexp.MustCompile(`(^|[/@=:])([0-9a-f]{40}|[0-9a-f]{64})($|[/?#.\-_])`)
+	// Object versions of S3 and GCS.
+	pinnedQuery = []string{"versionId", "generation"}
+)
+
+// isPinned returns true if the request identifies immutable content.
+func isPinned(req *http.Request) bool {
+	if pinnedPath.MatchString(strings.ToLower(req.URL.Path)) {
+		return true
+	}
+	q := req.URL.Query()
+	for _, k := range pinnedQuery {
+		if q.Get(k) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Digest returns the digest of the JSON representation of the policy,
+// which identifies the policy independently of its formatting.
+func (p *Policy) Digest() (slsa.DigestSet, error) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("marshal: %w", err)
+	}
+	h := sha256.Sum256(b)
+	return slsa.DigestSet{"sha256": hex.EncodeToString(h[:])}, nil
+}
@@ -0,0 +1,232 @@
+package policy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
+)
+
+func Test_Parse(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		policy   string
+		expected error
+	}{
+		{
+			name: "yaml",
+			policy: `
+default: deny
+rules:
+  - name: hf
+    hosts: ["huggingface.co", "*.huggingface.co"]
+    methods: [GET, HEAD]
+    action: require-pinned
+`,
+		},
+		{
+			name:   "json",
+			policy: `{"default": "allow-and-record", "rules": [{"name": "pypi", "hosts": ["pypi.org"], "action": "allow-unrecorded"}]}`,
+		},
+		{
+			name:     "empty",
+			policy:   "",
+			expected: errs.ErrorInvalid,
+		},
+		{
+			name:     "no default",
+			policy:   "rules: []",
+			expected: errs.ErrorInvalid,
+		},
+		{
+			name:     "unknown field",
+			policy:   "default: deny\nhost: bla",
+			expected: errs.ErrorInvalid,
+		},
+		{
+			name: "invalid action",
+			policy: `
+default: deny
+rules:
+  - name: bla
+    action: allow
+`,
+			expected: errs.ErrorInvalid,
+		},
+		{
+			name: "no name",
+			policy: `
+default: deny
+rules:
+  - action: deny
+`,
+			expected: errs.ErrorInvalid,
+		},
+		{
+			name: "duplicate name",
+			policy: `
+default: deny
+rules:
+  - name: bla
+    action: deny
+  - name: bla
+    action: deny
+`,
+			expected: errs.ErrorInvalid,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := Parse(strings.NewReader(tt.policy))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_decide(t *testing.T) {
+	t.Parallel()
+	const policy = `
+default: deny
+rules:
+  - name: hf-api
+    hosts: ["huggingface.co"]
+    paths: ["/api/**"]
+    action: allow-unrecorded
+  - name: hf
+    hosts: ["huggingface.co", "cdn-lfs*.huggingface.co"]
+    methods: [GET, HEAD]
+    schemes: [https]
+    action: require-pinned
+  - name: images
+    hosts: ["*.example.com"]
+    contentTypes: ["image/*"]
+    action: allow-unrecorded
+  - name: example
+    hosts: ["**.example.com"]
+    action: allow-and-record
+`
+	p, err := Parse(strings.NewReader(policy))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	const commit = "55eb2010aeaaa246defc329d42939e0253d55c99"
+	tests := []struct {
+		name        string
+		method      string
+		url         string
+		contentType string
+		request     *decision
+		response    decision
+		pinned      bool
+	}{
+		{
+			name:     "api",
+			method:   "GET",
+			url:      "https://huggingface.co:443/api/models/owner/name",
+			request:  &decision{rule: "hf-api", action: ActionAllowUnrecorded},
+			response: decision{rule: "hf-api", action: ActionAllowUnrecorded},
+		},
+		{
+			name:     "pinned",
+			method:   "GET",
+			url:      "https://huggingface.co/owner/name/resolve/" + commit + "/config.json",
+			request:  &decision{rule: "hf", action: ActionRequirePinned},
+			response: decision{rule: "hf", action: ActionRequirePinned},
+			pinned:   true,
+		},
+		{
+			name:     "not pinned",
+			method:   "HEAD",
+			url:      "https://huggingface.co/owner/name/resolve/main/config.json",
+			request:  &decision{rule: "hf", action: ActionRequirePinned},
+			response: decision{rule: "hf", action: ActionRequirePinned},
+		},
+		{
+			name:     "cdn",
+			method:   "GET",
+			url:      "https://cdn-lfs-us-1.huggingface.co/repos/96/8b/" + strings.Repeat("ab", 32) + "?X-Amz-Expires=10",
+			request:  &decision{rule: "hf", action: ActionRequirePinned},
+			response: decision{rule: "hf", action: ActionRequirePinned},
+			pinned:   true,
+		},
+		{
+			name:     "method not matching",
+			method:   "POST",
+			url:      "https://huggingface.co/owner/name/resolve/main/config.json",
+			request:  &decision{rule: defaultRule, action: ActionDeny},
+			response: decision{rule: defaultRule, action: ActionDeny},
+		},
+		{
+			name:     "scheme not matching",
+			method:   "GET",
+			url:      "http://huggingface.co/owner/name/resolve/main/config.json",
+			request:  &decision{rule: defaultRule, action: ActionDeny},
+			response: decision{rule: defaultRule, action: ActionDeny},
+		},
+		{
+			name:        "content type",
+			method:      "GET",
+			url:         "https://www.example.com/logo.png",
+			contentType: "image/png; charset=binary",
+			response:    decision{rule: "images", action: ActionAllowUnrecorded},
+		},
+		{
+			name:        "content type not matching",
+			method:      "GET",
+			url:         "https://www.example.com/data.csv",
+			contentType: "text/csv",
+			response:    decision{rule: "example", action: ActionAllowAndRecord},
+		},
+		{
+			name:     "double star",
+			method:   "GET",
+			url:      "https://a.b.example.com/data.csv",
+			request:  &decision{rule: "example", action: ActionAllowAndRecord},
+			response: decision{rule: "example", action: ActionAllowAndRecord},
+		},
+		{
+			name:     "s3 version",
+			method:   "GET",
+			url:      "https://bucket.s3.amazonaws.com/key?versionId=3",
+			request:  &decision{rule: defaultRule, action: ActionDeny},
+			response: decision{rule: defaultRule, action: ActionDeny},
+			pinned:   true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(tt.method, tt.url, nil)
+			d, ok := p.decide(req, nil)
+			if diff := cmp.Diff(tt.request != nil, ok); diff != "" {
+				t.Fatalf("unexpected request decision (-want +got): \n%s", diff)
+			}
+			if ok {
+				if diff := cmp.Diff(*tt.request, d, cmp.AllowUnexported(decision{})); diff != "" {
+					t.Fatalf("unexpected request decision (-want +got): \n%s", diff)
+				}
+			}
+			resp := &http.Response{Header: http.Header{}}
+			if tt.contentType != "" {
+				resp.Header.Set("Content-Type", tt.contentType)
+			}
+			d, _ = p.decide(req, resp)
+			if diff := cmp.Diff(tt.response, d, cmp.AllowUnexported(decision{})); diff != "" {
+				t.Fatalf("unexpected response decision (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.pinned, isPinned(req)); diff != "" {
+				t.Fatalf("unexpected pinned (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
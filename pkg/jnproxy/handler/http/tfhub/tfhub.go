@@ -0,0 +1,120 @@
+package tfhub
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	handler "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+type Hub struct {
+	handler.HandlerImpl
+}
+
+func New() (*Hub, error) {
+	self := &Hub{}
+	self.SetName("TensorflowHub/v0.1")
+	return self, nil
+}
+
+// Compressed modules are served from buckets, e.g.
+// https://tfhub.dev/google/imagenet/mobilenet_v2_100_224/feature_vector/5?tf-hub-format=compressed
+// redirects to
+// https://storage.googleapis.com/tfhub-modules/google/imagenet/mobilenet_v2_100_224/feature_vector/5.tar.gz
+// See https://www.tensorflow.org/hub/hosting.
+var buckets = map[string]string{
+	"/tfhub-modules/":      ".tar.gz",
+	"/tfhub-lite-models/":  ".tflite",
+	"/tfhub-tfjs-modules/": ".tar.gz",
+}
+
+// parseHandle returns the module handle {publisher}/.../{model}/{version}
+// and its format.
+func parseHandle(host, absPath string) (string, string, bool) {
+	var handle, format string
+	switch host {
+	case "tfhub.dev":
+		handle = strings.TrimPrefix(absPath, "/")
+	case "storage.googleapis.com":
+		for prefix, ext := range buckets {
+			if h, ok := strings.CutPrefix(absPath, prefix); ok {
+				handle = strings.TrimSuffix(h, ext)
+				format = strings.TrimPrefix(ext, ".")
+				break
+			}
+		}
+	}
+	// The handle ends with a version number.
+	parts := strings.Split(handle, "/")
+	if len(parts) < 3 {
+		return "", "", false
+	}
+	if _, err := strconv.ParseUint(parts[len(parts)-1], 10, 64); err != nil {
+		return "", "", false
+	}
+	return handle, format, true
+}
+
+func (h *Hub) OnRequest(req *http.Request, ctx handler.Context) (*http.Request, *http.Response, bool, error) {
+	absPath, err := handler.AbsURLPath(req.URL.Path)
+	if err != nil {
+		msg := fmt.Sprintf("[http/%s] %v", h.Name(), err)
+		ctx.Logger.Errorf(msg)
+		return req, handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), false, nil
+	}
+	_, _, interested := parseHandle(req.Host, absPath)
+	return req, nil, interested, nil
+}
+
+func (h *Hub) OnResponse(resp *http.Response, ctx handler.Context) (*http.Response, error) {
+	b, err := handler.ReadBody(resp)
+	if err != nil {
+		msg := fmt.Sprintf("[http/%s] %v", h.Name(), err)
+		ctx.Logger.Errorf(msg)
+		return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
+	}
+	ctx.Logger.Debugf("[http]: received (%q %q):\nHeader:\n%q", ctx.Req.Method, ctx.Req.Host+ctx.Req.URL.Path, resp.Header)
+	// NOTE: tfhub.dev redirects to the bucket, so there's nothing to record
+	// for the redirect itself.
+	if ctx.Req.Method == "HEAD" || resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+	absPath, err := handler.AbsURLPath(ctx.Req.URL.Path)
+	if err != nil {
+		msg := fmt.Sprintf("[http/%s] %v", h.Name(), err)
+		ctx.Logger.Errorf(msg)
+		return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
+	}
+	handle, format, ok := parseHandle(ctx.Req.Host, absPath)
+	if !ok {
+		msg := fmt.Sprintf("[http/%s] invalid path (%q)", h.Name(), absPath)
+		ctx.Logger.Errorf(msg)
+		return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
+	}
+	if format == "" {
+		format = ctx.Req.URL.Query().Get("tf-hub-format")
+	}
+	rd, err := handler.NewResourceDescriptor(resp, b, ctx, h.Name())
+	if err != nil {
+		msg := fmt.Sprintf("[http/%s] %v", h.Name(), err)
+		ctx.Logger.Errorf(msg)
+		return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
+	}
+	hubAnnotations := map[string]any{
+		"Kind":    "model",
+		"Handle":  handle,
+		"Version": handle[strings.LastIndex(handle, "/")+1:],
+	}
+	if format != "" {
+		hubAnnotations["Format"] = format
+	}
+	rd.Name = "https://tfhub.dev/" + handle
+	rd.Annotations["TensorflowHub"] = hubAnnotations
+	rd.SetDependency(slsa.KindModel, slsa.EcosystemTensorflowHub)
+	h.Store(ctx.ID, rd)
+	ctx.Logger.Debugf("[http]: RD %q", rd)
+	return resp, nil
+}
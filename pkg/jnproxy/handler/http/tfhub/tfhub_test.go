@@ -0,0 +1,79 @@
+package tfhub
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_parseHandle(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		host   string
+		path   string
+		handle string
+		format string
+		ok     bool
+	}{
+		{
+			name:   "tfhub",
+			host:   "tfhub.dev",
+			path:   "/google/imagenet/mobilenet_v2_100_224/feature_vector/5",
+			handle: "google/imagenet/mobilenet_v2_100_224/feature_vector/5",
+			ok:     true,
+		},
+		{
+			name:   "module bucket",
+			host:   "storage.googleapis.com",
+			path:   "/tfhub-modules/google/imagenet/mobilenet_v2_100_224/feature_vector/5.tar.gz",
+			handle: "google/imagenet/mobilenet_v2_100_224/feature_vector/5",
+			format: "tar.gz",
+			ok:     true,
+		},
+		{
+			name:   "lite bucket",
+			host:   "storage.googleapis.com",
+			path:   "/tfhub-lite-models/google/lite-model/aiy/vision/classifier/birds_V1/3.tflite",
+			handle: "google/lite-model/aiy/vision/classifier/birds_V1/3",
+			format: "tflite",
+			ok:     true,
+		},
+		{
+			name: "no version",
+			host: "tfhub.dev",
+			path: "/google/imagenet/mobilenet_v2_100_224/feature_vector",
+		},
+		{
+			name: "too short",
+			host: "tfhub.dev",
+			path: "/google/5",
+		},
+		{
+			name: "other bucket",
+			host: "storage.googleapis.com",
+			path: "/other/google/imagenet/mobilenet/5.tar.gz",
+		},
+		{
+			name: "other host",
+			host: "example.com",
+			path: "/google/imagenet/mobilenet/5",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			handle, format, ok := parseHandle(tt.host, tt.path)
+			if ok != tt.ok {
+				t.Fatalf("unexpected ok: %v", ok)
+			}
+			if diff := cmp.Diff(tt.handle, handle); diff != "" {
+				t.Fatalf("unexpected handle (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.format, format); diff != "" {
+				t.Fatalf("unexpected format (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package torchhub
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	handler "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+type Hub struct {
+	handler.HandlerImpl
+}
+
+func New() (*Hub, error) {
+	self := &Hub{}
+	self.SetName("PytorchHub/v0.1")
+	return self, nil
+}
+
+type resource struct {
+	// Pre-trained weights, e.g. models/resnet18-f37072fd.pth.
+	file string
+	// Hub repositories, e.g. pytorch/vision at ref main.
+	repo string
+	ref  string
+}
+
+// torch.hub sets its User-Agent on downloads, and urllib keeps it
+// when following redirects.
+const userAgent = "torch.hub"
+
+// parse recognizes:
+// - https://download.pytorch.org/models/{file}
+// - https://github.com/{owner}/{repo}/zipball/{ref}, used by torch.hub.load('{owner}/{repo}:{ref}')
+// - https://github.com/{owner}/{repo}/archive/{ref}.zip, used by older torch.hub versions
+// - https://codeload.github.com/{owner}/{repo}/{legacy.zip,zip}/{ref}, where the above redirect to
+// GitHub archives are downloaded by other clients too, e.g. pip,
+// so they are only recognized when requested by torch.hub.
+// See https://github.com/pytorch/pytorch/blob/main/torch/hub.py.
+func parse(host, absPath, agent string) (*resource, bool) {
+	switch host {
+	case "download.pytorch.org":
+		if !strings.HasPrefix(absPath, "/models/") || strings.HasSuffix(absPath, "/") {
+			return nil, false
+		}
+		return &resource{file: strings.TrimPrefix(absPath, "/")}, true
+	case "github.com", "codeload.github.com":
+		if agent != userAgent {
+			return nil, false
+		}
+		parts := strings.SplitN(strings.TrimPrefix(absPath, "/"), "/", 4)
+		if len(parts) != 4 || parts[3] == "" {
+			return nil, false
+		}
+		r := &resource{repo: parts[0] + "/" + parts[1], ref: parts[3]}
+		switch {
+		case host == "github.com" && parts[2] == "zipball":
+		case host == "github.com" && parts[2] == "archive" && strings.HasSuffix(r.ref, ".zip"):
+			r.ref = strings.TrimSuffix(r.ref, ".zip")
+		case host == "codeload.github.com" && (parts[2] == "legacy.zip" || parts[2] == "zip"):
+		default:
+			return nil, false
+		}
+		return r, true
+	}
+	return nil, false
+}
+
+// hashPrefix returns the sha256 prefix torch.hub embeds in file names,
+// e.g. f37072fd for resnet18-f37072fd.pth.
+// See https://pytorch.org/docs/stable/hub.html#torch.hub.download_url_to_file.
+func hashPrefix(file string) string {
+	base := path.Base(file)
+	base = strings.TrimSuffix(base, path.Ext(base))
+	i := strings.LastIndex(base, "-")
+	if i < 0 {
+		return ""
+	}
+	prefix := base[i+1:]
+	if len(prefix) < 8 {
+		return ""
+	}
+	for _, c := range prefix {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return ""
+		}
+	}
+	return prefix
+}
+
+func (h *Hub) OnRequest(req *http.Request, ctx handler.Context) (*http.Request, *http.Response, bool, error) {
+	absPath, err := handler.AbsURLPath(req.URL.Path)
+	if err != nil {
+		msg := fmt.Sprintf("[http/%s] %v", h.Name(), err)
+		ctx.Logger.Errorf(msg)
+		return req, handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), false, nil
+	}
+	_, interested := parse(req.Host, absPath, req.UserAgent())
+	return req, nil, interested, nil
+}
+
+func (h *Hub) OnResponse(resp *http.Response, ctx handler.Context) (*http.Response, error) {
+	b, err := handler.ReadBody(resp)
+	if err != nil {
+		msg := fmt.Sprintf("[http/%s] %v", h.Name(), err)
+		ctx.Logger.Errorf(msg)
+		return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
+	}
+	ctx.Logger.Debugf("[http]: received (%q %q):\nHeader:\n%q", ctx.Req.Method, ctx.Req.Host+ctx.Req.URL.Path, resp.Header)
+	// NOTE: github.com redirects to codeload.github.com, so there's nothing to record
+	// for the redirect itself.
+	if ctx.Req.Method == "HEAD" || resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+	// Archives are identified by the request to github.com,
+	// rather than by the codeload.github.com URL it redirects to.
+	var r *resource
+	var ok bool
+	var absPath string
+	for _, req := range []*http.Request{ctx.Origin(), ctx.Req} {
+		absPath, err = handler.AbsURLPath(req.URL.Path)
+		if err != nil {
+			msg := fmt.Sprintf("[http/%s] %v", h.Name(), err)
+			ctx.Logger.Errorf(msg)
+			return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
+		}
+		if r, ok = parse(req.Host, absPath, req.UserAgent()); ok {
+			break
+		}
+	}
+	if !ok {
+		msg := fmt.Sprintf("[http/%s] invalid path (%q)", h.Name(), absPath)
+		ctx.Logger.Errorf(msg)
+		return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
+	}
+	rd, err := handler.NewResourceDescriptor(resp, b, ctx, h.Name())
+	if err != nil {
+		msg := fmt.Sprintf("[http/%s] %v", h.Name(), err)
+		ctx.Logger.Errorf(msg)
+		return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, msg), nil
+	}
+	hubAnnotations := map[string]any{}
+	// Hub repositories contain the code that loads the models.
+	kind := slsa.KindCode
+	if r.file != "" {
+		kind = slsa.KindModel
+		hubAnnotations["File"] = r.file
+		rd.Name = "https://download.pytorch.org/" + r.file
+		// torch.hub verifies the prefix on load only if asked to, and
+		// file names may end with hex characters that are not a prefix,
+		// e.g. resnet-20210101.pth. We record the result of the check.
+		if prefix := hashPrefix(r.file); prefix != "" {
+			match := strings.HasPrefix(rd.DigestSet["sha256"], prefix)
+			if !match {
+				ctx.Logger.Warnf("[http/%s] file (%q) digest (%v) does not start with (%v)", h.Name(), r.file, rd.DigestSet["sha256"], prefix)
+			}
+			hubAnnotations["HashPrefix"] = map[string]any{
+				"Prefix": prefix,
+				"Match":  match,
+			}
+		}
+	} else {
+		hubAnnotations["Repo"] = r.repo
+		hubAnnotations["Ref"] = r.ref
+		rd.Name = "github.com/" + r.repo + "@" + r.ref
+	}
+	hubAnnotations["Kind"] = string(kind)
+	rd.SetDependency(kind, slsa.EcosystemPytorchHub)
+	rd.Annotations["PytorchHub"] = hubAnnotations
+	h.Store(ctx.ID, rd)
+	ctx.Logger.Debugf("[http]: RD %q", rd)
+	return resp, nil
+}
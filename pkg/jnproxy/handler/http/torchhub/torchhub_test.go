@@ -0,0 +1,191 @@
+package torchhub
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	handler "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http"
+	logimpl "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/logger"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+func Test_parse(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		host     string
+		path     string
+		agent    string
+		resource *resource
+	}{
+		{
+			name:     "weights",
+			host:     "download.pytorch.org",
+			path:     "/models/resnet18-f37072fd.pth",
+			resource: &resource{file: "models/resnet18-f37072fd.pth"},
+		},
+		{
+			name:     "zipball",
+			host:     "github.com",
+			path:     "/pytorch/vision/zipball/main",
+			agent:    userAgent,
+			resource: &resource{repo: "pytorch/vision", ref: "main"},
+		},
+		{
+			name:     "archive",
+			host:     "github.com",
+			path:     "/pytorch/vision/archive/v0.10.0.zip",
+			agent:    userAgent,
+			resource: &resource{repo: "pytorch/vision", ref: "v0.10.0"},
+		},
+		{
+			name:     "codeload",
+			host:     "codeload.github.com",
+			path:     "/pytorch/vision/legacy.zip/refs/heads/main",
+			agent:    userAgent,
+			resource: &resource{repo: "pytorch/vision", ref: "refs/heads/main"},
+		},
+		{
+			name:  "archive without zip",
+			host:  "github.com",
+			path:  "/pytorch/vision/archive/v0.10.0.tar.gz",
+			agent: userAgent,
+		},
+		{
+			name:  "zipball from other client",
+			host:  "github.com",
+			path:  "/pytorch/vision/zipball/main",
+			agent: "pip/24.0",
+		},
+		{
+			name:  "github page",
+			host:  "github.com",
+			path:  "/pytorch/vision/blob/main/hubconf.py",
+			agent: userAgent,
+		},
+		{
+			name: "models directory",
+			host: "download.pytorch.org",
+			path: "/models/",
+		},
+		{
+			name: "other host",
+			host: "example.com",
+			path: "/models/resnet18-f37072fd.pth",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r, ok := parse(tt.host, tt.path, tt.agent)
+			if ok != (tt.resource != nil) {
+				t.Fatalf("unexpected ok: %v", ok)
+			}
+			if diff := cmp.Diff(tt.resource, r, cmp.AllowUnexported(resource{})); diff != "" {
+				t.Fatalf("unexpected resource (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_hashPrefix(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		file   string
+		prefix string
+	}{
+		{file: "models/resnet18-f37072fd.pth", prefix: "f37072fd"},
+		{file: "models/resnet-20210101.pth", prefix: "20210101"},
+		{file: "models/resnet18.pth"},
+		{file: "models/resnet18-abc.pth"},
+		{file: "models/resnet18-f37072fz.pth"},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.file, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(tt.prefix, hashPrefix(tt.file)); diff != "" {
+				t.Fatalf("unexpected prefix (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_OnResponse(t *testing.T) {
+	t.Parallel()
+	const content = "weights"
+	digest := handler.SHA256([]byte(content))
+	tests := []struct {
+		name        string
+		url         string
+		kind        slsa.DependencyKind
+		annotations map[string]any
+	}{
+		{
+			name: "weights with prefix",
+			url:  "https://download.pytorch.org/models/resnet18-" + digest[:8] + ".pth",
+			kind: slsa.KindModel,
+			annotations: map[string]any{
+				"Kind":       "model",
+				"File":       "models/resnet18-" + digest[:8] + ".pth",
+				"HashPrefix": map[string]any{"Prefix": digest[:8], "Match": true},
+			},
+		},
+		{
+			name: "weights with date",
+			url:  "https://download.pytorch.org/models/resnet-20210101.pth",
+			kind: slsa.KindModel,
+			annotations: map[string]any{
+				"Kind":       "model",
+				"File":       "models/resnet-20210101.pth",
+				"HashPrefix": map[string]any{"Prefix": "20210101", "Match": false},
+			},
+		},
+		{
+			name: "repository",
+			url:  "https://github.com/pytorch/vision/zipball/main",
+			kind: slsa.KindCode,
+			annotations: map[string]any{
+				"Kind": "code",
+				"Repo": "pytorch/vision",
+				"Ref":  "main",
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			h, err := New()
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			req := httptest.NewRequest("GET", tt.url, nil)
+			req.Header.Set("User-Agent", userAgent)
+			ctx := handler.Context{ID: 1, Req: req, Logger: logimpl.Logger{}}
+			resp, err := h.OnResponse(handler.NewResponse(req, "application/octet-stream", http.StatusOK, content), ctx)
+			if err != nil {
+				t.Fatalf("OnResponse: %v", err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("unexpected status: %d", resp.StatusCode)
+			}
+			deps, err := h.Dependencies(ctx)
+			if err != nil {
+				t.Fatalf("Dependencies: %v", err)
+			}
+			if len(deps) != 1 {
+				t.Fatalf("unexpected dependencies: %v", deps)
+			}
+			if kind, _, _ := deps[0].Dependency(); kind != tt.kind {
+				t.Fatalf("unexpected kind: %q", kind)
+			}
+			if diff := cmp.Diff(tt.annotations, deps[0].Annotations["PytorchHub"]); diff != "" {
+				t.Fatalf("unexpected annotations (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
@@ -1,8 +1,17 @@
 package http
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
 )
 
 // TODO(#12): Sanitize the URL path
@@ -14,3 +23,96 @@ func AbsURLPath(p string) (string, error) {
 	base, _ := url.Parse("")
 	return base.ResolveReference(u).Path, nil
 }
+
+// Hostname returns the lower-case host of req, without port.
+// Requests in the MITM proxy have the host in the URL,
+// others only in the Host header.
+func Hostname(req *http.Request) string {
+	if h := req.URL.Hostname(); h != "" {
+		return strings.ToLower(h)
+	}
+	host := req.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
+
+// ReadBody reads the entire response body and replaces it
+// with an in-memory copy, so that the response can still be relayed to the client.
+func ReadBody(resp *http.Response) ([]byte, error) {
+	if resp.Body == nil {
+		return nil, nil
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+	return b, nil
+}
+
+// ReadRequestBody reads the entire request body and replaces it
+// with an in-memory copy, so that the request can still be sent upstream.
+func ReadRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	b, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+	return b, nil
+}
+
+// SHA256 returns the hex-encoded sha256 digest of b.
+func SHA256(b []byte) string {
+	hash := sha256.New()
+	hash.Write(b)
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
+
+// NewResourceDescriptor returns a descriptor for the content b
+// of the response to ctx.Req. It verifies the Content-Length header,
+// if present, and records the sha256 digest of the content.
+// WARNING: GET parameters are not recorded.
+func NewResourceDescriptor(resp *http.Response, b []byte, ctx Context, handlerName string) (slsa.ResourceDescriptor, error) {
+	header := resp.Header
+	var hLen int
+	contentLen := header.Get("Content-Length")
+	if contentLen != "" {
+		var err error
+		hLen, err = strconv.Atoi(contentLen)
+		if err != nil {
+			return slsa.ResourceDescriptor{}, fmt.Errorf("conversion to int: %w", err)
+		}
+		if hLen != len(b) {
+			return slsa.ResourceDescriptor{}, fmt.Errorf("length mismatch. Header (%v) != actual (%v)", hLen, len(b))
+		}
+	}
+	contentType := header.Get("Content-Type")
+	aLen64 := uint64(len(b))
+	return slsa.ResourceDescriptor{
+		DownloadLocation: ctx.Req.URL.Host + ctx.Req.URL.Path,
+		URI:              ctx.Req.URL.Host + ctx.Req.URL.Path,
+		MediaType:        contentType,
+		ContentLength:    &aLen64,
+		DigestSet: slsa.DigestSet{
+			"sha256": SHA256(b),
+		},
+		Annotations: map[string]any{
+			// NOTE: No header recorded.
+			"Handler": handlerName,
+			"HTTP": map[string]any{
+				"Method": ctx.Req.Method,
+				"Header": map[string]any{
+					"Content-Length": hLen,
+					"Content-Type":   contentType,
+				},
+			},
+		},
+	}, nil
+}
@@ -1,6 +1,8 @@
 package http
 
 import (
+	"net/http"
+	"net/url"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -53,3 +55,51 @@ func Test_AbsURLPath(t *testing.T) {
 		})
 	}
 }
+
+func Test_Hostname(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		url      string
+		host     string
+		hostname string
+	}{
+		{
+			name:     "mitm url with port",
+			url:      "https://Example.com:443/path",
+			hostname: "example.com",
+		},
+		{
+			name:     "host header with port",
+			url:      "/path",
+			host:     "Example.com:8080",
+			hostname: "example.com",
+		},
+		{
+			name:     "host header without port",
+			url:      "/path",
+			host:     "example.com",
+			hostname: "example.com",
+		},
+		{
+			name:     "ipv6",
+			url:      "/path",
+			host:     "[::1]:8080",
+			hostname: "::1",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatalf("url parse: %v", err)
+			}
+			req := &http.Request{URL: u, Host: tt.host}
+			if diff := cmp.Diff(tt.hostname, Hostname(req)); diff != "" {
+				t.Fatalf("unexpected hostname (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
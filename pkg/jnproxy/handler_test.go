@@ -0,0 +1,70 @@
+package jnproxy
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http/deny"
+)
+
+func Test_InstallDenyHandler(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		options  [][]deny.Option
+		expected error
+	}{
+		{
+			name: "single",
+			options: [][]deny.Option{
+				{deny.WithHosts("example.com")},
+			},
+		},
+		{
+			name: "named",
+			options: [][]deny.Option{
+				{deny.WithName("DenyHosts/v0.1"), deny.WithHosts("example.com")},
+				{deny.WithName("DenyPaths/v0.1"), deny.WithPathPrefixes("/admin/"), deny.WithMessage("No admin")},
+			},
+		},
+		{
+			name: "duplicate names",
+			options: [][]deny.Option{
+				{deny.WithHosts("example.com")},
+				{deny.WithPathPrefixes("/admin/")},
+			},
+			expected: errs.ErrorInvalid,
+		},
+		{
+			name: "unversioned name",
+			options: [][]deny.Option{
+				{deny.WithName("DenyHosts")},
+			},
+			expected: errs.ErrorInvalid,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			p := &JNProxy{}
+			var err error
+			for _, opts := range tt.options {
+				if err = InstallDenyHandler(opts...)(p); err != nil {
+					break
+				}
+			}
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if len(p.httpHandlers) != len(tt.options) {
+				t.Fatalf("unexpected handlers: %v", p.httpHandlers)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package bom
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+// Component is a subject or a dependency of the session,
+// as listed in bills of materials.
+type Component struct {
+	// Ref identifies the component in the document.
+	Ref       string
+	Name      string
+	Version   string
+	PURL      string
+	URI       string
+	Kind      slsa.DependencyKind
+	Ecosystem string
+	DigestSet slsa.DigestSet
+	// Handler that recorded the dependency.
+	Handler string
+}
+
+// Subjects returns the components of the subjects, i.e. the outputs.
+func Subjects(subjects []slsa.Subject) []Component {
+	res := make([]Component, 0, len(subjects))
+	for i, s := range subjects {
+		res = append(res, Component{
+			Ref:       fmt.Sprintf("subject-%d", i),
+			Name:      s.Name,
+			Kind:      slsa.KindFile,
+			DigestSet: s.DigestSet,
+		})
+	}
+	return res
+}
+
+// Dependencies returns the components of the dependencies.
+// Dependencies without a kind, like the lineage repository,
+// are files.
+func Dependencies(deps []slsa.ResourceDescriptor) []Component {
+	res := make([]Component, 0, len(deps))
+	for i := range deps {
+		rd := &deps[i]
+		c := Component{
+			Ref:       fmt.Sprintf("dependency-%d", i),
+			Name:      rd.Name,
+			URI:       rd.URI,
+			Kind:      slsa.KindFile,
+			DigestSet: rd.DigestSet,
+		}
+		if kind, ecosystem, ok := rd.Dependency(); ok {
+			c.Kind, c.Ecosystem = kind, ecosystem
+		}
+		// Some handlers name dependencies after their purl.
+		if strings.HasPrefix(rd.Name, "pkg:") {
+			c.PURL = rd.Name
+			c.Name = ""
+		}
+		if c.Name == "" {
+			c.Name = rd.URI
+		}
+		c.Handler, _ = rd.Annotations["Handler"].(string)
+		c.Version = version(rd)
+		res = append(res, c)
+	}
+	return res
+}
+
+// version returns the version the handler resolved the dependency to.
+func version(rd *slsa.ResourceDescriptor) string {
+	if hf, ok := rd.Annotations["Huggingface"].(map[string]any); ok {
+		if commit, ok := hf["Commit"].(string); ok {
+			return commit
+		}
+	}
+	if v, ok := rd.Annotations["Version"].(string); ok {
+		return v
+	}
+	return ""
+}
+
+// Algorithms returns the digest algorithms of c, sorted.
+func (c *Component) Algorithms() []string {
+	algs := make([]string, 0, len(c.DigestSet))
+	for alg := range c.DigestSet {
+		algs = append(algs, alg)
+	}
+	sort.Strings(algs)
+	return algs
+}
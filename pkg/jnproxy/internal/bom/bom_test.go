@@ -0,0 +1,66 @@
+package bom
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+func Test_Dependencies(t *testing.T) {
+	t.Parallel()
+	model := slsa.ResourceDescriptor{
+		Name:      "pkg:huggingface/org/model@abc#config.json",
+		URI:       "https://huggingface.co/org/model/resolve/main/config.json",
+		DigestSet: slsa.DigestSet{"sha256": "aa"},
+		Annotations: map[string]any{
+			"Handler":     "HuggingfaceModel/v0.1",
+			"Huggingface": map[string]any{"Commit": "abc"},
+		},
+	}
+	model.SetDependency(slsa.KindModel, slsa.EcosystemHuggingface)
+	image := slsa.ResourceDescriptor{
+		Name:        "registry.example.com/image",
+		URI:         "https://registry.example.com/v2/image/blobs/sha256:bb",
+		DigestSet:   slsa.DigestSet{"sha256": "bb"},
+		Annotations: map[string]any{"Handler": "OCIRegistry/v0.1", "Version": "v1"},
+	}
+	image.SetDependency(slsa.KindPackage, slsa.EcosystemOCI)
+	repo := slsa.ResourceDescriptor{
+		Name:      slsa.RepositoryName,
+		DigestSet: slsa.DigestSet{"gitCommit": "cc"},
+	}
+	expected := []Component{
+		{
+			Ref:       "dependency-0",
+			Name:      model.URI,
+			Version:   "abc",
+			PURL:      model.Name,
+			URI:       model.URI,
+			Kind:      slsa.KindModel,
+			Ecosystem: slsa.EcosystemHuggingface,
+			DigestSet: model.DigestSet,
+			Handler:   "HuggingfaceModel/v0.1",
+		},
+		{
+			Ref:       "dependency-1",
+			Name:      image.Name,
+			Version:   "v1",
+			URI:       image.URI,
+			Kind:      slsa.KindPackage,
+			Ecosystem: slsa.EcosystemOCI,
+			DigestSet: image.DigestSet,
+			Handler:   "OCIRegistry/v0.1",
+		},
+		{
+			Ref:       "dependency-2",
+			Name:      slsa.RepositoryName,
+			Kind:      slsa.KindFile,
+			DigestSet: repo.DigestSet,
+		},
+	}
+	components := Dependencies([]slsa.ResourceDescriptor{model, image, repo})
+	if diff := cmp.Diff(expected, components); diff != "" {
+		t.Fatalf("unexpected components (-want +got): \n%s", diff)
+	}
+}
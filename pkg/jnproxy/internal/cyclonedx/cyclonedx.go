@@ -0,0 +1,216 @@
+package cyclonedx
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/bom"
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/version"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+// See https://cyclonedx.org/docs/1.5/json/.
+
+const specVersion = "1.5"
+
+type document struct {
+	BOMFormat    string       `json:"bomFormat"`
+	SpecVersion  string       `json:"specVersion"`
+	SerialNumber string       `json:"serialNumber,omitempty"`
+	Version      int          `json:"version"`
+	Metadata     metadata     `json:"metadata"`
+	Components   []component  `json:"components,omitempty"`
+	Dependencies []dependency `json:"dependencies,omitempty"`
+}
+
+type metadata struct {
+	Timestamp string `json:"timestamp,omitempty"`
+	Tools     tools  `json:"tools"`
+}
+
+type tools struct {
+	Components []component `json:"components"`
+}
+
+type component struct {
+	Type               string              `json:"type"`
+	BOMRef             string              `json:"bom-ref,omitempty"`
+	Name               string              `json:"name"`
+	Version            string              `json:"version,omitempty"`
+	PURL               string              `json:"purl,omitempty"`
+	Hashes             []hash              `json:"hashes,omitempty"`
+	ExternalReferences []externalReference `json:"externalReferences,omitempty"`
+	Data               []data              `json:"data,omitempty"`
+	Properties         []property          `json:"properties,omitempty"`
+}
+
+type hash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type externalReference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type data struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+type property struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type dependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+// Digest algorithms with a CycloneDX name. Others, e.g. gitCommit,
+// are recorded as properties.
+var algorithms = map[string]string{
+	"md5":      "MD5",
+	"sha1":     "SHA-1",
+	"sha256":   "SHA-256",
+	"sha384":   "SHA-384",
+	"sha512":   "SHA-512",
+	"sha3-256": "SHA3-256",
+	"sha3-512": "SHA3-512",
+}
+
+// ML-BOM component types.
+func componentType(kind slsa.DependencyKind) string {
+	switch kind {
+	case slsa.KindModel:
+		return "machine-learning-model"
+	case slsa.KindDataset:
+		return "data"
+	case slsa.KindPackage:
+		return "library"
+	}
+	return "file"
+}
+
+type BOM struct {
+	doc      document
+	subjects []bom.Component
+	deps     []bom.Component
+}
+
+type Option func(*BOM) error
+
+// New returns an ML-BOM listing the subjects, which depend on
+// all the dependencies of the session.
+func New(subjects []slsa.Subject, opts ...Option) (*BOM, error) {
+	b := BOM{
+		doc: document{
+			BOMFormat:   "CycloneDX",
+			SpecVersion: specVersion,
+			Version:     1,
+			Metadata: metadata{
+				Tools: tools{
+					Components: []component{
+						{
+							Type:    "application",
+							Name:    "jnproxy",
+							Version: version.Version,
+						},
+					},
+				},
+			},
+		},
+		subjects: bom.Subjects(subjects),
+	}
+	for _, option := range opts {
+		if err := option(&b); err != nil {
+			return nil, err
+		}
+	}
+	return &b, nil
+}
+
+func AddDependencies(deps []slsa.ResourceDescriptor) Option {
+	return func(b *BOM) error {
+		return b.addDependencies(deps)
+	}
+}
+
+func (b *BOM) addDependencies(deps []slsa.ResourceDescriptor) error {
+	b.deps = append(b.deps, bom.Dependencies(deps)...)
+	return nil
+}
+
+// WithSerialNumber sets the serial number of the BOM from a UUID.
+func WithSerialNumber(uuid string) Option {
+	return func(b *BOM) error {
+		return b.withSerialNumber(uuid)
+	}
+}
+
+func (b *BOM) withSerialNumber(uuid string) error {
+	b.doc.SerialNumber = "urn:uuid:" + uuid
+	return nil
+}
+
+func WithTimestamp(t time.Time) Option {
+	return func(b *BOM) error {
+		return b.withTimestamp(t)
+	}
+}
+
+func (b *BOM) withTimestamp(t time.Time) error {
+	b.doc.Metadata.Timestamp = t.UTC().Format(time.RFC3339)
+	return nil
+}
+
+func toComponent(c *bom.Component) component {
+	res := component{
+		Type:    componentType(c.Kind),
+		BOMRef:  c.Ref,
+		Name:    c.Name,
+		Version: c.Version,
+		PURL:    c.PURL,
+	}
+	for _, alg := range c.Algorithms() {
+		if name, ok := algorithms[alg]; ok {
+			res.Hashes = append(res.Hashes, hash{Alg: name, Content: c.DigestSet[alg]})
+			continue
+		}
+		res.Properties = append(res.Properties, property{Name: "jnproxy:digest:" + alg, Value: c.DigestSet[alg]})
+	}
+	if c.URI != "" {
+		res.ExternalReferences = append(res.ExternalReferences, externalReference{Type: "distribution", URL: c.URI})
+	}
+	if c.Kind == slsa.KindDataset {
+		res.Data = append(res.Data, data{Type: "dataset", Name: c.Name})
+	}
+	if c.Ecosystem != "" {
+		res.Properties = append(res.Properties, property{Name: "jnproxy:ecosystem", Value: c.Ecosystem})
+	}
+	if c.Handler != "" {
+		res.Properties = append(res.Properties, property{Name: "jnproxy:handler", Value: c.Handler})
+	}
+	return res
+}
+
+func (b *BOM) ToBytes() ([]byte, error) {
+	doc := b.doc
+	var refs []string
+	for i := range b.deps {
+		doc.Components = append(doc.Components, toComponent(&b.deps[i]))
+		refs = append(refs, b.deps[i].Ref)
+	}
+	for i := range b.subjects {
+		doc.Components = append(doc.Components, toComponent(&b.subjects[i]))
+		doc.Dependencies = append(doc.Dependencies, dependency{Ref: b.subjects[i].Ref, DependsOn: refs})
+	}
+	content, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("marshal: %w", err)
+	}
+	return content, nil
+}
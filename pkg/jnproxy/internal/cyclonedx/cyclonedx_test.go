@@ -0,0 +1,150 @@
+package cyclonedx
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/version"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+func Test_ToBytes(t *testing.T) {
+	t.Parallel()
+	model := slsa.ResourceDescriptor{
+		Name:      "pkg:huggingface/org/model@abc#model.safetensors",
+		URI:       "hf://org/model@main/model.safetensors",
+		DigestSet: slsa.DigestSet{"sha256": "aa", "gitCommit": "abc"},
+		Annotations: map[string]any{
+			"Handler":     "HuggingfaceModel/v0.1",
+			"Huggingface": map[string]any{"Commit": "abc"},
+		},
+	}
+	model.SetDependency(slsa.KindModel, slsa.EcosystemHuggingface)
+	dataset := slsa.ResourceDescriptor{
+		URI:         "hf://datasets/org/data@main/train.csv",
+		DigestSet:   slsa.DigestSet{"sha256": "bb"},
+		Annotations: map[string]any{"Handler": "HuggingfaceDataset/v0.1"},
+	}
+	dataset.SetDependency(slsa.KindDataset, slsa.EcosystemHuggingface)
+	image := slsa.ResourceDescriptor{
+		Name:        "registry.example.com/image",
+		URI:         "registry.example.com/v2/image/manifests/v1",
+		DigestSet:   slsa.DigestSet{"sha256": "cc"},
+		Annotations: map[string]any{"Handler": "OCIRegistry/v0.1", "Version": "v1"},
+	}
+	image.SetDependency(slsa.KindPackage, slsa.EcosystemOCI)
+	subjects := []slsa.Subject{
+		{Name: "out/model.safetensors", DigestSet: slsa.DigestSet{"sha256": "dd"}},
+		{Name: "out/metrics.json", DigestSet: slsa.DigestSet{"sha512": "ee"}},
+	}
+	b, err := New(subjects,
+		AddDependencies([]slsa.ResourceDescriptor{model, dataset, image}),
+		WithSerialNumber("3e671687-395b-41f5-a30f-a58921a69b79"),
+		WithTimestamp(time.Date(2024, 4, 6, 2, 20, 8, 0, time.FixedZone("PDT", -7*3600))),
+	)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	content, err := b.ToBytes()
+	if err != nil {
+		t.Fatalf("ToBytes: %v", err)
+	}
+	var doc document
+	if err := json.Unmarshal(content, &doc); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	deps := []string{"dependency-0", "dependency-1", "dependency-2"}
+	expected := document{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79",
+		Version:      1,
+		Metadata: metadata{
+			Timestamp: "2024-04-06T09:20:08Z",
+			Tools: tools{
+				Components: []component{{Type: "application", Name: "jnproxy", Version: version.Version}},
+			},
+		},
+		Components: []component{
+			{
+				Type:               "machine-learning-model",
+				BOMRef:             "dependency-0",
+				Name:               model.URI,
+				Version:            "abc",
+				PURL:               model.Name,
+				Hashes:             []hash{{Alg: "SHA-256", Content: "aa"}},
+				ExternalReferences: []externalReference{{Type: "distribution", URL: model.URI}},
+				Properties: []property{
+					{Name: "jnproxy:digest:gitCommit", Value: "abc"},
+					{Name: "jnproxy:ecosystem", Value: slsa.EcosystemHuggingface},
+					{Name: "jnproxy:handler", Value: "HuggingfaceModel/v0.1"},
+				},
+			},
+			{
+				Type:               "data",
+				BOMRef:             "dependency-1",
+				Name:               dataset.URI,
+				Hashes:             []hash{{Alg: "SHA-256", Content: "bb"}},
+				ExternalReferences: []externalReference{{Type: "distribution", URL: dataset.URI}},
+				Data:               []data{{Type: "dataset", Name: dataset.URI}},
+				Properties: []property{
+					{Name: "jnproxy:ecosystem", Value: slsa.EcosystemHuggingface},
+					{Name: "jnproxy:handler", Value: "HuggingfaceDataset/v0.1"},
+				},
+			},
+			{
+				Type:               "library",
+				BOMRef:             "dependency-2",
+				Name:               image.Name,
+				Version:            "v1",
+				Hashes:             []hash{{Alg: "SHA-256", Content: "cc"}},
+				ExternalReferences: []externalReference{{Type: "distribution", URL: image.URI}},
+				Properties: []property{
+					{Name: "jnproxy:ecosystem", Value: slsa.EcosystemOCI},
+					{Name: "jnproxy:handler", Value: "OCIRegistry/v0.1"},
+				},
+			},
+			{
+				Type:   "file",
+				BOMRef: "subject-0",
+				Name:   "out/model.safetensors",
+				Hashes: []hash{{Alg: "SHA-256", Content: "dd"}},
+			},
+			{
+				Type:   "file",
+				BOMRef: "subject-1",
+				Name:   "out/metrics.json",
+				Hashes: []hash{{Alg: "SHA-512", Content: "ee"}},
+			},
+		},
+		Dependencies: []dependency{
+			{Ref: "subject-0", DependsOn: deps},
+			{Ref: "subject-1", DependsOn: deps},
+		},
+	}
+	if diff := cmp.Diff(expected, doc); diff != "" {
+		t.Fatalf("unexpected document (-want +got): \n%s", diff)
+	}
+}
+
+func Test_ToBytes_noDependencies(t *testing.T) {
+	t.Parallel()
+	b, err := New([]slsa.Subject{{Name: "out/model.safetensors", DigestSet: slsa.DigestSet{"sha256": "dd"}}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	content, err := b.ToBytes()
+	if err != nil {
+		t.Fatalf("ToBytes: %v", err)
+	}
+	var doc document
+	if err := json.Unmarshal(content, &doc); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	// Subjects are listed without dependencies.
+	if diff := cmp.Diff([]dependency{{Ref: "subject-0"}}, doc.Dependencies); diff != "" {
+		t.Fatalf("unexpected dependencies (-want +got): \n%s", diff)
+	}
+}
@@ -0,0 +1,143 @@
+package outputs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+// Glob returns the subjects of the files matching the patterns,
+// see filepath.Match for the syntax. Matching directories are walked.
+// Subjects are named after their path. The patterns that match nothing
+// are returned too: outputs may not exist yet, e.g. for a checkpoint
+// taken before the notebook saves its model.
+func Glob(patterns ...string) ([]slsa.Subject, []string, error) {
+	var subjects []slsa.Subject
+	var unmatched []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: pattern (%q): %w", errs.ErrorInvalid, pattern, err)
+		}
+		if len(matches) == 0 {
+			unmatched = append(unmatched, pattern)
+			continue
+		}
+		for _, m := range matches {
+			err := walk(m, func(path string, _ fs.FileInfo) error {
+				s, err := subject(path, path)
+				if err != nil {
+					return err
+				}
+				subjects = append(subjects, s)
+				return nil
+			})
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return subjects, unmatched, nil
+}
+
+// walk calls fn for the regular files under root.
+// Symbolic links are not followed.
+func walk(root string, fn func(path string, info fs.FileInfo) error) error {
+	return filepath.Walk(root, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf("walk (%q): %w", path, err)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return fn(path, info)
+	})
+}
+
+func subject(name, path string) (slsa.Subject, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return slsa.Subject{}, fmt.Errorf("open (%q): %w", path, err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return slsa.Subject{}, fmt.Errorf("read (%q): %w", path, err)
+	}
+	return slsa.Subject{
+		Name:      filepath.ToSlash(name),
+		DigestSet: slsa.DigestSet{"sha256": hex.EncodeToString(h.Sum(nil))},
+	}, nil
+}
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// Snapshot records the state of the files in a directory,
+// to later detect the files written since.
+type Snapshot struct {
+	dir   string
+	files map[string]fileState
+}
+
+// NewSnapshot records the state of the files in dir.
+// The directory is created if it does not exist.
+func NewSnapshot(dir string) (*Snapshot, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("mkdir (%q): %w", dir, err)
+	}
+	s := &Snapshot{
+		dir:   dir,
+		files: make(map[string]fileState),
+	}
+	err := walk(dir, func(path string, info fs.FileInfo) error {
+		s.files[path] = fileState{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Changed returns the subjects of the files created or modified
+// since the snapshot, named after their path relative to the directory.
+func (s *Snapshot) Changed() ([]slsa.Subject, error) {
+	var paths []string
+	err := walk(s.dir, func(path string, info fs.FileInfo) error {
+		prev, ok := s.files[path]
+		if ok && prev.size == info.Size() && prev.modTime.Equal(info.ModTime()) {
+			return nil
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	subjects := make([]slsa.Subject, 0, len(paths))
+	for _, path := range paths {
+		name, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return nil, fmt.Errorf("rel (%q): %w", path, err)
+		}
+		sub, err := subject(name, path)
+		if err != nil {
+			return nil, err
+		}
+		subjects = append(subjects, sub)
+	}
+	return subjects, nil
+}
@@ -0,0 +1,130 @@
+package outputs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+func digest(content string) slsa.DigestSet {
+	h := sha256.Sum256([]byte(content))
+	return slsa.DigestSet{"sha256": hex.EncodeToString(h[:])}
+}
+
+func writeFile(t *testing.T, fn, content string) {
+	if err := os.MkdirAll(filepath.Dir(fn), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(fn, []byte(content), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
+
+func Test_Glob(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "model.safetensors"), "weights")
+	writeFile(t, filepath.Join(dir, "config.json"), "config")
+	writeFile(t, filepath.Join(dir, "data", "train.csv"), "train")
+	tests := []struct {
+		name      string
+		patterns  []string
+		subjects  []slsa.Subject
+		unmatched []string
+		expected  error
+	}{
+		{
+			name:     "file",
+			patterns: []string{filepath.Join(dir, "config.json")},
+			subjects: []slsa.Subject{
+				{Name: filepath.ToSlash(filepath.Join(dir, "config.json")), DigestSet: digest("config")},
+			},
+		},
+		{
+			name:     "glob and directory",
+			patterns: []string{filepath.Join(dir, "*.safetensors"), filepath.Join(dir, "data")},
+			subjects: []slsa.Subject{
+				{Name: filepath.ToSlash(filepath.Join(dir, "model.safetensors")), DigestSet: digest("weights")},
+				{Name: filepath.ToSlash(filepath.Join(dir, "data", "train.csv")), DigestSet: digest("train")},
+			},
+		},
+		{
+			name:     "no match",
+			patterns: []string{filepath.Join(dir, "*.bin"), filepath.Join(dir, "config.json")},
+			subjects: []slsa.Subject{
+				{Name: filepath.ToSlash(filepath.Join(dir, "config.json")), DigestSet: digest("config")},
+			},
+			unmatched: []string{filepath.Join(dir, "*.bin")},
+		},
+		{
+			name:     "invalid pattern",
+			patterns: []string{"["},
+			expected: errs.ErrorInvalid,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			subjects, unmatched, err := Glob(tt.patterns...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.subjects, subjects); diff != "" {
+				t.Fatalf("unexpected subjects (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.unmatched, unmatched); diff != "" {
+				t.Fatalf("unexpected unmatched (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Snapshot(t *testing.T) {
+	t.Parallel()
+	dir := filepath.Join(t.TempDir(), "outputs")
+	// Created if it does not exist.
+	s, err := NewSnapshot(dir)
+	if err != nil {
+		t.Fatalf("NewSnapshot: %v", err)
+	}
+	writeFile(t, filepath.Join(dir, "unchanged.txt"), "unchanged")
+	writeFile(t, filepath.Join(dir, "modified.txt"), "before")
+	s, err = NewSnapshot(dir)
+	if err != nil {
+		t.Fatalf("NewSnapshot: %v", err)
+	}
+	subjects, err := s.Changed()
+	if err != nil {
+		t.Fatalf("Changed: %v", err)
+	}
+	if diff := cmp.Diff([]slsa.Subject{}, subjects); diff != "" {
+		t.Fatalf("unexpected subjects (-want +got): \n%s", diff)
+	}
+	writeFile(t, filepath.Join(dir, "modified.txt"), "after")
+	// Make sure the modification time changes on file systems with coarse timestamps.
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "modified.txt"), future, future); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	writeFile(t, filepath.Join(dir, "model", "weights.bin"), "weights")
+	subjects, err = s.Changed()
+	if err != nil {
+		t.Fatalf("Changed: %v", err)
+	}
+	expected := []slsa.Subject{
+		{Name: "model/weights.bin", DigestSet: digest("weights")},
+		{Name: "modified.txt", DigestSet: digest("after")},
+	}
+	if diff := cmp.Diff(expected, subjects); diff != "" {
+		t.Fatalf("unexpected subjects (-want +got): \n%s", diff)
+	}
+}
@@ -0,0 +1,67 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	handler "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+// Kinds of byproducts, recorded as their name.
+const (
+	// Request or response refused by a handler.
+	byproductDenied = "denied"
+	// Request that failed upstream, with a 4xx/5xx status or no response.
+	byproductUpstreamError = "upstream-error"
+	// Handler that failed to process a request or response.
+	byproductHandlerError = "handler-error"
+	// Request the proxy could not process.
+	byproductProxyError = "proxy-error"
+	// Uri observed with different digests in the session.
+	byproductConflict = "conflict"
+)
+
+// Reasons are truncated to keep the provenance small.
+const maxReasonLength = 512
+
+// recordByproduct records an attempt that did not result in a dependency,
+// so that reviewers can see what a notebook tried to fetch. The URL is
+// recorded without its query, so a denied request for a presigned URL
+// still shows which object was requested.
+func (p *Proxy) recordByproduct(kind string, req *http.Request, status int, handlerName, reason string) {
+	if len(reason) > maxReasonLength {
+		reason = reason[:maxReasonLength] + "..."
+	}
+	annotations := map[string]any{
+		"Method": req.Method,
+		"Time":   time.Now().UTC().Format(time.RFC3339),
+	}
+	if status != 0 {
+		annotations["Status"] = status
+	}
+	if handlerName != "" {
+		annotations["Handler"] = handlerName
+	}
+	if reason != "" {
+		annotations["Reason"] = reason
+	}
+	p.logger.Debugf("[http] %s (%q %q) status %d: %s", kind, req.Method, urlOf(req), status, reason)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.byproducts = append(p.byproducts, slsa.ResourceDescriptor{
+		Name:        kind,
+		URI:         urlOf(req),
+		Annotations: annotations,
+	})
+}
+
+// responseReason returns the body of responses created by handlers,
+// which explain why they were created.
+func responseReason(resp *http.Response) string {
+	b, err := handler.ReadBody(resp)
+	if err != nil || len(b) == 0 {
+		return resp.Status
+	}
+	return string(b)
+}
@@ -0,0 +1,16 @@
+package http
+
+import "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/session"
+
+// WithClock attributes the dependencies to the cell
+// executing when they are requested.
+func WithClock(clock *session.Clock) Option {
+	return func(p *Proxy) error {
+		return p.setClock(clock)
+	}
+}
+
+func (p *Proxy) setClock(clock *session.Clock) error {
+	p.clock = clock
+	return nil
+}
@@ -5,14 +5,20 @@ import (
 	"fmt"
 	"net/http"
 	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
 
 	"github.com/elazarl/goproxy"
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
 	handler "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http"
 	logimpl "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/logger"
 	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/proxy"
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/session"
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/version"
 	"github.com/laurentsimon/jupyter-lineage/pkg/logger"
 )
 
@@ -22,8 +28,19 @@ type Proxy struct {
 	server       *http.Server
 	handlers     []handler.Handler
 	callbacks    sync.Map
+	chains       sync.Map           // Redirect chains of the sessions.
+	redirects    map[string][]chain // Pending redirect chains, keyed by client and location.
+	redirectMu   sync.Mutex         // To access redirects.
+	responded    sync.Map           // Handlers that created the response of the sessions.
+	cells        sync.Map           // Cells executing when the requests of the sessions were made.
+	clock        *session.Clock
 	dependencies []slsa.ResourceDescriptor
-	mu           sync.Mutex // To add dependencies
+	byproducts   []slsa.ResourceDescriptor
+	subjects     []slsa.Subject
+	seen         map[string]int    // Index of dependencies, keyed by uri and digest.
+	published    map[string]bool   // Names of the subjects.
+	digests      map[string]string // First digest observed for each uri.
+	mu           sync.Mutex        // To add dependencies and byproducts
 }
 
 type Option func(*Proxy) error
@@ -60,7 +77,11 @@ func New(address string, options ...Option) (*Proxy, error) {
 		server: &http.Server{
 			Addr: address,
 		},
-		logger: logimpl.Logger{},
+		logger:    logimpl.Logger{},
+		seen:      make(map[string]int),
+		published: make(map[string]bool),
+		redirects: make(map[string][]chain),
+		digests:   make(map[string]string),
 	}
 
 	// Set optional parameters.
@@ -104,32 +125,72 @@ func (p *Proxy) createHttpProxy() error {
 			p.logger.Debugf("[http] no handler installed (%q)", r.Host)
 			return r, nil
 		}
+		ctx.RoundTripper = goproxy.RoundTripperFunc(p.roundTrip)
+		// The cell is the one executing when the request is made,
+		// since the response may be processed after it completes.
+		if p.clock != nil {
+			if cell, ok := p.clock.Current(); ok {
+				p.cells.Store(ctx.Session, cell)
+			}
+		}
+		// Requests that follow a redirect are handled by the handler
+		// of the original request. All handlers still get a chance to respond,
+		// so that a redirect cannot bypass them.
+		c, redirected := p.redirected(r)
 		for _, h := range p.handlers {
-			req, resp, ok, err := h.OnRequest(r, handler.Context{ID: ctx.Session, Logger: p.logger})
+			req, resp, ok, err := h.OnRequest(r, handler.Context{ID: ctx.Session, Req: r, Logger: p.logger, Redirects: c.redirects})
 			if err != nil {
 				// TODO: More logging.
 				p.logger.Errorf("[http] handler (%q) OnRequest (%q) error: %v", h.Name(), r.Host, err)
+				p.recordByproduct(byproductHandlerError, r, 0, h.Name(), err.Error())
 				continue
 			}
 			if resp != nil {
 				p.logger.Debugf("[http] handler (%q) created a request (%q) of status %d", h.Name(), r.Host, resp.StatusCode)
+				p.responded.Store(ctx.Session, h.Name())
 				return req, resp
 			}
 			if !ok {
 				p.logger.Debugf("[http] handler (%q) not interested in request (%q)", h.Name(), r.Host+r.URL.Path)
 				continue
 			}
+			if redirected {
+				r = req
+				continue
+			}
 			// Keep track of the handler to call back.
 			p.callbacks.Store(ctx.Session, h)
 			return req, resp
 		}
+		if redirected {
+			p.logger.Debugf("[http] handler (%q) following redirect (%q)", c.handler.Name(), r.Host+r.URL.Path)
+			p.callbacks.Store(ctx.Session, c.handler)
+			p.chains.Store(ctx.Session, c)
+		}
 		return r, nil
 	})
 	httpProxy.OnResponse().DoFunc(func(resp *http.Response, ctx *goproxy.ProxyCtx) *http.Response {
 		if p.handlers == nil {
 			return resp
 		}
-		defer p.callbacks.Delete(ctx.Session)
+		defer p.forget(ctx.Session)
+		if resp == nil {
+			// The request failed upstream, and roundTrip recorded it.
+			return resp
+		}
+		if name, ok := p.responded.LoadAndDelete(ctx.Session); ok {
+			// The response was created by a handler in OnRequest.
+			switch {
+			case resp.StatusCode == http.StatusForbidden:
+				p.recordByproduct(byproductDenied, ctx.Req, resp.StatusCode, name.(string), responseReason(resp))
+			case resp.StatusCode >= http.StatusBadRequest:
+				p.recordByproduct(byproductHandlerError, ctx.Req, resp.StatusCode, name.(string), responseReason(resp))
+			}
+			return resp
+		}
+		if resp.StatusCode >= http.StatusBadRequest {
+			p.recordByproduct(byproductUpstreamError, ctx.Req, resp.StatusCode, "", resp.Status)
+		}
 		if resp.StatusCode == http.StatusForbidden {
 			p.logger.Debugf("[http] host (%q) relay Forbidden response", ctx.Req.Host)
 			return resp
@@ -137,32 +198,97 @@ func (p *Proxy) createHttpProxy() error {
 		// TODO(#5): Support chunked encoding.
 		tf, ok := resp.Header["Transfer-Encoding"]
 		if ok && slices.Contains(tf, "chunked") {
+			p.recordByproduct(byproductProxyError, ctx.Req, resp.StatusCode, "", "chunked not supported")
 			return handler.NewResponse(ctx.Req, handler.ContentTypeText, http.StatusInternalServerError, "chunked not supported")
 		}
 		val, ok := p.callbacks.Load(ctx.Session)
 		if !ok {
 			// TODO: configurable what to do here.
 			p.logger.Debugf("[http] host (%q) has not handler", ctx.Req.Host)
+			p.recordByproduct(byproductProxyError, ctx.Req, resp.StatusCode, "", "no handler")
 			return goproxy.NewResponse(ctx.Req, goproxy.ContentTypeText, http.StatusInternalServerError, "InternalServerError")
 		}
 		v, ok := val.(handler.Handler)
 		if !ok {
 			p.logger.Errorf("[http] map contains a non handler type (%T)", val)
+			p.recordByproduct(byproductProxyError, ctx.Req, resp.StatusCode, "", fmt.Sprintf("non handler type (%T)", val))
 			return goproxy.NewResponse(ctx.Req, goproxy.ContentTypeText, http.StatusInternalServerError, "InternalServerError")
 		}
+		var redirects []handler.Redirect
+		if c, ok := p.chains.Load(ctx.Session); ok {
+			redirects = c.(chain).redirects
+		}
 		p.logger.Debugf("[http] handler (%q) handling response (%q)", v.Name(), ctx.Req.Host+ctx.Req.URL.Path)
-		r, err := v.OnResponse(resp, handler.Context{ID: ctx.Session, Req: ctx.Req, Logger: p.logger})
+		r, err := v.OnResponse(resp, handler.Context{ID: ctx.Session, Req: ctx.Req, Logger: p.logger, Redirects: redirects})
 		if err != nil {
 			p.logger.Errorf("[http] handler (%q) OnResponse (%q) error: %v", v.Name(), ctx.Req.Host, err)
+			p.recordByproduct(byproductHandlerError, ctx.Req, resp.StatusCode, v.Name(), err.Error())
 			return goproxy.NewResponse(ctx.Req, goproxy.ContentTypeText, http.StatusInternalServerError, "InternalServerError")
 		}
+		if r != nil && r != resp && r.StatusCode >= http.StatusBadRequest {
+			// The handler rejected the response, e.g. for a digest mismatch.
+			p.recordByproduct(byproductHandlerError, ctx.Req, r.StatusCode, v.Name(), responseReason(r))
+		}
 		deps, err := v.Dependencies(handler.Context{Logger: p.logger})
 		if err != nil {
 			p.logger.Errorf("[http] handler (%q) Dependencies (%q) error: %v", v.Name(), ctx.Req.Host, err)
+			p.recordByproduct(byproductHandlerError, ctx.Req, resp.StatusCode, v.Name(), err.Error())
 			return goproxy.NewResponse(ctx.Req, goproxy.ContentTypeText, http.StatusInternalServerError, "InternalServerError")
 		}
-		if err := p.recordDependencies(deps); err != nil {
+		if sp, ok := v.(handler.SubjectProvider); ok {
+			subjects, err := sp.Subjects(handler.Context{ID: ctx.Session, Req: ctx.Req, Logger: p.logger})
+			if err != nil {
+				p.logger.Errorf("[http] handler (%q) Subjects (%q) error: %v", v.Name(), ctx.Req.Host, err)
+				p.recordByproduct(byproductHandlerError, ctx.Req, resp.StatusCode, v.Name(), err.Error())
+				return goproxy.NewResponse(ctx.Req, goproxy.ContentTypeText, http.StatusInternalServerError, "InternalServerError")
+			}
+			p.recordSubjects(subjects)
+		}
+		if r != nil && isRedirect(r) {
+			// A redirect carries no content: the dependency is recorded
+			// when the client gets the final response.
+			if err := p.recordRedirect(ctx.Session, v, ctx.Req, r); err != nil {
+				p.logger.Errorf("[http] handler (%q) record redirect (%q) error: %v", v.Name(), ctx.Req.Host, err)
+				p.recordByproduct(byproductProxyError, ctx.Req, r.StatusCode, v.Name(), err.Error())
+				return goproxy.NewResponse(ctx.Req, goproxy.ContentTypeText, http.StatusInternalServerError, "InternalServerError")
+			}
+			if len(deps) > 0 {
+				p.logger.Debugf("[http] handler (%q) dropping %d dependencies for redirect (%q)", v.Name(), len(deps), ctx.Req.Host+ctx.Req.URL.Path)
+			}
+			return r
+		}
+		linkRedirects(deps, redirects, ctx.Req)
+		record := true
+		for _, h := range p.handlers {
+			f, ok := h.(handler.ResponseFilter)
+			if !ok || r == nil {
+				continue
+			}
+			fr, rec, err := f.FilterResponse(r, deps, handler.Context{ID: ctx.Session, Req: ctx.Req, Logger: p.logger, Redirects: redirects})
+			if err != nil {
+				p.logger.Errorf("[http] handler (%q) FilterResponse (%q) error: %v", h.Name(), ctx.Req.Host, err)
+				p.recordByproduct(byproductHandlerError, ctx.Req, r.StatusCode, h.Name(), err.Error())
+				return goproxy.NewResponse(ctx.Req, goproxy.ContentTypeText, http.StatusInternalServerError, "InternalServerError")
+			}
+			if fr != nil {
+				p.logger.Debugf("[http] handler (%q) rejected response (%q) with status %d", h.Name(), ctx.Req.Host+ctx.Req.URL.Path, fr.StatusCode)
+				p.recordByproduct(byproductDenied, ctx.Req, fr.StatusCode, h.Name(), responseReason(fr))
+				return fr
+			}
+			record = record && rec
+		}
+		if !record {
+			p.logger.Debugf("[http] dropping %d dependencies (%q)", len(deps), ctx.Req.Host+ctx.Req.URL.Path)
+			return r
+		}
+		var cell *session.Cell
+		if c, ok := p.cells.Load(ctx.Session); ok {
+			cc := c.(session.Cell)
+			cell = &cc
+		}
+		if err := p.recordDependencies(ctx.Req, v.Name(), cell, deps); err != nil {
 			p.logger.Errorf("[http] handler (%q) record dependencies (%q) error: %v", v.Name(), ctx.Req.Host, err)
+			p.recordByproduct(byproductProxyError, ctx.Req, resp.StatusCode, v.Name(), err.Error())
 			return goproxy.NewResponse(ctx.Req, goproxy.ContentTypeText, http.StatusInternalServerError, "InternalServerError")
 		}
 		return r
@@ -173,15 +299,153 @@ func (p *Proxy) createHttpProxy() error {
 	return nil
 }
 
-func (p *Proxy) recordDependencies(deps []slsa.ResourceDescriptor) error {
+// roundTrip sends the request upstream and records failures.
+// goproxy does not call the response callback when the request of an
+// HTTPS session fails, and calls it twice for HTTP sessions, so failures
+// are recorded here instead.
+func (p *Proxy) roundTrip(req *http.Request, ctx *goproxy.ProxyCtx) (*http.Response, error) {
+	resp, err := ctx.Proxy.Tr.RoundTrip(req)
+	if err != nil {
+		p.recordByproduct(byproductUpstreamError, req, 0, "", err.Error())
+		p.forget(ctx.Session)
+	}
+	return resp, err
+}
+
+// forget erases the state of session, once its response is handled
+// or its request failed.
+func (p *Proxy) forget(session int64) {
+	p.callbacks.Delete(session)
+	p.chains.Delete(session)
+	p.cells.Delete(session)
+	p.responded.Delete(session)
+}
+
+// recordDependencies records deps, once per uri and digest.
+// Dependencies are stamped with the name of the handler that produced
+// them and the version of jnproxy. Dependencies of unknown kind are
+// recorded as files downloaded over HTTP.
+// Repeated accesses are counted in the "Access" annotation.
+// The cells executing when the dependencies were accessed, if known,
+// are listed in the "Cells" annotation.
+// A uri observed with different digests is reported as a conflict,
+// since its content changed during the session.
+func (p *Proxy) recordDependencies(req *http.Request, handlerName string, cell *session.Cell, deps []slsa.ResourceDescriptor) error {
+	// Validate all the dependencies before recording any.
+	for _, rd := range deps {
+		if err := validateDependency(rd, handlerName); err != nil {
+			return err
+		}
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	var conflicts []string
+	p.mu.Lock()
+	for _, rd := range deps {
+		digest := digestKey(rd.DigestSet)
+		key := rd.URI + "@" + digest
+		if i, ok := p.seen[key]; ok {
+			access := p.dependencies[i].Annotations["Access"].(map[string]any)
+			access["Count"] = access["Count"].(int) + 1
+			access["Last"] = now
+			if cell != nil {
+				annotations := p.dependencies[i].Annotations
+				cells, _ := annotations["Cells"].([]map[string]any)
+				if !slices.ContainsFunc(cells, func(c map[string]any) bool { return c["MsgID"] == cell.MsgID }) {
+					annotations["Cells"] = append(cells, cell.Annotation())
+				}
+			}
+			continue
+		}
+		if first, ok := p.digests[rd.URI]; !ok {
+			p.digests[rd.URI] = digest
+		} else {
+			conflicts = append(conflicts, fmt.Sprintf("uri (%q) observed with digests (%s) and (%s)", rd.URI, first, digest))
+		}
+		// NOTE: Annotations may be shared with the handler, so we make a copy.
+		annotations := make(map[string]any, len(rd.Annotations)+3)
+		for k, v := range rd.Annotations {
+			annotations[k] = v
+		}
+		annotations["Handler"] = handlerName
+		annotations["JNProxy"] = map[string]any{
+			"Version": version.Version,
+		}
+		annotations["Access"] = map[string]any{
+			"Count": 1,
+			"First": now,
+			"Last":  now,
+		}
+		if cell != nil {
+			annotations["Cells"] = []map[string]any{cell.Annotation()}
+		}
+		rd.Annotations = annotations
+		if _, _, ok := rd.Dependency(); !ok {
+			rd.SetDependency(slsa.KindFile, slsa.EcosystemHTTP)
+		}
+		p.seen[key] = len(p.dependencies)
+		p.dependencies = append(p.dependencies, rd)
+	}
+	p.mu.Unlock()
+	for _, c := range conflicts {
+		p.logger.Warnf("[http] conflict: %s", c)
+		p.recordByproduct(byproductConflict, req, 0, "", c)
+	}
+	return nil
+}
+
+// recordSubjects records subjects, once per name.
+func (p *Proxy) recordSubjects(subjects []slsa.Subject) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	// TODO(#10): Remove duplicate entries
-	// TODO(#11): Validate the presence of handler name / version.
-	p.dependencies = append(p.dependencies, deps...)
+	for _, s := range subjects {
+		if p.published[s.Name] {
+			continue
+		}
+		p.published[s.Name] = true
+		p.subjects = append(p.subjects, s)
+	}
+}
+
+// validateDependency verifies that rd identifies its content
+// and does not claim to be produced by another handler.
+func validateDependency(rd slsa.ResourceDescriptor, handlerName string) error {
+	if rd.URI == "" {
+		return fmt.Errorf("%w: handler (%q) dependency has no uri", errs.ErrorInvalid, handlerName)
+	}
+	if len(rd.DigestSet) == 0 {
+		return fmt.Errorf("%w: handler (%q) dependency (%q) has no digest", errs.ErrorInvalid, handlerName, rd.URI)
+	}
+	for alg, v := range rd.DigestSet {
+		if alg == "" || v == "" {
+			return fmt.Errorf("%w: handler (%q) dependency (%q) has an empty digest", errs.ErrorInvalid, handlerName, rd.URI)
+		}
+	}
+	if kind, _, ok := rd.Dependency(); ok && !kind.IsValid() {
+		return fmt.Errorf("%w: handler (%q) dependency (%q) has unknown kind (%q)", errs.ErrorInvalid, handlerName, rd.URI, kind)
+	}
+	if name, ok := rd.Annotations["Handler"]; ok && name != handlerName {
+		return fmt.Errorf("%w: handler (%q) dependency (%q) names handler (%v)", errs.ErrorInvalid, handlerName, rd.URI, name)
+	}
 	return nil
 }
 
+// urlOf returns the URL recorded for req in the provenance.
+// WARNING: We're not recording GET parameters, which may contain
+// credentials, e.g. in presigned URLs.
+func urlOf(req *http.Request) string {
+	return req.URL.Host + req.URL.Path
+}
+
+// digestKey returns a canonical representation of digests.
+func digestKey(digests slsa.DigestSet) string {
+	var parts []string
+	for alg, v := range digests {
+		parts = append(parts, strings.ToLower(alg)+":"+strings.ToLower(v))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
 func (p *Proxy) Start() error {
 	if p.server == nil {
 		return fmt.Errorf("http:proxy not ready")
@@ -206,8 +470,47 @@ func (p *Proxy) Type() proxy.Type {
 	return proxy.TypeRuntime
 }
 
+// Dependencies returns a snapshot of the dependencies recorded so far.
+// It may be called while the proxy is running.
 func (p *Proxy) Dependencies() ([]slsa.ResourceDescriptor, error) {
-	return p.dependencies, nil
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	deps := make([]slsa.ResourceDescriptor, len(p.dependencies))
+	for i, rd := range p.dependencies {
+		// NOTE: The access annotation is updated in place by recordDependencies.
+		annotations := make(map[string]any, len(rd.Annotations))
+		for k, v := range rd.Annotations {
+			annotations[k] = v
+		}
+		if access, ok := rd.Annotations["Access"].(map[string]any); ok {
+			annotations["Access"] = map[string]any{
+				"Count": access["Count"],
+				"First": access["First"],
+				"Last":  access["Last"],
+			}
+		}
+		if cells, ok := rd.Annotations["Cells"].([]map[string]any); ok {
+			annotations["Cells"] = slices.Clone(cells)
+		}
+		rd.Annotations = annotations
+		deps[i] = rd
+	}
+	return deps, nil
+}
+
+// Byproducts returns a snapshot of the byproducts recorded so far.
+func (p *Proxy) Byproducts() ([]slsa.ResourceDescriptor, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]slsa.ResourceDescriptor{}, p.byproducts...), nil
+}
+
+// Subjects returns a snapshot of the subjects recorded so far,
+// e.g. the files uploaded to a model hub.
+func (p *Proxy) Subjects() ([]slsa.Subject, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]slsa.Subject{}, p.subjects...), nil
 }
 
 func (p *Proxy) serve() {
@@ -0,0 +1,262 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/elazarl/goproxy"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/session"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+func Test_recordDependencies(t *testing.T) {
+	t.Parallel()
+	p, err := New("localhost:0")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	req := httptest.NewRequest("GET", "https://huggingface.co/owner/name/resolve/main/config.json", nil)
+	rd := func(uri, digest string) slsa.ResourceDescriptor {
+		return slsa.ResourceDescriptor{
+			URI:         uri,
+			DigestSet:   slsa.DigestSet{"sha256": digest},
+			Annotations: map[string]any{"Handler": "Allow/v0.1"},
+		}
+	}
+	for _, deps := range [][]slsa.ResourceDescriptor{
+		{rd("example.com/a", "aa")},
+		{rd("example.com/a", "aa"), rd("example.com/b", "bb")},
+		{rd("example.com/a", "AA")},
+		// Conflict.
+		{rd("example.com/a", "cc")},
+	} {
+		if err := p.recordDependencies(req, "Allow/v0.1", nil, deps); err != nil {
+			t.Fatalf("recordDependencies: %v", err)
+		}
+	}
+	deps, err := p.Dependencies()
+	if err != nil {
+		t.Fatalf("Dependencies: %v", err)
+	}
+	var uris []string
+	var counts []int
+	for _, d := range deps {
+		uris = append(uris, d.URI+"@"+d.DigestSet["sha256"])
+		counts = append(counts, d.Annotations["Access"].(map[string]any)["Count"].(int))
+	}
+	if diff := cmp.Diff([]string{"example.com/a@aa", "example.com/b@bb", "example.com/a@cc"}, uris); diff != "" {
+		t.Fatalf("unexpected dependencies (-want +got): \n%s", diff)
+	}
+	if diff := cmp.Diff([]int{3, 1, 1}, counts); diff != "" {
+		t.Fatalf("unexpected counts (-want +got): \n%s", diff)
+	}
+	byproducts, err := p.Byproducts()
+	if err != nil {
+		t.Fatalf("Byproducts: %v", err)
+	}
+	if len(byproducts) != 1 || byproducts[0].Name != byproductConflict {
+		t.Fatalf("unexpected byproducts: %v", byproducts)
+	}
+	// Snapshots are not updated by later records.
+	if err := p.recordDependencies(req, "Allow/v0.1", nil, []slsa.ResourceDescriptor{rd("example.com/a", "aa")}); err != nil {
+		t.Fatalf("recordDependencies: %v", err)
+	}
+	if count := deps[0].Annotations["Access"].(map[string]any)["Count"].(int); count != 3 {
+		t.Fatalf("snapshot count changed to %d", count)
+	}
+}
+
+func Test_recordDependencies_cells(t *testing.T) {
+	t.Parallel()
+	p, err := New("localhost:0")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	req := httptest.NewRequest("GET", "https://huggingface.co/owner/name/resolve/main/config.json", nil)
+	rd := slsa.ResourceDescriptor{
+		URI:         "example.com/a",
+		DigestSet:   slsa.DigestSet{"sha256": "aa"},
+		Annotations: map[string]any{"Handler": "Allow/v0.1"},
+	}
+	for _, cell := range []*session.Cell{
+		nil,
+		{MsgID: "1", ExecutionCount: 7},
+		{MsgID: "1", ExecutionCount: 7},
+		{MsgID: "2"},
+	} {
+		if err := p.recordDependencies(req, "Allow/v0.1", cell, []slsa.ResourceDescriptor{rd}); err != nil {
+			t.Fatalf("recordDependencies: %v", err)
+		}
+	}
+	deps, err := p.Dependencies()
+	if err != nil {
+		t.Fatalf("Dependencies: %v", err)
+	}
+	expected := []map[string]any{
+		{"MsgID": "1", "ExecutionCount": 7},
+		{"MsgID": "2"},
+	}
+	if diff := cmp.Diff(expected, deps[0].Annotations["Cells"]); diff != "" {
+		t.Fatalf("unexpected cells (-want +got): \n%s", diff)
+	}
+}
+
+func Test_validateDependency(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		rd       slsa.ResourceDescriptor
+		expected error
+	}{
+		{
+			name: "valid",
+			rd: slsa.ResourceDescriptor{
+				URI:         "example.com/a",
+				DigestSet:   slsa.DigestSet{"sha256": "aa"},
+				Annotations: map[string]any{"Handler": "Allow/v0.1"},
+			},
+		},
+		{
+			name: "no handler annotation",
+			rd: slsa.ResourceDescriptor{
+				URI:       "example.com/a",
+				DigestSet: slsa.DigestSet{"sha256": "aa"},
+			},
+		},
+		{
+			name: "no uri",
+			rd: slsa.ResourceDescriptor{
+				DigestSet: slsa.DigestSet{"sha256": "aa"},
+			},
+			expected: errs.ErrorInvalid,
+		},
+		{
+			name: "no digest",
+			rd: slsa.ResourceDescriptor{
+				URI: "example.com/a",
+			},
+			expected: errs.ErrorInvalid,
+		},
+		{
+			name: "empty digest",
+			rd: slsa.ResourceDescriptor{
+				URI:       "example.com/a",
+				DigestSet: slsa.DigestSet{"sha256": ""},
+			},
+			expected: errs.ErrorInvalid,
+		},
+		{
+			name: "unknown kind",
+			rd: slsa.ResourceDescriptor{
+				URI:       "example.com/a",
+				DigestSet: slsa.DigestSet{"sha256": "aa"},
+				Annotations: map[string]any{
+					"Dependency": map[string]any{"Kind": "notebook"},
+				},
+			},
+			expected: errs.ErrorInvalid,
+		},
+		{
+			name: "other handler",
+			rd: slsa.ResourceDescriptor{
+				URI:         "example.com/a",
+				DigestSet:   slsa.DigestSet{"sha256": "aa"},
+				Annotations: map[string]any{"Handler": "Deny/v0.1"},
+			},
+			expected: errs.ErrorInvalid,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := validateDependency(tt.rd, "Allow/v0.1")
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_recordSubjects(t *testing.T) {
+	t.Parallel()
+	p, err := New("localhost:0")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	s := func(name, digest string) slsa.Subject {
+		return slsa.Subject{Name: name, DigestSet: slsa.DigestSet{"sha256": digest}}
+	}
+	p.recordSubjects([]slsa.Subject{s("hf://owner/name@c1/a", "aa"), s("hf://owner/name@c1/b", "bb")})
+	p.recordSubjects([]slsa.Subject{s("hf://owner/name@c1/a", "aa"), s("hf://owner/name@c2/a", "cc")})
+	subjects, err := p.Subjects()
+	if err != nil {
+		t.Fatalf("Subjects: %v", err)
+	}
+	expected := []slsa.Subject{s("hf://owner/name@c1/a", "aa"), s("hf://owner/name@c1/b", "bb"), s("hf://owner/name@c2/a", "cc")}
+	if diff := cmp.Diff(expected, subjects); diff != "" {
+		t.Fatalf("unexpected subjects (-want +got): \n%s", diff)
+	}
+}
+
+func Test_roundTrip(t *testing.T) {
+	t.Parallel()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(upstream.Close)
+	tests := []struct {
+		name       string
+		url        string
+		byproducts []string
+	}{
+		{
+			name: "success",
+			url:  upstream.URL + "/a",
+		},
+		{
+			name:       "failure",
+			url:        "http://127.0.0.1:1/a",
+			byproducts: []string{byproductUpstreamError},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			p, err := New("localhost:0")
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			req := httptest.NewRequest("GET", tt.url, nil)
+			req.RequestURI = ""
+			ctx := &goproxy.ProxyCtx{Req: req, Session: 1, Proxy: goproxy.NewProxyHttpServer()}
+			p.callbacks.Store(ctx.Session, "handler")
+			p.responded.Store(ctx.Session, "handler")
+			resp, err := p.roundTrip(req, ctx)
+			if err == nil {
+				resp.Body.Close()
+			}
+			byproducts, err := p.Byproducts()
+			if err != nil {
+				t.Fatalf("Byproducts: %v", err)
+			}
+			var names []string
+			for _, b := range byproducts {
+				names = append(names, b.Name)
+			}
+			if diff := cmp.Diff(tt.byproducts, names); diff != "" {
+				t.Fatalf("unexpected byproducts (-want +got): \n%s", diff)
+			}
+			_, callback := p.callbacks.Load(ctx.Session)
+			_, responded := p.responded.Load(ctx.Session)
+			if failed := len(tt.byproducts) > 0; callback == failed || responded == failed {
+				t.Fatalf("unexpected session state: callback %v, responded %v", callback, responded)
+			}
+		})
+	}
+}
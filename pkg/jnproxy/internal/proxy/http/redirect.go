@@ -0,0 +1,170 @@
+package http
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	handler "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+// Clients follow redirects with new requests, which goproxy handles
+// in new sessions. We link them by the client and the Location of the redirect.
+// Clients may follow a redirect on a new connection, e.g. to another host,
+// so several chains may be pending for the same client and Location, e.g.
+// for parallel downloads of the same file. A request takes the chain
+// redirected on its connection if any, and the oldest one otherwise.
+// Redirects not followed within redirectTimeout are forgotten.
+const redirectTimeout = time.Minute
+
+type chain struct {
+	handler    handler.Handler // Handler of the original request.
+	redirects  []handler.Redirect
+	remoteAddr string // Client connection of the last redirect.
+	time       time.Time
+}
+
+// redirectKey returns the key of the chains pending for the client
+// of req and the location.
+func redirectKey(req *http.Request, location *url.URL) string {
+	client := req.RemoteAddr
+	if host, _, err := net.SplitHostPort(client); err == nil {
+		client = host
+	}
+	return client + " " + normalizeURL(location)
+}
+
+func isRedirect(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return resp.Header.Get("Location") != ""
+	}
+	return false
+}
+
+// normalizeURL returns a key to match a Location header
+// with the request that follows it.
+func normalizeURL(u *url.URL) string {
+	host := strings.ToLower(u.Host)
+	switch {
+	case u.Scheme == "https":
+		host = strings.TrimSuffix(host, ":443")
+	case u.Scheme == "http":
+		host = strings.TrimSuffix(host, ":80")
+	}
+	key := u.Scheme + "://" + host + u.EscapedPath()
+	if u.RawQuery != "" {
+		key += "?" + u.RawQuery
+	}
+	return key
+}
+
+// recordRedirect records that the request of session was redirected,
+// so that the request following the redirect can be linked to it.
+func (p *Proxy) recordRedirect(session int64, h handler.Handler, req *http.Request, resp *http.Response) error {
+	location, err := req.URL.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return err
+	}
+	c := chain{
+		handler: h,
+	}
+	if v, ok := p.chains.Load(session); ok {
+		c = v.(chain)
+	}
+	c.redirects = append(append([]handler.Redirect{}, c.redirects...), handler.Redirect{
+		Req:        req,
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+	})
+	c.remoteAddr = req.RemoteAddr
+	c.time = time.Now()
+
+	key := redirectKey(req, location)
+	p.redirectMu.Lock()
+	defer p.redirectMu.Unlock()
+	p.expireRedirects()
+	p.redirects[key] = append(p.redirects[key], c)
+	return nil
+}
+
+// expireRedirects forgets the redirects not followed in time.
+// WARNING: p.redirectMu must be held.
+func (p *Proxy) expireRedirects() {
+	for key, chains := range p.redirects {
+		fresh := chains[:0]
+		for _, c := range chains {
+			if time.Since(c.time) <= redirectTimeout {
+				fresh = append(fresh, c)
+			}
+		}
+		if len(fresh) == 0 {
+			delete(p.redirects, key)
+			continue
+		}
+		p.redirects[key] = fresh
+	}
+}
+
+// redirected returns the chain that led the client to req, if any.
+func (p *Proxy) redirected(req *http.Request) (chain, bool) {
+	key := redirectKey(req, req.URL)
+	p.redirectMu.Lock()
+	defer p.redirectMu.Unlock()
+	p.expireRedirects()
+	chains, ok := p.redirects[key]
+	if !ok {
+		return chain{}, false
+	}
+	i := 0
+	for j, c := range chains {
+		if c.remoteAddr == req.RemoteAddr {
+			i = j
+			break
+		}
+	}
+	c := chains[i]
+	chains = append(chains[:i:i], chains[i+1:]...)
+	if len(chains) == 0 {
+		delete(p.redirects, key)
+	} else {
+		p.redirects[key] = chains
+	}
+	return c, true
+}
+
+// linkRedirects records the redirects that led to req in deps.
+// The requested URI is recorded as the uri, unless the handler
+// identified the dependency differently, and the final location
+// as the downloadLocation. Handlers may identify the dependency by the
+// location with its query, so such URIs are replaced by the origin too.
+func linkRedirects(deps []slsa.ResourceDescriptor, redirects []handler.Redirect, req *http.Request) {
+	if len(redirects) == 0 {
+		return
+	}
+	origin := urlOf(redirects[0].Req)
+	location := urlOf(req)
+	var hops []map[string]any
+	for _, r := range redirects {
+		hops = append(hops, map[string]any{
+			"Method": r.Req.Method,
+			"URL":    urlOf(r.Req),
+			"Status": r.StatusCode,
+		})
+	}
+	for i := range deps {
+		rd := &deps[i]
+		if rd.URI == "" || rd.URI == location || strings.HasPrefix(rd.URI, location+"?") {
+			rd.URI = origin
+		}
+		rd.DownloadLocation = location
+		if rd.Annotations == nil {
+			rd.Annotations = map[string]any{}
+		}
+		rd.Annotations["Redirects"] = hops
+	}
+}
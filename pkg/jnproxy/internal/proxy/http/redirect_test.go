@@ -0,0 +1,198 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	handler "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+type fakeHandler struct {
+	handler.HandlerImpl
+}
+
+func (h *fakeHandler) OnRequest(req *http.Request, ctx handler.Context) (*http.Request, *http.Response, bool, error) {
+	return req, nil, true, nil
+}
+
+func (h *fakeHandler) OnResponse(resp *http.Response, ctx handler.Context) (*http.Response, error) {
+	return resp, nil
+}
+
+func newRequest(url, remoteAddr string) *http.Request {
+	req := httptest.NewRequest("GET", url, nil)
+	req.RemoteAddr = remoteAddr
+	return req
+}
+
+func redirectResponse(req *http.Request, location string) *http.Response {
+	resp := handler.NewResponse(req, handler.ContentTypeText, http.StatusFound, "")
+	resp.Header.Set("Location", location)
+	return resp
+}
+
+// origins returns the URLs of the requests of c.
+func origins(c chain) []string {
+	var urls []string
+	for _, r := range c.redirects {
+		urls = append(urls, r.Req.URL.String())
+	}
+	return urls
+}
+
+func Test_redirected(t *testing.T) {
+	t.Parallel()
+	const (
+		hub = "https://huggingface.co/owner/name/resolve/main/model.bin"
+		cdn = "https://cdn-lfs.huggingface.co/repos/aa/bb/model.bin"
+	)
+	h := &fakeHandler{}
+	h.SetName("Fake/v0.1")
+
+	t.Run("single hop", func(t *testing.T) {
+		t.Parallel()
+		p, err := New("localhost:0")
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		req := newRequest(hub, "10.0.0.1:1000")
+		if err := p.recordRedirect(1, h, req, redirectResponse(req, cdn)); err != nil {
+			t.Fatalf("recordRedirect: %v", err)
+		}
+		// The client follows the redirect on a new connection.
+		c, ok := p.redirected(newRequest(cdn, "10.0.0.1:2000"))
+		if !ok {
+			t.Fatalf("redirect not found")
+		}
+		if diff := cmp.Diff([]string{hub}, origins(c)); diff != "" {
+			t.Fatalf("unexpected chain (-want +got): \n%s", diff)
+		}
+		if c.handler != h {
+			t.Fatalf("unexpected handler: %v", c.handler)
+		}
+		// The chain is consumed.
+		if _, ok := p.redirected(newRequest(cdn, "10.0.0.1:2000")); ok {
+			t.Fatalf("redirect found twice")
+		}
+	})
+
+	t.Run("other client", func(t *testing.T) {
+		t.Parallel()
+		p, err := New("localhost:0")
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		req := newRequest(hub, "10.0.0.1:1000")
+		if err := p.recordRedirect(1, h, req, redirectResponse(req, cdn)); err != nil {
+			t.Fatalf("recordRedirect: %v", err)
+		}
+		if _, ok := p.redirected(newRequest(cdn, "10.0.0.2:1000")); ok {
+			t.Fatalf("redirect of another client found")
+		}
+	})
+
+	t.Run("multi hop", func(t *testing.T) {
+		t.Parallel()
+		p, err := New("localhost:0")
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		const mirror = "https://huggingface.co/owner/name/resolve/v1/model.bin"
+		first := newRequest(hub, "10.0.0.1:1000")
+		if err := p.recordRedirect(1, h, first, redirectResponse(first, "/owner/name/resolve/v1/model.bin")); err != nil {
+			t.Fatalf("recordRedirect: %v", err)
+		}
+		second := newRequest(mirror, "10.0.0.1:1000")
+		c, ok := p.redirected(second)
+		if !ok {
+			t.Fatalf("first redirect not found")
+		}
+		// The proxy tracks the chain of the session that follows the redirect.
+		p.chains.Store(int64(2), c)
+		if err := p.recordRedirect(2, h, second, redirectResponse(second, cdn)); err != nil {
+			t.Fatalf("recordRedirect: %v", err)
+		}
+		final := newRequest(cdn, "10.0.0.1:2000")
+		c, ok = p.redirected(final)
+		if !ok {
+			t.Fatalf("second redirect not found")
+		}
+		if diff := cmp.Diff([]string{hub, mirror}, origins(c)); diff != "" {
+			t.Fatalf("unexpected chain (-want +got): \n%s", diff)
+		}
+		deps := []slsa.ResourceDescriptor{{URI: "cdn-lfs.huggingface.co/repos/aa/bb/model.bin"}}
+		linkRedirects(deps, c.redirects, final)
+		if diff := cmp.Diff("huggingface.co/owner/name/resolve/main/model.bin", deps[0].URI); diff != "" {
+			t.Fatalf("unexpected uri (-want +got): \n%s", diff)
+		}
+		if diff := cmp.Diff("cdn-lfs.huggingface.co/repos/aa/bb/model.bin", deps[0].DownloadLocation); diff != "" {
+			t.Fatalf("unexpected download location (-want +got): \n%s", diff)
+		}
+		if hops := deps[0].Annotations["Redirects"].([]map[string]any); len(hops) != 2 {
+			t.Fatalf("unexpected hops: %v", hops)
+		}
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		t.Parallel()
+		p, err := New("localhost:0")
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		const other = "https://huggingface.co/owner/name/resolve/v1/model.bin"
+		// Two downloads redirected to the same location on different connections.
+		for i, req := range []*http.Request{newRequest(hub, "10.0.0.1:1000"), newRequest(other, "10.0.0.1:1001")} {
+			if err := p.recordRedirect(int64(i), h, req, redirectResponse(req, cdn)); err != nil {
+				t.Fatalf("recordRedirect: %v", err)
+			}
+		}
+		// A request on the connection of a redirect takes its chain.
+		c, ok := p.redirected(newRequest(cdn, "10.0.0.1:1001"))
+		if !ok {
+			t.Fatalf("redirect not found")
+		}
+		if diff := cmp.Diff([]string{other}, origins(c)); diff != "" {
+			t.Fatalf("unexpected chain (-want +got): \n%s", diff)
+		}
+		// Otherwise, the oldest chain.
+		c, ok = p.redirected(newRequest(cdn, "10.0.0.1:3000"))
+		if !ok {
+			t.Fatalf("redirect not found")
+		}
+		if diff := cmp.Diff([]string{hub}, origins(c)); diff != "" {
+			t.Fatalf("unexpected chain (-want +got): \n%s", diff)
+		}
+		if _, ok := p.redirected(newRequest(cdn, "10.0.0.1:3000")); ok {
+			t.Fatalf("redirect found three times")
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		t.Parallel()
+		p, err := New("localhost:0")
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		req := newRequest(hub, "10.0.0.1:1000")
+		if err := p.recordRedirect(1, h, req, redirectResponse(req, cdn)); err != nil {
+			t.Fatalf("recordRedirect: %v", err)
+		}
+		p.redirectMu.Lock()
+		for _, chains := range p.redirects {
+			chains[0].time = time.Now().Add(-2 * redirectTimeout)
+		}
+		p.redirectMu.Unlock()
+		if _, ok := p.redirected(newRequest(cdn, "10.0.0.1:1000")); ok {
+			t.Fatalf("expired redirect found")
+		}
+		p.redirectMu.Lock()
+		defer p.redirectMu.Unlock()
+		if len(p.redirects) != 0 {
+			t.Fatalf("expired redirects not forgotten: %v", p.redirects)
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package jserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
+)
+
+// See https://jupyter-client.readthedocs.io/en/latest/messaging.html#the-wire-protocol.
+const delimiter = "<IDS|MSG>"
+
+type Header struct {
+	MsgID   string `json:"msg_id"`
+	MsgType string `json:"msg_type"`
+	Session string `json:"session"`
+}
+
+// Message is a Jupyter message exchanged on a channel,
+// e.g. shell or iopub.
+type Message struct {
+	Channel      string
+	Header       Header
+	ParentHeader Header
+	Metadata     json.RawMessage
+	Content      json.RawMessage
+}
+
+// parseMessage parses the frames of a Jupyter message:
+// the routing identities, the delimiter, the signature,
+// the header, the parent header, the metadata, the content
+// and optional buffers.
+func parseMessage(channel string, frames [][]byte) (*Message, error) {
+	i := 0
+	for i < len(frames) && !bytes.Equal(frames[i], []byte(delimiter)) {
+		i++
+	}
+	if len(frames)-i < 6 {
+		return nil, fmt.Errorf("%w: message has (%d) frames", errs.ErrorInvalid, len(frames))
+	}
+	m := Message{
+		Channel:  channel,
+		Metadata: frames[i+4],
+		Content:  frames[i+5],
+	}
+	if err := json.Unmarshal(frames[i+2], &m.Header); err != nil {
+		return nil, fmt.Errorf("%w: header: %w", errs.ErrorInvalid, err)
+	}
+	// The parent header is empty for messages that are not replies.
+	if err := json.Unmarshal(frames[i+3], &m.ParentHeader); err != nil {
+		return nil, fmt.Errorf("%w: parent header: %w", errs.ErrorInvalid, err)
+	}
+	return &m, nil
+}
@@ -37,6 +37,7 @@ type Proxy struct {
 	repoClient repository.Client
 	mu         sync.Mutex
 	counter    *atomic.Uint64
+	observer   func(*Message)
 }
 
 type Option func(*Proxy) error
@@ -73,6 +74,19 @@ func (p *Proxy) setLogger(l logger.Logger) error {
 	return nil
 }
 
+// WithObserver calls observer with the messages exchanged in both
+// directions. The observer is called concurrently, and must not block.
+func WithObserver(observer func(*Message)) Option {
+	return func(p *Proxy) error {
+		return p.setObserver(observer)
+	}
+}
+
+func (p *Proxy) setObserver(observer func(*Message)) error {
+	p.observer = observer
+	return nil
+}
+
 func (p *Proxy) Start() error {
 	if p.listener != nil {
 		return fmt.Errorf("[jserver]: proxy already running")
@@ -96,6 +110,10 @@ func (p *Proxy) Dependencies() ([]slsa.ResourceDescriptor, error) {
 	return nil, nil
 }
 
+func (p *Proxy) Byproducts() ([]slsa.ResourceDescriptor, error) {
+	return nil, nil
+}
+
 func (p *Proxy) serve() {
 	defer p.wg.Done()
 
@@ -182,6 +200,10 @@ func (p *Proxy) forward(src, dst net.Conn, record bool) {
 	defer p.closeConn(src)
 	defer p.closeConn(dst)
 	buf := make([]byte, 2048)
+	var dec *decoder
+	if p.observer != nil {
+		dec = &decoder{}
+	}
 	for {
 		n, err := src.Read(buf)
 		if err != nil && err != io.EOF {
@@ -206,6 +228,10 @@ func (p *Proxy) forward(src, dst net.Conn, record bool) {
 			}
 		}
 
+		if dec != nil {
+			p.observe(dec, buf[:n], src, dst)
+		}
+
 		// Copy data to dst.
 		_, err = dst.Write(buf[:n])
 		if err != nil {
@@ -216,6 +242,26 @@ func (p *Proxy) forward(src, dst net.Conn, record bool) {
 	}
 }
 
+// observe decodes the messages in data and passes them to the observer.
+// Decoding errors are not fatal: the data is forwarded regardless.
+func (p *Proxy) observe(dec *decoder, data []byte, src, dst net.Conn) {
+	if dec.err != nil {
+		return
+	}
+	msgs, err := dec.Write(data)
+	for _, frames := range msgs {
+		m, err := parseMessage(p.binding.Name, frames)
+		if err != nil {
+			p.logger.Warnf("[jserver]: forward (%q -> %q) parse message: %v", p.connID(src), p.connID(dst), err)
+			continue
+		}
+		p.observer(m)
+	}
+	if err != nil {
+		p.logger.Warnf("[jserver]: forward (%q -> %q) stop decoding: %v", p.connID(src), p.connID(dst), err)
+	}
+}
+
 func (p *Proxy) connID(conn net.Conn) string {
 	return fmt.Sprintf("%s/%s", p.binding.Name, conn.RemoteAddr().String())
 }
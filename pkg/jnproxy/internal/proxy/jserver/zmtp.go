@@ -0,0 +1,92 @@
+package jserver
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
+)
+
+// ZMTP 3.x framing, see https://rfc.zeromq.org/spec/23/.
+const (
+	greetingLen = 64
+	flagMore    = 0x01
+	flagLong    = 0x02
+	flagCommand = 0x04
+	// Larger frames are not decoded, e.g. large outputs.
+	maxFrameSize = 64 << 20
+)
+
+// decoder extracts the messages of a ZMTP stream.
+// Only the NULL security mechanism is supported,
+// which is what Jupyter uses.
+type decoder struct {
+	buf     []byte
+	greeted bool
+	frames  [][]byte
+	err     error
+}
+
+// Write appends p to the stream and returns the messages completed,
+// as a list of frames. Commands are skipped. Once it fails, the decoder
+// returns the same error.
+func (d *decoder) Write(p []byte) ([][][]byte, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	d.buf = append(d.buf, p...)
+	var msgs [][][]byte
+	start := 0
+	for {
+		b := d.buf[start:]
+		if !d.greeted {
+			if len(b) < greetingLen {
+				break
+			}
+			// Signature.
+			if b[0] != 0xff || b[9] != 0x7f {
+				d.err = fmt.Errorf("%w: zmtp signature", errs.ErrorInvalid)
+				return msgs, d.err
+			}
+			// Major version.
+			if b[10] < 3 {
+				d.err = fmt.Errorf("%w: zmtp version (%d)", errs.ErrorInvalid, b[10])
+				return msgs, d.err
+			}
+			d.greeted = true
+			start += greetingLen
+			continue
+		}
+		if len(b) < 2 {
+			break
+		}
+		flags := b[0]
+		size, headerLen := uint64(b[1]), 2
+		if flags&flagLong != 0 {
+			if len(b) < 9 {
+				break
+			}
+			size, headerLen = binary.BigEndian.Uint64(b[1:9]), 9
+		}
+		if size > maxFrameSize {
+			d.err = fmt.Errorf("%w: zmtp frame size (%d)", errs.ErrorInvalid, size)
+			return msgs, d.err
+		}
+		if uint64(len(b)-headerLen) < size {
+			break
+		}
+		body := b[headerLen : headerLen+int(size)]
+		start += headerLen + int(size)
+		if flags&flagCommand != 0 {
+			continue
+		}
+		d.frames = append(d.frames, append([]byte{}, body...))
+		if flags&flagMore == 0 {
+			msgs = append(msgs, d.frames)
+			d.frames = nil
+		}
+	}
+	// Keep the incomplete data only.
+	d.buf = append([]byte{}, d.buf[start:]...)
+	return msgs, nil
+}
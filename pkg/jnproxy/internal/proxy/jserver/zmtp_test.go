@@ -0,0 +1,137 @@
+package jserver
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
+)
+
+func greeting(major byte) []byte {
+	g := make([]byte, greetingLen)
+	g[0] = 0xff
+	g[9] = 0x7f
+	g[10] = major
+	copy(g[12:], "NULL")
+	return g
+}
+
+func frame(flags byte, body string) []byte {
+	if len(body) > 255 {
+		b := []byte{flags | flagLong}
+		b = binary.BigEndian.AppendUint64(b, uint64(len(body)))
+		return append(b, body...)
+	}
+	return append([]byte{flags, byte(len(body))}, body...)
+}
+
+func concat(parts ...[]byte) []byte {
+	var res []byte
+	for _, p := range parts {
+		res = append(res, p...)
+	}
+	return res
+}
+
+func Test_decoder(t *testing.T) {
+	t.Parallel()
+	long := string(make([]byte, 300))
+	stream := concat(
+		greeting(3),
+		frame(flagCommand, "\x05READY"),
+		frame(flagMore, "id"),
+		frame(0, "last"),
+		frame(0, long),
+	)
+	tests := []struct {
+		name     string
+		stream   []byte
+		chunk    int
+		msgs     [][][]byte
+		expected error
+	}{
+		{
+			name:   "one write",
+			stream: stream,
+			chunk:  len(stream),
+			msgs:   [][][]byte{{[]byte("id"), []byte("last")}, {[]byte(long)}},
+		},
+		{
+			name:   "byte per byte",
+			stream: stream,
+			chunk:  1,
+			msgs:   [][][]byte{{[]byte("id"), []byte("last")}, {[]byte(long)}},
+		},
+		{
+			name:     "invalid signature",
+			stream:   make([]byte, greetingLen),
+			chunk:    greetingLen,
+			expected: errs.ErrorInvalid,
+		},
+		{
+			name:     "zmtp 2",
+			stream:   greeting(2),
+			chunk:    greetingLen,
+			expected: errs.ErrorInvalid,
+		},
+		{
+			name:     "frame too large",
+			stream:   concat(greeting(3), []byte{flagLong, 0xff, 0, 0, 0, 0, 0, 0, 0}),
+			chunk:    100,
+			expected: errs.ErrorInvalid,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var dec decoder
+			var msgs [][][]byte
+			var err error
+			for i := 0; i < len(tt.stream) && err == nil; i += tt.chunk {
+				var m [][][]byte
+				m, err = dec.Write(tt.stream[i:min(i+tt.chunk, len(tt.stream))])
+				msgs = append(msgs, m...)
+			}
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.msgs, msgs); diff != "" {
+				t.Fatalf("unexpected messages (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_parseMessage(t *testing.T) {
+	t.Parallel()
+	frames := [][]byte{
+		[]byte("identity"),
+		[]byte(delimiter),
+		[]byte("signature"),
+		[]byte(`{"msg_id":"2","msg_type":"kernel_info_reply","session":"s"}`),
+		[]byte(`{"msg_id":"1","msg_type":"kernel_info_request","session":"s"}`),
+		[]byte(`{}`),
+		[]byte(`{"status":"ok"}`),
+	}
+	m, err := parseMessage("shell", frames)
+	if err != nil {
+		t.Fatalf("parseMessage: %v", err)
+	}
+	expected := &Message{
+		Channel:      "shell",
+		Header:       Header{MsgID: "2", MsgType: "kernel_info_reply", Session: "s"},
+		ParentHeader: Header{MsgID: "1", MsgType: "kernel_info_request", Session: "s"},
+		Metadata:     []byte(`{}`),
+		Content:      []byte(`{"status":"ok"}`),
+	}
+	if diff := cmp.Diff(expected, m); diff != "" {
+		t.Fatalf("unexpected message (-want +got): \n%s", diff)
+	}
+	_, err = parseMessage("shell", frames[:5])
+	if diff := cmp.Diff(errs.ErrorInvalid, err, cmpopts.EquateErrors()); diff != "" {
+		t.Fatalf("unexpected err (-want +got): \n%s", diff)
+	}
+}
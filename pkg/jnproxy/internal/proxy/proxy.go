@@ -14,4 +14,13 @@ type Proxy interface {
 	Stop() error
 	Type() Type
 	Dependencies() ([]slsa.ResourceDescriptor, error)
+	// Byproducts returns the attempts that did not result in dependencies,
+	// e.g. denied or failed requests.
+	Byproducts() ([]slsa.ResourceDescriptor, error)
+}
+
+// SubjectProvider is implemented by proxies that identify artifacts
+// published during the session.
+type SubjectProvider interface {
+	Subjects() ([]slsa.Subject, error)
 }
@@ -0,0 +1,104 @@
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/proxy/jserver"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+// Cell is the execution of a cell, identified by
+// the msg_id of its execute_request.
+type Cell struct {
+	MsgID string
+	// ExecutionCount is the number the kernel gives the execution,
+	// e.g. 7 for In [7]. It is 0 until the kernel announces it.
+	ExecutionCount int
+}
+
+// Annotation returns the representation of the cell in annotations.
+func (c Cell) Annotation() map[string]any {
+	res := map[string]any{
+		"MsgID": c.MsgID,
+	}
+	if c.ExecutionCount != 0 {
+		res["ExecutionCount"] = c.ExecutionCount
+	}
+	return res
+}
+
+// Clock tracks the cell executing in the kernel, from the messages
+// exchanged with it: a cell executes between the busy and idle status
+// messages on IOPub whose parent is its execute_request.
+// See https://jupyter-client.readthedocs.io/en/latest/messaging.html#kernel-status.
+// It also records the sources of the cells executed.
+// The zero value is ready to use.
+type Clock struct {
+	mu       sync.Mutex
+	current  *Cell
+	executed []slsa.Cell
+}
+
+// Observe updates the clock with a message exchanged with the kernel.
+func (c *Clock) Observe(m *jserver.Message) {
+	if m.Channel != "iopub" || m.ParentHeader.MsgType != "execute_request" {
+		return
+	}
+	switch m.Header.MsgType {
+	case "status":
+		var content struct {
+			ExecutionState string `json:"execution_state"`
+		}
+		if err := json.Unmarshal(m.Content, &content); err != nil {
+			return
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		switch content.ExecutionState {
+		case "busy":
+			c.current = &Cell{MsgID: m.ParentHeader.MsgID}
+		case "idle":
+			if c.current != nil && c.current.MsgID == m.ParentHeader.MsgID {
+				c.current = nil
+			}
+		}
+	case "execute_input":
+		var content struct {
+			Code           string `json:"code"`
+			ExecutionCount int    `json:"execution_count"`
+		}
+		if err := json.Unmarshal(m.Content, &content); err != nil {
+			return
+		}
+		h := sha256.Sum256([]byte(content.Code))
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.executed = append(c.executed, slsa.Cell{
+			ExecutionCount: content.ExecutionCount,
+			DigestSet:      slsa.DigestSet{"sha256": hex.EncodeToString(h[:])},
+		})
+		if c.current != nil && c.current.MsgID == m.ParentHeader.MsgID {
+			c.current.ExecutionCount = content.ExecutionCount
+		}
+	}
+}
+
+// Current returns the cell executing, if any.
+func (c *Clock) Current() (Cell, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.current == nil {
+		return Cell{}, false
+	}
+	return *c.current, true
+}
+
+// Executed returns the cells executed so far, in order.
+func (c *Clock) Executed() []slsa.Cell {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]slsa.Cell{}, c.executed...)
+}
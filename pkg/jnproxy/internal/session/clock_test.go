@@ -0,0 +1,119 @@
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/proxy/jserver"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+func message(channel, msgType, parentID, parentType, content string) *jserver.Message {
+	return &jserver.Message{
+		Channel:      channel,
+		Header:       jserver.Header{MsgType: msgType},
+		ParentHeader: jserver.Header{MsgID: parentID, MsgType: parentType},
+		Content:      []byte(content),
+	}
+}
+
+func sha256Hex(content string) string {
+	h := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(h[:])
+}
+
+func Test_Clock(t *testing.T) {
+	t.Parallel()
+	type current struct {
+		cell Cell
+		ok   bool
+	}
+	tests := []struct {
+		name     string
+		messages []*jserver.Message
+		expected current
+	}{
+		{
+			name: "idle",
+		},
+		{
+			name: "busy",
+			messages: []*jserver.Message{
+				message("iopub", "status", "1", "execute_request", `{"execution_state":"busy"}`),
+			},
+			expected: current{cell: Cell{MsgID: "1"}, ok: true},
+		},
+		{
+			name: "execute input",
+			messages: []*jserver.Message{
+				message("iopub", "status", "1", "execute_request", `{"execution_state":"busy"}`),
+				message("iopub", "execute_input", "1", "execute_request", `{"code":"x = 1","execution_count":7}`),
+			},
+			expected: current{cell: Cell{MsgID: "1", ExecutionCount: 7}, ok: true},
+		},
+		{
+			name: "busy then idle",
+			messages: []*jserver.Message{
+				message("iopub", "status", "1", "execute_request", `{"execution_state":"busy"}`),
+				message("iopub", "status", "1", "execute_request", `{"execution_state":"idle"}`),
+			},
+		},
+		{
+			name: "idle of another request",
+			messages: []*jserver.Message{
+				message("iopub", "status", "2", "execute_request", `{"execution_state":"busy"}`),
+				message("iopub", "status", "1", "execute_request", `{"execution_state":"idle"}`),
+			},
+			expected: current{cell: Cell{MsgID: "2"}, ok: true},
+		},
+		{
+			name: "not an execution",
+			messages: []*jserver.Message{
+				message("iopub", "status", "1", "kernel_info_request", `{"execution_state":"busy"}`),
+				message("shell", "status", "2", "execute_request", `{"execution_state":"busy"}`),
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var c Clock
+			for _, m := range tt.messages {
+				c.Observe(m)
+			}
+			cell, ok := c.Current()
+			if diff := cmp.Diff(tt.expected, current{cell: cell, ok: ok}, cmp.AllowUnexported(current{})); diff != "" {
+				t.Fatalf("unexpected cell (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Clock_Executed(t *testing.T) {
+	t.Parallel()
+	var c Clock
+	for _, m := range []*jserver.Message{
+		message("iopub", "status", "1", "execute_request", `{"execution_state":"busy"}`),
+		message("iopub", "execute_input", "1", "execute_request", `{"code":"x = 1","execution_count":1}`),
+		message("iopub", "status", "1", "execute_request", `{"execution_state":"idle"}`),
+		message("iopub", "execute_input", "2", "execute_request", `{"code":"","execution_count":2}`),
+	} {
+		c.Observe(m)
+	}
+	expected := []slsa.Cell{
+		{
+			ExecutionCount: 1,
+			DigestSet:      slsa.DigestSet{"sha256": sha256Hex("x = 1")},
+		},
+		{
+			ExecutionCount: 2,
+			DigestSet:      slsa.DigestSet{"sha256": sha256Hex("")},
+		},
+	}
+	if diff := cmp.Diff(expected, c.Executed()); diff != "" {
+		t.Fatalf("unexpected cells (-want +got): \n%s", diff)
+	}
+}
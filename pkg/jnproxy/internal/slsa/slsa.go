@@ -9,25 +9,23 @@ import (
 )
 
 type Provenance struct {
-	attestation attestation
+	attestation slsa.Statement
 }
 
 type Option func(*Provenance) error
 
 func New(builder slsa.Builder, subjects []slsa.Subject, repo slsa.ResourceDescriptor, opts ...Option) (*Provenance, error) {
 	p := Provenance{
-		attestation: attestation{
-			Header: Header{
-				Type:          statementType,
-				PredicateType: predicateType,
-				Subjects:      append([]slsa.Subject{}, subjects...),
-			},
-			Predicate: Predicate{
-				BuildDefinition: BuildDefinition{
-					BuildType:            buildType,
+		attestation: slsa.Statement{
+			Type:          slsa.StatementType,
+			PredicateType: slsa.PredicateType,
+			Subjects:      append([]slsa.Subject{}, subjects...),
+			Predicate: slsa.Predicate{
+				BuildDefinition: slsa.BuildDefinition{
+					BuildType:            slsa.BuildType,
 					ResolvedDependencies: append([]slsa.ResourceDescriptor{}, repo), // NOTE: Make a copy.
 				},
-				RunDetails: RunDetails{
+				RunDetails: slsa.RunDetails{
 					Builder: builder, // TODO: Should make a copy?
 				},
 			},
@@ -55,6 +53,50 @@ func (p *Provenance) addDependencies(deps []slsa.ResourceDescriptor) error {
 	return nil
 }
 
+func AddByproducts(byproducts []slsa.ResourceDescriptor) Option {
+	return func(p *Provenance) error {
+		return p.addByproducts(byproducts)
+	}
+}
+
+func (p *Provenance) addByproducts(byproducts []slsa.ResourceDescriptor) error {
+	p.attestation.Predicate.RunDetails.Byproducts = append(p.attestation.Predicate.RunDetails.Byproducts, byproducts...)
+	return nil
+}
+
+func WithExternalParameters(params slsa.ExternalParameters) Option {
+	return func(p *Provenance) error {
+		return p.withExternalParameters(params)
+	}
+}
+
+func (p *Provenance) withExternalParameters(params slsa.ExternalParameters) error {
+	p.attestation.Predicate.BuildDefinition.ExternalParameters = params
+	return nil
+}
+
+func WithInternalParameters(params slsa.InternalParameters) Option {
+	return func(p *Provenance) error {
+		return p.withInternalParameters(params)
+	}
+}
+
+func (p *Provenance) withInternalParameters(params slsa.InternalParameters) error {
+	p.attestation.Predicate.BuildDefinition.InternalParameters = &params
+	return nil
+}
+
+func WithInvocationID(id string) Option {
+	return func(p *Provenance) error {
+		return p.withInvocationID(id)
+	}
+}
+
+func (p *Provenance) withInvocationID(id string) error {
+	p.attestation.Predicate.RunDetails.BuildMetadata.InvocationID = id
+	return nil
+}
+
 func WithStartTime(t time.Time) Option {
 	return func(p *Provenance) error {
 		return p.withStartTime(t)
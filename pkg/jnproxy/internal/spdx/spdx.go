@@ -0,0 +1,264 @@
+package spdx
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/bom"
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/version"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+// See https://spdx.github.io/spdx-spec/v3.0.1/.
+
+const (
+	specVersion  = "3.0.1"
+	context      = "https://spdx.org/rdf/3.0.1/spdx-context.jsonld"
+	creationInfo = "_:creationinfo"
+)
+
+type document struct {
+	Context string `json:"@context"`
+	Graph   []any  `json:"@graph"`
+}
+
+type creation struct {
+	Type         string   `json:"type"`
+	ID           string   `json:"@id"`
+	SpecVersion  string   `json:"specVersion"`
+	Created      string   `json:"created"`
+	CreatedBy    []string `json:"createdBy"`
+	CreatedUsing []string `json:"createdUsing,omitempty"`
+}
+
+// element holds the properties of the elements we emit.
+type element struct {
+	Type               string   `json:"type"`
+	SpdxID             string   `json:"spdxId"`
+	CreationInfo       string   `json:"creationInfo"`
+	Name               string   `json:"name,omitempty"`
+	Comment            string   `json:"comment,omitempty"`
+	VerifiedUsing      []hash   `json:"verifiedUsing,omitempty"`
+	PackageVersion     string   `json:"software_packageVersion,omitempty"`
+	PackageURL         string   `json:"software_packageUrl,omitempty"`
+	DownloadLocation   string   `json:"software_downloadLocation,omitempty"`
+	PrimaryPurpose     string   `json:"software_primaryPurpose,omitempty"`
+	DatasetType        []string `json:"dataset_datasetType,omitempty"`
+	From               string   `json:"from,omitempty"`
+	RelationshipType   string   `json:"relationshipType,omitempty"`
+	To                 []string `json:"to,omitempty"`
+	RootElement        []string `json:"rootElement,omitempty"`
+	Element            []string `json:"element,omitempty"`
+	SbomType           []string `json:"software_sbomType,omitempty"`
+	ProfileConformance []string `json:"profileConformance,omitempty"`
+}
+
+type hash struct {
+	Type      string `json:"type"`
+	Algorithm string `json:"algorithm"`
+	HashValue string `json:"hashValue"`
+}
+
+// Digest algorithms with an SPDX name. Others, e.g. gitCommit, are not recorded.
+var algorithms = map[string]string{
+	"md5":      "md5",
+	"sha1":     "sha1",
+	"sha256":   "sha256",
+	"sha384":   "sha384",
+	"sha512":   "sha512",
+	"sha3-256": "sha3_256",
+	"sha3-512": "sha3_512",
+}
+
+type Document struct {
+	namespace string
+	created   time.Time
+	subjects  []bom.Component
+	deps      []bom.Component
+}
+
+type Option func(*Document) error
+
+// New returns an SPDX 3 document with the AI and Dataset profiles,
+// listing the subjects, which depend on all the dependencies of the session.
+func New(subjects []slsa.Subject, opts ...Option) (*Document, error) {
+	d := Document{
+		namespace: "urn:jnproxy",
+		created:   time.Now(),
+		subjects:  bom.Subjects(subjects),
+	}
+	for _, option := range opts {
+		if err := option(&d); err != nil {
+			return nil, err
+		}
+	}
+	return &d, nil
+}
+
+func AddDependencies(deps []slsa.ResourceDescriptor) Option {
+	return func(d *Document) error {
+		return d.addDependencies(deps)
+	}
+}
+
+func (d *Document) addDependencies(deps []slsa.ResourceDescriptor) error {
+	d.deps = append(d.deps, bom.Dependencies(deps)...)
+	return nil
+}
+
+// WithNamespace sets the IRI the IDs of the elements are relative to.
+func WithNamespace(namespace string) Option {
+	return func(d *Document) error {
+		return d.withNamespace(namespace)
+	}
+}
+
+func (d *Document) withNamespace(namespace string) error {
+	d.namespace = namespace
+	return nil
+}
+
+func WithCreationTime(t time.Time) Option {
+	return func(d *Document) error {
+		return d.withCreationTime(t)
+	}
+}
+
+func (d *Document) withCreationTime(t time.Time) error {
+	d.created = t
+	return nil
+}
+
+func (d *Document) id(ref string) string {
+	return d.namespace + "#" + ref
+}
+
+func (d *Document) toElement(c *bom.Component) element {
+	e := element{
+		Type:             "software_Package",
+		SpdxID:           d.id(c.Ref),
+		CreationInfo:     creationInfo,
+		Name:             c.Name,
+		PackageVersion:   c.Version,
+		PackageURL:       c.PURL,
+		DownloadLocation: c.URI,
+	}
+	switch c.Kind {
+	case slsa.KindModel:
+		e.Type = "ai_AIPackage"
+		e.PrimaryPurpose = "model"
+	case slsa.KindDataset:
+		e.Type = "dataset_DatasetPackage"
+		e.PrimaryPurpose = "data"
+		e.DatasetType = []string{"other"}
+	case slsa.KindPackage:
+		e.PrimaryPurpose = "library"
+	case slsa.KindCode:
+		e.PrimaryPurpose = "source"
+	default:
+		e.PrimaryPurpose = "file"
+	}
+	for _, alg := range c.Algorithms() {
+		if name, ok := algorithms[alg]; ok {
+			e.VerifiedUsing = append(e.VerifiedUsing, hash{Type: "Hash", Algorithm: name, HashValue: c.DigestSet[alg]})
+		}
+	}
+	if c.Handler != "" {
+		e.Comment = fmt.Sprintf("Recorded by handler %s", c.Handler)
+		if c.Ecosystem != "" {
+			e.Comment += fmt.Sprintf(" from ecosystem %s", c.Ecosystem)
+		}
+	}
+	return e
+}
+
+func (d *Document) subjectElement(c *bom.Component) element {
+	e := element{
+		Type:         "software_File",
+		SpdxID:       d.id(c.Ref),
+		CreationInfo: creationInfo,
+		Name:         c.Name,
+	}
+	for _, alg := range c.Algorithms() {
+		if name, ok := algorithms[alg]; ok {
+			e.VerifiedUsing = append(e.VerifiedUsing, hash{Type: "Hash", Algorithm: name, HashValue: c.DigestSet[alg]})
+		}
+	}
+	return e
+}
+
+func (d *Document) ToBytes() ([]byte, error) {
+	agent := element{
+		Type:         "SoftwareAgent",
+		SpdxID:       d.id("jnproxy"),
+		CreationInfo: creationInfo,
+		Name:         "jnproxy",
+	}
+	tool := element{
+		Type:         "Tool",
+		SpdxID:       d.id("jnproxy-" + version.Version),
+		CreationInfo: creationInfo,
+		Name:         "jnproxy " + version.Version,
+	}
+	graph := []any{
+		creation{
+			Type:         "CreationInfo",
+			ID:           creationInfo,
+			SpecVersion:  specVersion,
+			Created:      d.created.UTC().Format(time.RFC3339),
+			CreatedBy:    []string{agent.SpdxID},
+			CreatedUsing: []string{tool.SpdxID},
+		},
+		agent,
+		tool,
+	}
+	var elements, roots, deps []string
+	for i := range d.deps {
+		e := d.toElement(&d.deps[i])
+		graph = append(graph, e)
+		elements = append(elements, e.SpdxID)
+		deps = append(deps, e.SpdxID)
+	}
+	for i := range d.subjects {
+		e := d.subjectElement(&d.subjects[i])
+		graph = append(graph, e)
+		elements = append(elements, e.SpdxID)
+		roots = append(roots, e.SpdxID)
+		if len(deps) == 0 {
+			continue
+		}
+		r := element{
+			Type:             "Relationship",
+			SpdxID:           d.id(fmt.Sprintf("relationship-%d", i)),
+			CreationInfo:     creationInfo,
+			From:             e.SpdxID,
+			RelationshipType: "dependsOn",
+			To:               deps,
+		}
+		graph = append(graph, r)
+		elements = append(elements, r.SpdxID)
+	}
+	sbom := element{
+		Type:         "software_Sbom",
+		SpdxID:       d.id("sbom"),
+		CreationInfo: creationInfo,
+		RootElement:  roots,
+		Element:      elements,
+		SbomType:     []string{"runtime"},
+	}
+	doc := element{
+		Type:               "SpdxDocument",
+		SpdxID:             d.id("document"),
+		CreationInfo:       creationInfo,
+		RootElement:        []string{sbom.SpdxID},
+		Element:            append(append([]string{agent.SpdxID, tool.SpdxID}, elements...), sbom.SpdxID),
+		ProfileConformance: []string{"core", "software", "ai", "dataset"},
+	}
+	graph = append(graph, sbom, doc)
+	content, err := json.Marshal(document{Context: context, Graph: graph})
+	if err != nil {
+		return nil, fmt.Errorf("marshal: %w", err)
+	}
+	return content, nil
+}
@@ -0,0 +1,201 @@
+package spdx
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/version"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+// parse returns the creation info and the elements of the graph.
+func parse(t *testing.T, content []byte) (creation, []element) {
+	var doc struct {
+		Context string            `json:"@context"`
+		Graph   []json.RawMessage `json:"@graph"`
+	}
+	if err := json.Unmarshal(content, &doc); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if diff := cmp.Diff(context, doc.Context); diff != "" {
+		t.Fatalf("unexpected context (-want +got): \n%s", diff)
+	}
+	if len(doc.Graph) == 0 {
+		t.Fatalf("empty graph")
+	}
+	var c creation
+	if err := json.Unmarshal(doc.Graph[0], &c); err != nil {
+		t.Fatalf("unmarshal creation info: %v", err)
+	}
+	var elements []element
+	for _, raw := range doc.Graph[1:] {
+		var e element
+		if err := json.Unmarshal(raw, &e); err != nil {
+			t.Fatalf("unmarshal element: %v", err)
+		}
+		elements = append(elements, e)
+	}
+	return c, elements
+}
+
+func Test_ToBytes(t *testing.T) {
+	t.Parallel()
+	model := slsa.ResourceDescriptor{
+		Name:      "pkg:huggingface/org/model@abc#model.safetensors",
+		URI:       "hf://org/model@main/model.safetensors",
+		DigestSet: slsa.DigestSet{"sha256": "aa", "gitCommit": "abc"},
+		Annotations: map[string]any{
+			"Handler":     "HuggingfaceModel/v0.1",
+			"Huggingface": map[string]any{"Commit": "abc"},
+		},
+	}
+	model.SetDependency(slsa.KindModel, slsa.EcosystemHuggingface)
+	dataset := slsa.ResourceDescriptor{
+		URI:         "hf://datasets/org/data@main/train.csv",
+		DigestSet:   slsa.DigestSet{"sha256": "bb"},
+		Annotations: map[string]any{"Handler": "HuggingfaceDataset/v0.1"},
+	}
+	dataset.SetDependency(slsa.KindDataset, slsa.EcosystemHuggingface)
+	code := slsa.ResourceDescriptor{
+		Name:        "github.com/pytorch/vision@main",
+		URI:         "github.com/pytorch/vision/zipball/main",
+		DigestSet:   slsa.DigestSet{"sha256": "cc"},
+		Annotations: map[string]any{"Handler": "PytorchHub/v0.1"},
+	}
+	code.SetDependency(slsa.KindCode, slsa.EcosystemPytorchHub)
+	repo := slsa.ResourceDescriptor{
+		Name:      slsa.RepositoryName,
+		DigestSet: slsa.DigestSet{"gitCommit": "dd"},
+	}
+	subjects := []slsa.Subject{
+		{Name: "out/model.safetensors", DigestSet: slsa.DigestSet{"sha256": "ee"}},
+	}
+	const ns = "urn:example:session"
+	d, err := New(subjects,
+		AddDependencies([]slsa.ResourceDescriptor{model, dataset, code, repo}),
+		WithNamespace(ns),
+		WithCreationTime(time.Date(2024, 4, 6, 2, 20, 8, 0, time.FixedZone("PDT", -7*3600))),
+	)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	content, err := d.ToBytes()
+	if err != nil {
+		t.Fatalf("ToBytes: %v", err)
+	}
+	c, elements := parse(t, content)
+
+	agent, tool := ns+"#jnproxy", ns+"#jnproxy-"+version.Version
+	expectedCreation := creation{
+		Type:         "CreationInfo",
+		ID:           creationInfo,
+		SpecVersion:  "3.0.1",
+		Created:      "2024-04-06T09:20:08Z",
+		CreatedBy:    []string{agent},
+		CreatedUsing: []string{tool},
+	}
+	if diff := cmp.Diff(expectedCreation, c); diff != "" {
+		t.Fatalf("unexpected creation info (-want +got): \n%s", diff)
+	}
+	deps := []string{ns + "#dependency-0", ns + "#dependency-1", ns + "#dependency-2", ns + "#dependency-3"}
+	expected := []element{
+		{Type: "SoftwareAgent", SpdxID: agent, CreationInfo: creationInfo, Name: "jnproxy"},
+		{Type: "Tool", SpdxID: tool, CreationInfo: creationInfo, Name: "jnproxy " + version.Version},
+		{
+			Type:             "ai_AIPackage",
+			SpdxID:           deps[0],
+			CreationInfo:     creationInfo,
+			Name:             model.URI,
+			Comment:          "Recorded by handler HuggingfaceModel/v0.1 from ecosystem huggingface",
+			VerifiedUsing:    []hash{{Type: "Hash", Algorithm: "sha256", HashValue: "aa"}},
+			PackageVersion:   "abc",
+			PackageURL:       model.Name,
+			DownloadLocation: model.URI,
+			PrimaryPurpose:   "model",
+		},
+		{
+			Type:             "dataset_DatasetPackage",
+			SpdxID:           deps[1],
+			CreationInfo:     creationInfo,
+			Name:             dataset.URI,
+			Comment:          "Recorded by handler HuggingfaceDataset/v0.1 from ecosystem huggingface",
+			VerifiedUsing:    []hash{{Type: "Hash", Algorithm: "sha256", HashValue: "bb"}},
+			DownloadLocation: dataset.URI,
+			PrimaryPurpose:   "data",
+			DatasetType:      []string{"other"},
+		},
+		{
+			Type:             "software_Package",
+			SpdxID:           deps[2],
+			CreationInfo:     creationInfo,
+			Name:             code.Name,
+			Comment:          "Recorded by handler PytorchHub/v0.1 from ecosystem pytorch-hub",
+			VerifiedUsing:    []hash{{Type: "Hash", Algorithm: "sha256", HashValue: "cc"}},
+			DownloadLocation: code.URI,
+			PrimaryPurpose:   "source",
+		},
+		{
+			// The git commit of the repository has no SPDX algorithm.
+			Type:           "software_Package",
+			SpdxID:         deps[3],
+			CreationInfo:   creationInfo,
+			Name:           slsa.RepositoryName,
+			PrimaryPurpose: "file",
+		},
+		{
+			Type:          "software_File",
+			SpdxID:        ns + "#subject-0",
+			CreationInfo:  creationInfo,
+			Name:          "out/model.safetensors",
+			VerifiedUsing: []hash{{Type: "Hash", Algorithm: "sha256", HashValue: "ee"}},
+		},
+		{
+			Type:             "Relationship",
+			SpdxID:           ns + "#relationship-0",
+			CreationInfo:     creationInfo,
+			From:             ns + "#subject-0",
+			RelationshipType: "dependsOn",
+			To:               deps,
+		},
+		{
+			Type:         "software_Sbom",
+			SpdxID:       ns + "#sbom",
+			CreationInfo: creationInfo,
+			RootElement:  []string{ns + "#subject-0"},
+			Element:      append(append([]string{}, deps...), ns+"#subject-0", ns+"#relationship-0"),
+			SbomType:     []string{"runtime"},
+		},
+		{
+			Type:               "SpdxDocument",
+			SpdxID:             ns + "#document",
+			CreationInfo:       creationInfo,
+			RootElement:        []string{ns + "#sbom"},
+			Element:            append(append([]string{agent, tool}, deps...), ns+"#subject-0", ns+"#relationship-0", ns+"#sbom"),
+			ProfileConformance: []string{"core", "software", "ai", "dataset"},
+		},
+	}
+	if diff := cmp.Diff(expected, elements); diff != "" {
+		t.Fatalf("unexpected elements (-want +got): \n%s", diff)
+	}
+}
+
+func Test_ToBytes_noDependencies(t *testing.T) {
+	t.Parallel()
+	d, err := New([]slsa.Subject{{Name: "out/model.safetensors", DigestSet: slsa.DigestSet{"sha256": "ee"}}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	content, err := d.ToBytes()
+	if err != nil {
+		t.Fatalf("ToBytes: %v", err)
+	}
+	_, elements := parse(t, content)
+	// Subjects without dependencies have no relationship.
+	for _, e := range elements {
+		if e.Type == "Relationship" {
+			t.Fatalf("unexpected relationship: %v", e)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+package version
+
+// Version of jnproxy, recorded in the dependencies it records.
+// It can be set at build time with
+// -ldflags "-X github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/version.Version=v1.2.3".
+var Version = "v0.1.0"
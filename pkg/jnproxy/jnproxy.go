@@ -1,17 +1,25 @@
 package jnproxy
 
 import (
+	"bytes"
+	"crypto"
 	"fmt"
+	"io"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
 	httphandler "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http"
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http/huggingface"
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/handler/http/policy"
 	logimpl "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/logger"
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/outputs"
 	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/proxy"
 	httpproxy "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/proxy/http"
 	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/proxy/jserver"
-	slsaimpl "github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/slsa"
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/session"
+	"github.com/laurentsimon/jupyter-lineage/pkg/lockfile"
 	"github.com/laurentsimon/jupyter-lineage/pkg/logger"
 	"github.com/laurentsimon/jupyter-lineage/pkg/repository"
 	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
@@ -26,6 +34,8 @@ const (
 )
 
 type JNProxy struct {
+	// Guards state, which Checkpoint reads while the session runs.
+	stateMu      sync.Mutex
 	state        state
 	repoClient   repository.Client
 	proxies      []proxy.Proxy
@@ -35,6 +45,29 @@ type JNProxy struct {
 	provenance   []byte
 	ca           *CA
 	httpHandlers []httphandler.Handler
+	policy       *policy.Policy
+	// Shared by the Huggingface handlers.
+	hfResolutions *huggingface.Resolutions
+	// Outputs.
+	outputPatterns []string
+	outputDir      string
+	outputSnapshot *outputs.Snapshot
+	// Parameters.
+	notebookPath       string
+	notebook           *slsa.Notebook
+	internalParameters slsa.InternalParameters
+	mu                 sync.Mutex
+	kernel             *slsa.Kernel
+	// Session.
+	clock               session.Clock
+	sessionID           string
+	builderDependencies []slsa.ResourceDescriptor
+	// Serializes the accesses to the repository.
+	repoMu sync.Mutex
+	// Checkpoints.
+	checkpointMu   sync.Mutex
+	checkpoints    int
+	lastCheckpoint *slsa.ResourceDescriptor
 }
 
 type Option func(*JNProxy) error
@@ -113,7 +146,11 @@ func New(jServerConfig JServerConfig, httpConfig HttpConfig, repoClient reposito
 	// Set the proxy last, since we need to have the logger setup.
 	for i := range addressBinding {
 		b := &addressBinding[i]
-		proxy, err := jserver.New(*b, jnproxy.repoClient, &jnproxy.counter, jserver.WithLogger(jnproxy.logger))
+		// Files are recorded under the session ID.
+		repoClient := &sessionClient{Client: jnproxy.repoClient, proxy: &jnproxy}
+		proxy, err := jserver.New(*b, repoClient, &jnproxy.counter,
+			jserver.WithLogger(jnproxy.logger),
+			jserver.WithObserver(jnproxy.observe))
 		if err != nil {
 			return nil, err
 		}
@@ -121,19 +158,41 @@ func New(jServerConfig JServerConfig, httpConfig HttpConfig, repoClient reposito
 	}
 
 	// Create the http proxy.
+	handlers, err := jnproxy.policyHandlers()
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range handlers {
+		jnproxy.internalParameters.Handlers = append(jnproxy.internalParameters.Handlers, h.Name())
+	}
 	opts := []httpproxy.Option{
 		httpproxy.WithLogger(jnproxy.logger),
-		httpproxy.WithHandlers(jnproxy.httpHandlers),
+		httpproxy.WithHandlers(handlers),
+		httpproxy.WithClock(&jnproxy.clock),
 	}
+	// The CA is read once, since each proxy needs it.
+	var cert, key []byte
 	if jnproxy.ca != nil {
-		opts = append(opts, httpproxy.WithCA(httpproxy.CA{
-			Certificate: jnproxy.ca.Certificate,
-			Key:         jnproxy.ca.Key,
-		}))
+		if cert, err = io.ReadAll(jnproxy.ca.Certificate); err != nil {
+			return nil, fmt.Errorf("read certificate: %w", err)
+		}
+		if key, err = io.ReadAll(jnproxy.ca.Key); err != nil {
+			return nil, fmt.Errorf("read key: %w", err)
+		}
+		if jnproxy.internalParameters.CA, err = caDigest(cert); err != nil {
+			return nil, err
+		}
 	}
 	for i := range httpConfig.addr {
 		addr := &httpConfig.addr[i]
-		httpProxy, err := httpproxy.New(*addr, opts...)
+		proxyOpts := append([]httpproxy.Option{}, opts...)
+		if jnproxy.ca != nil {
+			proxyOpts = append(proxyOpts, httpproxy.WithCA(httpproxy.CA{
+				Certificate: bytes.NewReader(cert),
+				Key:         bytes.NewReader(key),
+			}))
+		}
+		httpProxy, err := httpproxy.New(*addr, proxyOpts...)
 		if err != nil {
 			return nil, err
 		}
@@ -152,10 +211,31 @@ func (s *JNProxy) Start() error {
 		return fmt.Errorf("%w: state %q", errs.ErrorInvalid, s.state)
 	}
 
+	sessionID, err := newSessionID()
+	if err != nil {
+		return err
+	}
+	s.sessionID = sessionID
+	s.builderDependencies = s.computeBuilderDependencies()
+
 	if err := s.repoClient.Init(); err != nil {
 		return err
 	}
 
+	if s.outputDir != "" {
+		snapshot, err := outputs.NewSnapshot(s.outputDir)
+		if err != nil {
+			return err
+		}
+		s.outputSnapshot = snapshot
+	}
+
+	if s.notebookPath != "" {
+		if err := s.hashNotebook(); err != nil {
+			return err
+		}
+	}
+
 	// Start proxies last.
 	for i := range s.proxies {
 		p := s.proxies[i]
@@ -165,13 +245,17 @@ func (s *JNProxy) Start() error {
 	}
 
 	// Update the JNProxy state.
+	s.stateMu.Lock()
 	s.state = stateStarted
+	s.stateMu.Unlock()
 	s.startTime = time.Now()
 	return nil
 }
 
 func (s *JNProxy) Stop() error {
 	// TODO: don't return early on error, innstead try to clean up as much as we can.
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
 	if s.state == stateFinished {
 		return fmt.Errorf("%w: state %q", errs.ErrorInvalid, s.state)
 	}
@@ -189,23 +273,35 @@ func (s *JNProxy) Stop() error {
 	return nil
 }
 
+// Provenance returns the provenance of the session. Its subjects are
+// subjects followed by the outputs configured via WithOutputs and WithOutputDir.
+// It chains to the last checkpoint, if any.
 func (s *JNProxy) Provenance(builder slsa.Builder, subjects []slsa.Subject, repoURI string) ([]byte, error) {
 	if s.state != stateFinished {
 		return nil, fmt.Errorf("%w: state %q", errs.ErrorInvalid, s.state)
 	}
 	if s.provenance != nil {
-		return s.provenance, nil
+		return append([]byte{}, s.provenance...), nil
 	}
-	// digestSet, err := s.repoClient.Digest()
-	// if err != nil {
-	// 	return nil, err
-	// }
-	digestSet := slsa.DigestSet{"bla": "val"}
-	repo := slsa.ResourceDescriptor{
-		DigestSet: digestSet,
-		URI:       repoURI,
+	prov, err := s.attest(builder, subjects, repoURI)
+	if err != nil {
+		return nil, err
 	}
-	// Get runtime dependencies.
+	s.provenance = prov
+	return append([]byte{}, s.provenance...), nil
+}
+
+// SignedProvenance returns the provenance wrapped in a DSSE envelope
+// signed by signer. See slsa.Sign for the supported keys.
+func (s *JNProxy) SignedProvenance(builder slsa.Builder, subjects []slsa.Subject, repoURI string, signer crypto.Signer, keyID string) ([]byte, error) {
+	prov, err := s.Provenance(builder, subjects, repoURI)
+	if err != nil {
+		return nil, err
+	}
+	return slsa.Sign(prov, signer, keyID)
+}
+
+func (s *JNProxy) runtimeDependencies() ([]slsa.ResourceDescriptor, error) {
 	var deps []slsa.ResourceDescriptor
 	for i := range s.proxies {
 		p := s.proxies[i]
@@ -218,21 +314,55 @@ func (s *JNProxy) Provenance(builder slsa.Builder, subjects []slsa.Subject, repo
 		}
 		deps = append(deps, d...)
 	}
+	return deps, nil
+}
 
-	prov, err := slsaimpl.New(builder, subjects, repo,
-		slsaimpl.WithStartTime(s.startTime),
-		slsaimpl.WithFinishTime(time.Now()),
-		slsaimpl.AddDependencies(deps),
-	)
-	if err != nil {
-		return nil, err
+func (s *JNProxy) runtimeByproducts() ([]slsa.ResourceDescriptor, error) {
+	var byproducts []slsa.ResourceDescriptor
+	for i := range s.proxies {
+		p := s.proxies[i]
+		if p.Type() == proxy.TypeUserSource {
+			continue
+		}
+		b, err := p.Byproducts()
+		if err != nil {
+			return nil, err
+		}
+		byproducts = append(byproducts, b...)
 	}
-	// TODO: Add dependencies collected from the http proxies.
-	s.provenance, err = prov.ToBytes()
+	return byproducts, nil
+}
+
+// runtimeSubjects returns the artifacts published during the session,
+// e.g. the files uploaded to the Hugging Face Hub.
+func (s *JNProxy) runtimeSubjects() ([]slsa.Subject, error) {
+	var subjects []slsa.Subject
+	for i := range s.proxies {
+		p, ok := s.proxies[i].(proxy.SubjectProvider)
+		if !ok {
+			continue
+		}
+		sub, err := p.Subjects()
+		if err != nil {
+			return nil, err
+		}
+		subjects = append(subjects, sub...)
+	}
+	return subjects, nil
+}
+
+// Lockfile returns a lockfile pinning the runtime dependencies
+// of the session, e.g. to enforce them in the next session
+// via InstallLockfile.
+func (s *JNProxy) Lockfile() (*lockfile.Lockfile, error) {
+	if s.state != stateFinished {
+		return nil, fmt.Errorf("%w: state %q", errs.ErrorInvalid, s.state)
+	}
+	deps, err := s.runtimeDependencies()
 	if err != nil {
 		return nil, err
 	}
-	return append([]byte{}, s.provenance...), nil
+	return lockfile.FromDependencies(deps)
 }
 
 func WithLogger(l logger.Logger) Option {
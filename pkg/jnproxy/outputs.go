@@ -0,0 +1,85 @@
+package jnproxy
+
+import (
+	"fmt"
+
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/outputs"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+// WithOutputs adds the files matching the patterns to the subjects
+// of the provenance, e.g. the saved model files. See filepath.Match
+// for the syntax. Matching directories are walked. Files are hashed
+// when the provenance is generated.
+func WithOutputs(patterns ...string) Option {
+	return func(p *JNProxy) error {
+		return p.setOutputs(patterns)
+	}
+}
+
+func (p *JNProxy) setOutputs(patterns []string) error {
+	for _, v := range patterns {
+		if v == "" {
+			return fmt.Errorf("%w: empty output pattern", errs.ErrorInvalid)
+		}
+	}
+	p.outputPatterns = append(p.outputPatterns, patterns...)
+	return nil
+}
+
+// WithOutputDir adds the files the kernel writes to dir during the session
+// to the subjects of the provenance. Files created or modified between
+// Start and the generation of the provenance are considered written.
+func WithOutputDir(dir string) Option {
+	return func(p *JNProxy) error {
+		return p.setOutputDir(dir)
+	}
+}
+
+func (p *JNProxy) setOutputDir(dir string) error {
+	if dir == "" {
+		return fmt.Errorf("%w: empty output directory", errs.ErrorInvalid)
+	}
+	p.outputDir = dir
+	return nil
+}
+
+// outputSubjects returns subjects followed by the artifacts published
+// during the session and the outputs, not already in subjects.
+func (p *JNProxy) outputSubjects(subjects []slsa.Subject) ([]slsa.Subject, error) {
+	outs, err := p.runtimeSubjects()
+	if err != nil {
+		return nil, fmt.Errorf("published subjects: %w", err)
+	}
+	if len(p.outputPatterns) > 0 {
+		globbed, unmatched, err := outputs.Glob(p.outputPatterns...)
+		if err != nil {
+			return nil, fmt.Errorf("outputs: %w", err)
+		}
+		for _, pattern := range unmatched {
+			p.logger.Warnf("output pattern (%q) matches no file", pattern)
+		}
+		outs = append(outs, globbed...)
+	}
+	if p.outputSnapshot != nil {
+		changed, err := p.outputSnapshot.Changed()
+		if err != nil {
+			return nil, fmt.Errorf("output directory: %w", err)
+		}
+		outs = append(outs, changed...)
+	}
+	res := append([]slsa.Subject{}, subjects...)
+	seen := make(map[string]bool, len(subjects))
+	for _, s := range subjects {
+		seen[s.Name] = true
+	}
+	for _, s := range outs {
+		if seen[s.Name] {
+			continue
+		}
+		seen[s.Name] = true
+		res = append(res, s)
+	}
+	return res, nil
+}
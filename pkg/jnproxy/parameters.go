@@ -0,0 +1,114 @@
+package jnproxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/proxy/jserver"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+// WithNotebook records the notebook run in the session. The notebook
+// is hashed when the session starts, since it is the input of the session.
+func WithNotebook(path string) Option {
+	return func(p *JNProxy) error {
+		return p.setNotebook(path)
+	}
+}
+
+func (p *JNProxy) setNotebook(path string) error {
+	if path == "" {
+		return fmt.Errorf("%w: empty notebook path", errs.ErrorInvalid)
+	}
+	p.notebookPath = path
+	return nil
+}
+
+func sha256Digest(r io.Reader) (slsa.DigestSet, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, err
+	}
+	return slsa.DigestSet{"sha256": hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+func (p *JNProxy) hashNotebook() error {
+	f, err := os.Open(p.notebookPath)
+	if err != nil {
+		return fmt.Errorf("notebook: %w", err)
+	}
+	defer f.Close()
+	digest, err := sha256Digest(f)
+	if err != nil {
+		return fmt.Errorf("notebook (%q): %w", p.notebookPath, err)
+	}
+	p.notebook = &slsa.Notebook{
+		Path:      p.notebookPath,
+		DigestSet: digest,
+	}
+	return nil
+}
+
+// caDigest returns the digest of the DER certificate in cert.
+func caDigest(cert []byte) (slsa.DigestSet, error) {
+	block, _ := pem.Decode(cert)
+	if block == nil {
+		return nil, fmt.Errorf("%w: no PEM certificate", errs.ErrorInvalid)
+	}
+	h := sha256.Sum256(block.Bytes)
+	return slsa.DigestSet{"sha256": hex.EncodeToString(h[:])}, nil
+}
+
+// kernelInfoReply is the content of kernel_info_reply messages.
+// See https://jupyter-client.readthedocs.io/en/latest/messaging.html#kernel-info.
+type kernelInfoReply struct {
+	ProtocolVersion       string `json:"protocol_version"`
+	Implementation        string `json:"implementation"`
+	ImplementationVersion string `json:"implementation_version"`
+	LanguageInfo          struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"language_info"`
+}
+
+// observe is called with the messages exchanged with the kernel.
+func (p *JNProxy) observe(m *jserver.Message) {
+	p.clock.Observe(m)
+	if m.Header.MsgType != "kernel_info_reply" {
+		return
+	}
+	var reply kernelInfoReply
+	if err := json.Unmarshal(m.Content, &reply); err != nil {
+		p.logger.Warnf("kernel_info_reply: %v", err)
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.kernel = &slsa.Kernel{
+		Implementation:        reply.Implementation,
+		ImplementationVersion: reply.ImplementationVersion,
+		Language:              reply.LanguageInfo.Name,
+		LanguageVersion:       reply.LanguageInfo.Version,
+		ProtocolVersion:       reply.ProtocolVersion,
+	}
+}
+
+func (p *JNProxy) externalParameters() slsa.ExternalParameters {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	params := slsa.ExternalParameters{
+		Notebook: p.notebook,
+		Cells:    p.clock.Executed(),
+	}
+	if p.kernel != nil {
+		kernel := *p.kernel
+		params.Kernel = &kernel
+	}
+	return params
+}
@@ -0,0 +1,82 @@
+package jnproxy
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+
+	"github.com/laurentsimon/jupyter-lineage/pkg/jnproxy/internal/version"
+	"github.com/laurentsimon/jupyter-lineage/pkg/repository"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+// newSessionID returns a random UUID (version 4).
+func newSessionID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("session id: %w", err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// SessionID returns the ID of the session, generated by Start.
+// It is recorded as the invocation ID of the provenance, and prefixes
+// the files of the repository, e.g. to correlate them with other logs.
+func (s *JNProxy) SessionID() string {
+	return s.sessionID
+}
+
+// sessionClient prefixes the files created in the
+// repository with the session ID. It also serializes the creation
+// of files with the checkpoints, which commit the repository.
+type sessionClient struct {
+	repository.Client
+	proxy *JNProxy
+}
+
+func (c *sessionClient) CreateFile(p string, content []byte) error {
+	c.proxy.repoMu.Lock()
+	defer c.proxy.repoMu.Unlock()
+	return c.Client.CreateFile(path.Join(c.proxy.sessionID, p), content)
+}
+
+// computeBuilderDependencies returns the jnproxy binary and the Go
+// version it is built with.
+func (s *JNProxy) computeBuilderDependencies() []slsa.ResourceDescriptor {
+	deps := []slsa.ResourceDescriptor{
+		{
+			Name: "go",
+			URI:  "pkg:golang/stdlib@" + strings.TrimPrefix(runtime.Version(), "go"),
+		},
+	}
+	fn, err := os.Executable()
+	if err != nil {
+		s.logger.Warnf("executable: %v", err)
+		return deps
+	}
+	f, err := os.Open(fn)
+	if err != nil {
+		s.logger.Warnf("executable: %v", err)
+		return deps
+	}
+	defer f.Close()
+	digest, err := sha256Digest(f)
+	if err != nil {
+		s.logger.Warnf("executable (%q): %v", fn, err)
+		return deps
+	}
+	return append([]slsa.ResourceDescriptor{
+		{
+			Name:      "jnproxy",
+			DigestSet: digest,
+			Annotations: map[string]any{
+				"Version": version.Version,
+			},
+		},
+	}, deps...)
+}
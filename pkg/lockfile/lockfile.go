@@ -0,0 +1,240 @@
+package lockfile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+const version = 1
+
+// Entry pins the content of a dependency.
+type Entry struct {
+	URI       string         `json:"uri"`
+	DigestSet slsa.DigestSet `json:"digest"`
+	// URL the content was downloaded from, without scheme nor query,
+	// if it differs from the uri. Requests to it that carry no
+	// content, e.g. HEAD requests, are not refused.
+	Location string `json:"location,omitempty"`
+	// Informational fields.
+	Handler   string `json:"handler,omitempty"`
+	MediaType string `json:"mediaType,omitempty"`
+}
+
+// Lockfile maps dependency URIs to their expected digests.
+type Lockfile struct {
+	Version int     `json:"version"`
+	Entries []Entry `json:"entries"`
+	// URIs observed with different digests, e.g. dynamic API responses.
+	// They cannot be pinned, and their content is not verified.
+	Unpinned  []string `json:"unpinned,omitempty"`
+	index     map[string]int
+	locations map[string]bool
+}
+
+// New returns a lockfile with the entries, sorted by URI.
+func New(entries []Entry) (*Lockfile, error) {
+	l := &Lockfile{
+		Version: version,
+		Entries: append([]Entry{}, entries...),
+	}
+	sort.SliceStable(l.Entries, func(i, j int) bool {
+		return l.Entries[i].URI < l.Entries[j].URI
+	})
+	if err := l.validate(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Parse reads a lockfile in JSON format.
+func Parse(r io.Reader) (*Lockfile, error) {
+	var l Lockfile
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&l); err != nil {
+		return nil, fmt.Errorf("%w: decode: %w", errs.ErrorInvalid, err)
+	}
+	if l.Version != version {
+		return nil, fmt.Errorf("%w: version (%d)", errs.ErrorInvalid, l.Version)
+	}
+	if err := l.validate(); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func (l *Lockfile) validate() error {
+	l.index = make(map[string]int, len(l.Entries))
+	l.locations = make(map[string]bool)
+	for i, e := range l.Entries {
+		if e.URI == "" {
+			return fmt.Errorf("%w: entry #%d has no uri", errs.ErrorInvalid, i)
+		}
+		if len(e.DigestSet) == 0 {
+			return fmt.Errorf("%w: entry (%q) has no digest", errs.ErrorInvalid, e.URI)
+		}
+		if _, ok := l.index[e.URI]; ok {
+			return fmt.Errorf("%w: duplicate entry (%q)", errs.ErrorInvalid, e.URI)
+		}
+		l.index[e.URI] = i
+		if e.Location != "" {
+			l.locations[e.Location] = true
+		}
+	}
+	for i, uri := range l.Unpinned {
+		if uri == "" {
+			return fmt.Errorf("%w: unpinned uri #%d is empty", errs.ErrorInvalid, i)
+		}
+		if _, ok := l.index[uri]; ok {
+			return fmt.Errorf("%w: uri (%q) both pinned and unpinned", errs.ErrorInvalid, uri)
+		}
+	}
+	return nil
+}
+
+// FromDependencies returns a lockfile pinning the dependencies.
+// Dependencies recorded several times are pinned once. URIs with
+// different digests cannot be pinned: they are listed as unpinned.
+func FromDependencies(deps []slsa.ResourceDescriptor) (*Lockfile, error) {
+	var entries []Entry
+	var unpinned []string
+	seen := make(map[string]int)
+	conflicts := make(map[string]bool)
+	for _, rd := range deps {
+		if rd.URI == "" || len(rd.DigestSet) == 0 {
+			continue
+		}
+		if i, ok := seen[rd.URI]; ok {
+			if err := entries[i].Verify(rd.DigestSet); err != nil && !conflicts[rd.URI] {
+				conflicts[rd.URI] = true
+				unpinned = append(unpinned, rd.URI)
+			}
+			continue
+		}
+		e := Entry{
+			URI:       rd.URI,
+			DigestSet: make(slsa.DigestSet, len(rd.DigestSet)),
+			MediaType: rd.MediaType,
+		}
+		if rd.DownloadLocation != rd.URI {
+			e.Location = rd.DownloadLocation
+		}
+		for k, v := range rd.DigestSet {
+			e.DigestSet[k] = v
+		}
+		if name, ok := rd.Annotations["Handler"].(string); ok {
+			e.Handler = name
+		}
+		seen[rd.URI] = len(entries)
+		entries = append(entries, e)
+	}
+	pinned := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		if !conflicts[e.URI] {
+			pinned = append(pinned, e)
+		}
+	}
+	l, err := New(pinned)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(unpinned)
+	l.Unpinned = unpinned
+	if err := l.validate(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// FromProvenance returns a lockfile pinning the runtime dependencies
+// recorded in a provenance, e.g. as generated by JNProxy.Provenance.
+// Runtime dependencies are those annotated with the handler that
+// recorded them: other dependencies, like the source repository,
+// are not pinnable.
+func FromProvenance(content []byte) (*Lockfile, error) {
+	statement, err := slsa.ParseProvenance(content)
+	if err != nil {
+		return nil, err
+	}
+	var deps []slsa.ResourceDescriptor
+	for _, rd := range statement.Predicate.BuildDefinition.ResolvedDependencies {
+		if _, ok := rd.Annotations["Handler"].(string); !ok {
+			continue
+		}
+		deps = append(deps, rd)
+	}
+	return FromDependencies(deps)
+}
+
+// Lookup returns the entry of uri.
+func (l *Lockfile) Lookup(uri string) (Entry, bool) {
+	i, ok := l.index[uri]
+	if !ok {
+		return Entry{}, false
+	}
+	return l.Entries[i], true
+}
+
+// IsUnpinned returns true if uri is listed as unpinned.
+func (l *Lockfile) IsUnpinned(uri string) bool {
+	for _, v := range l.Unpinned {
+		if v == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// Contains returns true if uri is pinned, unpinned
+// or the location of a pinned entry.
+func (l *Lockfile) Contains(uri string) bool {
+	_, ok := l.index[uri]
+	return ok || l.locations[uri] || l.IsUnpinned(uri)
+}
+
+// Verify returns an error unless the digests match the entry.
+// Digests must have at least one algorithm in common with the entry,
+// and all the common ones must match.
+func (e *Entry) Verify(digests slsa.DigestSet) error {
+	common := 0
+	for alg, expected := range e.DigestSet {
+		actual, ok := digests[alg]
+		if !ok {
+			continue
+		}
+		if actual != expected {
+			return fmt.Errorf("%w: %s digest mismatch. Expected (%v) != actual (%v)", errs.ErrorDenied, alg, expected, actual)
+		}
+		common++
+	}
+	if common == 0 {
+		return fmt.Errorf("%w: no common digest algorithm", errs.ErrorDenied)
+	}
+	return nil
+}
+
+// ToBytes returns the JSON representation of the lockfile.
+func (l *Lockfile) ToBytes() ([]byte, error) {
+	b, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal: %w", err)
+	}
+	return b, nil
+}
+
+// Digest returns the digest of the JSON representation of the lockfile.
+func (l *Lockfile) Digest() (slsa.DigestSet, error) {
+	b, err := l.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.Sum256(b)
+	return slsa.DigestSet{"sha256": hex.EncodeToString(h[:])}, nil
+}
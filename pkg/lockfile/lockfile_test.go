@@ -0,0 +1,282 @@
+package lockfile
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+func Test_FromDependencies(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		deps     []slsa.ResourceDescriptor
+		entries  []Entry
+		unpinned []string
+		expected error
+	}{
+		{
+			name: "sorted and deduplicated",
+			deps: []slsa.ResourceDescriptor{
+				{
+					URI:       "hf://owner/name@main/config.json",
+					DigestSet: slsa.DigestSet{"sha256": "bb"},
+					MediaType: "application/json",
+					Annotations: map[string]any{
+						"Handler": "HuggingfaceModel/v0.1",
+					},
+				},
+				{
+					URI:       "example.com/a",
+					DigestSet: slsa.DigestSet{"sha256": "aa"},
+				},
+				{
+					URI:       "example.com/a",
+					DigestSet: slsa.DigestSet{"sha256": "aa", "md5": "cc"},
+				},
+				{
+					// No digest.
+					URI: "example.com/b",
+				},
+			},
+			entries: []Entry{
+				{
+					URI:       "example.com/a",
+					DigestSet: slsa.DigestSet{"sha256": "aa"},
+				},
+				{
+					URI:       "hf://owner/name@main/config.json",
+					DigestSet: slsa.DigestSet{"sha256": "bb"},
+					Handler:   "HuggingfaceModel/v0.1",
+					MediaType: "application/json",
+				},
+			},
+		},
+		{
+			name: "mutable uri",
+			deps: []slsa.ResourceDescriptor{
+				{
+					URI:       "example.com/a",
+					DigestSet: slsa.DigestSet{"sha256": "aa"},
+				},
+				{
+					URI:       "example.com/b",
+					DigestSet: slsa.DigestSet{"sha256": "bb"},
+				},
+				{
+					URI:       "example.com/a",
+					DigestSet: slsa.DigestSet{"sha256": "cc"},
+				},
+				{
+					URI:       "example.com/a",
+					DigestSet: slsa.DigestSet{"sha256": "dd"},
+				},
+			},
+			entries: []Entry{
+				{
+					URI:       "example.com/b",
+					DigestSet: slsa.DigestSet{"sha256": "bb"},
+				},
+			},
+			unpinned: []string{"example.com/a"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			l, err := FromDependencies(tt.deps)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.entries, l.Entries); diff != "" {
+				t.Fatalf("unexpected entries (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.unpinned, l.Unpinned); diff != "" {
+				t.Fatalf("unexpected unpinned (-want +got): \n%s", diff)
+			}
+			for _, uri := range tt.unpinned {
+				if !l.IsUnpinned(uri) {
+					t.Fatalf("uri (%q) not unpinned", uri)
+				}
+			}
+			// Round trip.
+			b, err := l.ToBytes()
+			if err != nil {
+				t.Fatalf("ToBytes: %v", err)
+			}
+			parsed, err := Parse(strings.NewReader(string(b)))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if diff := cmp.Diff(l.Entries, parsed.Entries); diff != "" {
+				t.Fatalf("unexpected parsed entries (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(l.Unpinned, parsed.Unpinned); diff != "" {
+				t.Fatalf("unexpected parsed unpinned (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Verify(t *testing.T) {
+	t.Parallel()
+	l, err := Parse(strings.NewReader(`{"version": 1, "entries": [{"uri": "example.com/a", "digest": {"sha256": "aa", "md5": "bb"}}]}`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	tests := []struct {
+		name     string
+		uri      string
+		digests  slsa.DigestSet
+		found    bool
+		expected error
+	}{
+		{
+			name:    "match",
+			uri:     "example.com/a",
+			digests: slsa.DigestSet{"sha256": "aa"},
+			found:   true,
+		},
+		{
+			name:     "mismatch",
+			uri:      "example.com/a",
+			digests:  slsa.DigestSet{"sha256": "aa", "md5": "cc"},
+			found:    true,
+			expected: errs.ErrorDenied,
+		},
+		{
+			name:     "no common algorithm",
+			uri:      "example.com/a",
+			digests:  slsa.DigestSet{"sha512": "aa"},
+			found:    true,
+			expected: errs.ErrorDenied,
+		},
+		{
+			name: "not found",
+			uri:  "example.com/b",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			e, ok := l.Lookup(tt.uri)
+			if diff := cmp.Diff(tt.found, ok); diff != "" {
+				t.Fatalf("unexpected found (-want +got): \n%s", diff)
+			}
+			if !ok {
+				return
+			}
+			err := e.Verify(tt.digests)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Contains(t *testing.T) {
+	t.Parallel()
+	l, err := Parse(strings.NewReader(`{
+		"version": 1,
+		"entries": [{"uri": "hf://owner/name@main/config.json", "digest": {"sha256": "aa"}, "location": "huggingface.co/owner/name/resolve/main/config.json"}],
+		"unpinned": ["huggingface.co/api/models/owner/name"]
+	}`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	tests := []struct {
+		uri      string
+		expected bool
+	}{
+		{uri: "hf://owner/name@main/config.json", expected: true},
+		{uri: "huggingface.co/owner/name/resolve/main/config.json", expected: true},
+		{uri: "huggingface.co/api/models/owner/name", expected: true},
+		{uri: "huggingface.co/owner/name/resolve/main/model.bin"},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.uri, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(tt.expected, l.Contains(tt.uri)); diff != "" {
+				t.Fatalf("unexpected contains (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_FromProvenance(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		provenance string
+		entries    []Entry
+		expected   error
+	}{
+		{
+			name: "runtime dependencies",
+			provenance: `{
+				"_type": "https://in-toto.io/Statement/v1",
+				"predicateType": "https://slsa.dev/provenance/v1",
+				"subject": [{"name": "modelX", "digest": {"sha256": "aa"}}],
+				"predicate": {
+					"buildDefinition": {
+						"buildType": "https://slsa-framework/jupyter-lineage/back-position/0.1",
+						"resolvedDependencies": [
+							{"uri": "git+https://github.com/owner/repo", "digest": {"gitCommit": "bb"}},
+							{
+								"uri": "example.com/a",
+								"digest": {"sha256": "cc"},
+								"mediaType": "text/plain",
+								"annotations": {"Handler": "Handler/v0.1"}
+							}
+						]
+					},
+					"runDetails": {"builder": {"id": "https://colab.googleapis.com/ColabHostedKernel"}}
+				}
+			}`,
+			entries: []Entry{
+				{
+					URI:       "example.com/a",
+					DigestSet: slsa.DigestSet{"sha256": "cc"},
+					Handler:   "Handler/v0.1",
+					MediaType: "text/plain",
+				},
+			},
+		},
+		{
+			name:       "not a provenance",
+			provenance: `{"_type": "https://in-toto.io/Statement/v1", "predicateType": "https://spdx.dev/Document"}`,
+			expected:   errs.ErrorInvalid,
+		},
+		{
+			name:       "invalid json",
+			provenance: `{`,
+			expected:   errs.ErrorInvalid,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			l, err := FromProvenance([]byte(tt.provenance))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.entries, l.Entries); diff != "" {
+				t.Fatalf("unexpected entries (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package slsa
+
+// DependencyKind is the category of a dependency.
+type DependencyKind string
+
+const (
+	// Software package, e.g. a container image or a pip package.
+	KindPackage DependencyKind = "package"
+	// Machine learning model, e.g. weights or a tokenizer.
+	KindModel DependencyKind = "model"
+	// Dataset, or a file of a dataset.
+	KindDataset DependencyKind = "dataset"
+	// Source code, e.g. a repository archive.
+	KindCode DependencyKind = "code"
+	// Any other file.
+	KindFile DependencyKind = "file"
+)
+
+// IsValid returns true for known kinds.
+func (k DependencyKind) IsValid() bool {
+	switch k {
+	case KindPackage, KindModel, KindDataset, KindCode, KindFile:
+		return true
+	}
+	return false
+}
+
+// Ecosystems dependencies are downloaded from.
+const (
+	EcosystemHuggingface   = "huggingface"
+	EcosystemKaggle        = "kaggle"
+	EcosystemTensorflowHub = "tensorflow-hub"
+	EcosystemPytorchHub    = "pytorch-hub"
+	EcosystemOCI           = "oci"
+	EcosystemS3            = "s3"
+	EcosystemGCS           = "gcs"
+	// Generic downloads over HTTP.
+	EcosystemHTTP = "http"
+)
+
+// AnnotationDependency is the annotation recording the kind
+// and ecosystem of a dependency, e.g.
+// "Dependency": {"Kind": "model", "Ecosystem": "huggingface"}.
+const AnnotationDependency = "Dependency"
+
+// SetDependency annotates rd with its kind and ecosystem.
+func (rd *ResourceDescriptor) SetDependency(kind DependencyKind, ecosystem string) {
+	if rd.Annotations == nil {
+		rd.Annotations = map[string]any{}
+	}
+	rd.Annotations[AnnotationDependency] = map[string]any{
+		"Kind":      string(kind),
+		"Ecosystem": ecosystem,
+	}
+}
+
+// Dependency returns the kind and ecosystem of rd,
+// as set by SetDependency.
+func (rd *ResourceDescriptor) Dependency() (DependencyKind, string, bool) {
+	annotation, ok := rd.Annotations[AnnotationDependency].(map[string]any)
+	if !ok {
+		return "", "", false
+	}
+	kind, _ := annotation["Kind"].(string)
+	ecosystem, _ := annotation["Ecosystem"].(string)
+	return DependencyKind(kind), ecosystem, kind != ""
+}
+
+// DependenciesOfKind returns the dependencies of the kind,
+// e.g. all the datasets used.
+func DependenciesOfKind(deps []ResourceDescriptor, kind DependencyKind) []ResourceDescriptor {
+	var res []ResourceDescriptor
+	for i := range deps {
+		if k, _, ok := deps[i].Dependency(); ok && k == kind {
+			res = append(res, deps[i])
+		}
+	}
+	return res
+}
@@ -0,0 +1,35 @@
+package slsa
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_DependenciesOfKind(t *testing.T) {
+	t.Parallel()
+	model := ResourceDescriptor{URI: "hf://owner/model"}
+	model.SetDependency(KindModel, EcosystemHuggingface)
+	dataset := ResourceDescriptor{URI: "hf://datasets/owner/dataset"}
+	dataset.SetDependency(KindDataset, EcosystemHuggingface)
+	deps := []ResourceDescriptor{model, dataset, {URI: "example.com/a"}}
+
+	// Dependencies are read back from provenance files.
+	b, err := json.Marshal(deps)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var parsed []ResourceDescriptor
+	if err := json.Unmarshal(b, &parsed); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	datasets := DependenciesOfKind(parsed, KindDataset)
+	if diff := cmp.Diff([]ResourceDescriptor{dataset}, datasets); diff != "" {
+		t.Fatalf("unexpected datasets (-want +got): \n%s", diff)
+	}
+	kind, ecosystem, ok := datasets[0].Dependency()
+	if diff := cmp.Diff([]any{KindDataset, EcosystemHuggingface, true}, []any{kind, ecosystem, ok}); diff != "" {
+		t.Fatalf("unexpected dependency (-want +got): \n%s", diff)
+	}
+}
@@ -0,0 +1,165 @@
+package slsa
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
+)
+
+// See https://github.com/secure-systems-lab/dsse/blob/master/envelope.md.
+
+// PayloadType is the DSSE payload type of in-toto statements.
+const PayloadType = "application/vnd.in-toto+json"
+
+type Envelope struct {
+	PayloadType string      `json:"payloadType"`
+	Payload     string      `json:"payload"`
+	Signatures  []Signature `json:"signatures"`
+}
+
+type Signature struct {
+	KeyID string `json:"keyid,omitempty"`
+	Sig   string `json:"sig"`
+}
+
+// PublicKey is a key trusted to sign envelopes.
+// An empty KeyID matches any signature.
+type PublicKey struct {
+	KeyID string
+	Key   crypto.PublicKey
+}
+
+// pae returns the pre-authentication encoding of the payload,
+// which is what is signed.
+func pae(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+}
+
+// hashFor returns the hash used to sign with key.
+// Ed25519 signs the message itself.
+func hashFor(key crypto.PublicKey) (crypto.Hash, error) {
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		return crypto.Hash(0), nil
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return crypto.SHA256, nil
+		case elliptic.P384():
+			return crypto.SHA384, nil
+		case elliptic.P521():
+			return crypto.SHA512, nil
+		}
+		return 0, fmt.Errorf("%w: ecdsa curve (%v)", errs.ErrorInvalid, k.Curve.Params().Name)
+	case *rsa.PublicKey:
+		return crypto.SHA256, nil
+	}
+	return 0, fmt.Errorf("%w: key type (%T)", errs.ErrorInvalid, key)
+}
+
+func digest(h crypto.Hash, message []byte) []byte {
+	if h == crypto.Hash(0) {
+		return message
+	}
+	hash := h.New()
+	hash.Write(message)
+	return hash.Sum(nil)
+}
+
+// Sign wraps statement in a DSSE envelope signed by signer.
+// Ed25519, ECDSA (P-256, P-384, P-521) and RSA (PKCS #1 v1.5) keys are supported.
+func Sign(statement []byte, signer crypto.Signer, keyID string) ([]byte, error) {
+	h, err := hashFor(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	sig, err := signer.Sign(rand.Reader, digest(h, pae(PayloadType, statement)), h)
+	if err != nil {
+		return nil, fmt.Errorf("sign: %w", err)
+	}
+	envelope := Envelope{
+		PayloadType: PayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(statement),
+		Signatures: []Signature{
+			{
+				KeyID: keyID,
+				Sig:   base64.StdEncoding.EncodeToString(sig),
+			},
+		},
+	}
+	content, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, fmt.Errorf("marshal: %w", err)
+	}
+	return content, nil
+}
+
+func verifySignature(key crypto.PublicKey, message, sig []byte) error {
+	h, err := hashFor(key)
+	if err != nil {
+		return err
+	}
+	d := digest(h, message)
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, d, sig) {
+			return fmt.Errorf("%w: ed25519 signature", errs.ErrorDenied)
+		}
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(k, d, sig) {
+			return fmt.Errorf("%w: ecdsa signature", errs.ErrorDenied)
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(k, h, d, sig); err != nil {
+			return fmt.Errorf("%w: rsa signature: %w", errs.ErrorDenied, err)
+		}
+	}
+	return nil
+}
+
+// Verify verifies that the envelope is signed by one of the keys,
+// and returns its payload, the statement.
+func Verify(envelope []byte, keys ...PublicKey) ([]byte, error) {
+	var env Envelope
+	if err := json.Unmarshal(envelope, &env); err != nil {
+		return nil, fmt.Errorf("%w: unmarshal: %w", errs.ErrorInvalid, err)
+	}
+	if env.PayloadType != PayloadType {
+		return nil, fmt.Errorf("%w: payload type (%q)", errs.ErrorInvalid, env.PayloadType)
+	}
+	payload, err := base64.StdEncoding.DecodeString(env.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: payload: %w", errs.ErrorInvalid, err)
+	}
+	if len(env.Signatures) == 0 {
+		return nil, fmt.Errorf("%w: unsigned envelope", errs.ErrorDenied)
+	}
+	message := pae(env.PayloadType, payload)
+	var errors []error
+	for _, s := range env.Signatures {
+		sig, err := base64.StdEncoding.DecodeString(s.Sig)
+		if err != nil {
+			errors = append(errors, fmt.Errorf("%w: signature (%q): %w", errs.ErrorInvalid, s.KeyID, err))
+			continue
+		}
+		for _, k := range keys {
+			if k.KeyID != "" && s.KeyID != "" && k.KeyID != s.KeyID {
+				continue
+			}
+			if err := verifySignature(k.Key, message, sig); err != nil {
+				errors = append(errors, err)
+				continue
+			}
+			return payload, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no valid signature: %v", errs.ErrorDenied, errors)
+}
@@ -0,0 +1,155 @@
+package slsa
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
+)
+
+func Test_SignVerify(t *testing.T) {
+	t.Parallel()
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	p256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	statement := []byte(`{"_type":"https://in-toto.io/Statement/v1"}`)
+	tamper := func(envelope []byte) []byte {
+		var env Envelope
+		if err := json.Unmarshal(envelope, &env); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		env.Payload = base64.StdEncoding.EncodeToString([]byte(`{"_type":"forged"}`))
+		b, err := json.Marshal(env)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		return b
+	}
+	unsign := func(envelope []byte) []byte {
+		var env Envelope
+		if err := json.Unmarshal(envelope, &env); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		env.Signatures = nil
+		b, err := json.Marshal(env)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		return b
+	}
+	tests := []struct {
+		name     string
+		signer   crypto.Signer
+		keyID    string
+		keys     []PublicKey
+		modify   func([]byte) []byte
+		expected error
+	}{
+		{
+			name:   "ed25519",
+			signer: ed25519Key,
+			keys:   []PublicKey{{Key: ed25519Key.Public()}},
+		},
+		{
+			name:   "ecdsa p256 with keyid",
+			signer: p256Key,
+			keyID:  "key1",
+			keys:   []PublicKey{{KeyID: "key0", Key: otherKey.Public()}, {KeyID: "key1", Key: p256Key.Public()}},
+		},
+		{
+			name:   "ecdsa p384",
+			signer: p384Key,
+			keys:   []PublicKey{{Key: p384Key.Public()}},
+		},
+		{
+			name:   "rsa",
+			signer: rsaKey,
+			keys:   []PublicKey{{Key: rsaKey.Public()}},
+		},
+		{
+			name:     "keyid mismatch",
+			signer:   p256Key,
+			keyID:    "key1",
+			keys:     []PublicKey{{KeyID: "key2", Key: p256Key.Public()}},
+			expected: errs.ErrorDenied,
+		},
+		{
+			name:     "wrong key",
+			signer:   p256Key,
+			keys:     []PublicKey{{Key: otherKey.Public()}},
+			expected: errs.ErrorDenied,
+		},
+		{
+			name:     "tampered",
+			signer:   ed25519Key,
+			keys:     []PublicKey{{Key: ed25519Key.Public()}},
+			modify:   tamper,
+			expected: errs.ErrorDenied,
+		},
+		{
+			name:     "unsigned",
+			signer:   ed25519Key,
+			keys:     []PublicKey{{Key: ed25519Key.Public()}},
+			modify:   unsign,
+			expected: errs.ErrorDenied,
+		},
+		{
+			name:   "not an envelope",
+			signer: ed25519Key,
+			keys:   []PublicKey{{Key: ed25519Key.Public()}},
+			modify: func([]byte) []byte {
+				return statement
+			},
+			expected: errs.ErrorInvalid,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			envelope, err := Sign(statement, tt.signer, tt.keyID)
+			if err != nil {
+				t.Fatalf("Sign: %v", err)
+			}
+			if tt.modify != nil {
+				envelope = tt.modify(envelope)
+			}
+			payload, err := Verify(envelope, tt.keys...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(string(statement), string(payload)); diff != "" {
+				t.Fatalf("unexpected payload (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
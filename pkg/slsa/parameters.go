@@ -0,0 +1,46 @@
+package slsa
+
+// ExternalParameters are the parameters of the session
+// under the control of the notebook user.
+type ExternalParameters struct {
+	Notebook *Notebook `json:"notebook,omitempty"`
+	Kernel   *Kernel   `json:"kernel,omitempty"`
+	// Cells are the cells executed, in order.
+	Cells []Cell `json:"cells,omitempty"`
+}
+
+// Cell is the execution of a cell. Its source is recorded in the repository.
+type Cell struct {
+	ExecutionCount int `json:"executionCount,omitempty"`
+	// Digest of the source of the cell.
+	DigestSet DigestSet `json:"digest"`
+}
+
+// Notebook is the notebook run in the session.
+type Notebook struct {
+	Path      string    `json:"path"`
+	DigestSet DigestSet `json:"digest,omitempty"`
+}
+
+// Kernel is the kernel the notebook ran on, as reported
+// by its kernel_info_reply message.
+// See https://jupyter-client.readthedocs.io/en/latest/messaging.html#kernel-info.
+type Kernel struct {
+	Implementation        string `json:"implementation,omitempty"`
+	ImplementationVersion string `json:"implementationVersion,omitempty"`
+	Language              string `json:"language,omitempty"`
+	LanguageVersion       string `json:"languageVersion,omitempty"`
+	ProtocolVersion       string `json:"protocolVersion,omitempty"`
+}
+
+// InternalParameters are the parameters of the session
+// set by the operator of the proxy.
+type InternalParameters struct {
+	// Handlers are the names of the HTTP handlers, in order.
+	Handlers []string  `json:"handlers,omitempty"`
+	Policy   DigestSet `json:"policy,omitempty"`
+	Lockfile DigestSet `json:"lockfile,omitempty"`
+	// CA is the digest of the DER certificate of the CA
+	// used to intercept TLS connections.
+	CA DigestSet `json:"ca,omitempty"`
+}
@@ -0,0 +1,71 @@
+package slsa
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
+)
+
+// See https://github.com/in-toto/in-toto-golang/tree/master/in_toto/slsa_provenance/v1
+
+const (
+	StatementType = "https://in-toto.io/Statement/v1"
+	PredicateType = "https://slsa.dev/provenance/v1"
+	// BuildType of the provenance generated by jnproxy.
+	BuildType = "https://slsa-framework/jupyter-lineage/back-position/0.1"
+	// RepositoryName is the name of the resolved dependency
+	// describing the lineage repository.
+	RepositoryName = "repository"
+	// CheckpointName is the name of the resolved dependency
+	// describing the previous checkpoint of the session.
+	CheckpointName = "checkpoint"
+)
+
+// Statement is an in-toto statement with a SLSA provenance predicate.
+type Statement struct {
+	Type          string    `json:"_type"`
+	PredicateType string    `json:"predicateType"`
+	Subjects      []Subject `json:"subject"`
+	Predicate     Predicate `json:"predicate"`
+}
+
+type BuildDefinition struct {
+	BuildType            string               `json:"buildType"`
+	ExternalParameters   ExternalParameters   `json:"externalParameters"`
+	InternalParameters   *InternalParameters  `json:"internalParameters,omitempty"`
+	ResolvedDependencies []ResourceDescriptor `json:"resolvedDependencies,omitempty"`
+}
+
+type RunDetails struct {
+	Builder       Builder              `json:"builder"`
+	BuildMetadata BuildMetadata        `json:"metadata,omitempty"`
+	Byproducts    []ResourceDescriptor `json:"byproducts,omitempty"`
+}
+
+type BuildMetadata struct {
+	InvocationID string `json:"invocationID,omitempty"`
+	StartedOn    string `json:"startedOn,omitempty"`
+	FinishedOn   string `json:"finishedOn,omitempty"`
+}
+
+type Predicate struct {
+	BuildDefinition BuildDefinition `json:"buildDefinition"`
+	RunDetails      RunDetails      `json:"runDetails"`
+}
+
+// ParseProvenance parses a provenance statement, e.g. as
+// generated by JNProxy.Provenance.
+func ParseProvenance(content []byte) (*Statement, error) {
+	var s Statement
+	if err := json.Unmarshal(content, &s); err != nil {
+		return nil, fmt.Errorf("%w: unmarshal: %w", errs.ErrorInvalid, err)
+	}
+	if s.Type != StatementType {
+		return nil, fmt.Errorf("%w: statement type (%q)", errs.ErrorInvalid, s.Type)
+	}
+	if s.PredicateType != PredicateType {
+		return nil, fmt.Errorf("%w: predicate type (%q)", errs.ErrorInvalid, s.PredicateType)
+	}
+	return &s, nil
+}
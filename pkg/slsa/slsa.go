@@ -10,6 +10,8 @@ type Subject struct {
 type Builder struct {
 	ID      string `json:"id"`
 	Version string `json:"version,omitempty"`
+	// Dependencies of the builder itself, e.g. the proxy binary.
+	BuilderDependencies []ResourceDescriptor `json:"builderDependencies,omitempty"`
 }
 
 type ResourceDescriptor struct {
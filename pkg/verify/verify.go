@@ -0,0 +1,261 @@
+package verify
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+type Option func(*verifier) error
+
+type verifier struct {
+	keys      []slsa.PublicKey
+	repoDir   string
+	cacheDirs []string
+}
+
+// Result describes what was verified.
+type Result struct {
+	Statement *slsa.Statement
+	// Signed is true if the signature of the envelope was verified.
+	Signed bool
+	// Files are the cached dependency files whose sha256 was rechecked.
+	Files []string
+}
+
+// Provenance verifies a provenance generated by JNProxy, either a statement
+// or a DSSE envelope. If public keys are provided, the provenance must be an
+// envelope signed by one of them. If a repository directory is provided,
+// its commit must be the one recorded. If cache directories are provided,
+// the dependency files found in them must have the recorded sha256.
+func Provenance(content []byte, options ...Option) (*Result, error) {
+	v := &verifier{}
+	for _, option := range options {
+		if err := option(v); err != nil {
+			return nil, err
+		}
+	}
+	payload, signed, err := v.verifySignature(content)
+	if err != nil {
+		return nil, err
+	}
+	statement, err := slsa.ParseProvenance(payload)
+	if err != nil {
+		return nil, err
+	}
+	if statement.Predicate.BuildDefinition.BuildType != slsa.BuildType {
+		return nil, fmt.Errorf("%w: build type (%q)", errs.ErrorInvalid, statement.Predicate.BuildDefinition.BuildType)
+	}
+	res := &Result{
+		Statement: statement,
+		Signed:    signed,
+	}
+	deps := statement.Predicate.BuildDefinition.ResolvedDependencies
+	if v.repoDir != "" {
+		if err := verifyRepository(v.repoDir, deps); err != nil {
+			return nil, err
+		}
+	}
+	for _, dir := range v.cacheDirs {
+		files, err := verifyCache(dir, deps)
+		if err != nil {
+			return nil, err
+		}
+		res.Files = append(res.Files, files...)
+	}
+	return res, nil
+}
+
+func isEnvelope(content []byte) bool {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return false
+	}
+	_, ok := fields["payloadType"]
+	return ok
+}
+
+// verifySignature returns the statement and whether its signature was verified.
+func (v *verifier) verifySignature(content []byte) ([]byte, bool, error) {
+	if !isEnvelope(content) {
+		if len(v.keys) > 0 {
+			return nil, false, fmt.Errorf("%w: provenance not signed", errs.ErrorDenied)
+		}
+		return content, false, nil
+	}
+	if len(v.keys) == 0 {
+		return nil, false, fmt.Errorf("%w: no public key to verify the envelope", errs.ErrorInvalid)
+	}
+	payload, err := slsa.Verify(content, v.keys...)
+	if err != nil {
+		return nil, false, err
+	}
+	return payload, true, nil
+}
+
+func repository(deps []slsa.ResourceDescriptor) (*slsa.ResourceDescriptor, error) {
+	for i := range deps {
+		if deps[i].Name == slsa.RepositoryName {
+			return &deps[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no repository dependency", errs.ErrorInvalid)
+}
+
+func git(dir string, args ...string) (string, error) {
+	command := exec.Command("git", args...)
+	command.Dir = dir
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	command.Stdout = stdout
+	command.Stderr = stderr
+	if err := command.Run(); err != nil {
+		return "", fmt.Errorf("git %v: (stderr=%q): %w", args, stderr.String(), err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// verifyRepository verifies that the commit of the repository in dir
+// is the one recorded, and that its tracked files are unchanged.
+// Untracked files, like the provenance itself, are ignored.
+func verifyRepository(dir string, deps []slsa.ResourceDescriptor) error {
+	rd, err := repository(deps)
+	if err != nil {
+		return err
+	}
+	expected, ok := rd.DigestSet["gitCommit"]
+	if !ok {
+		return fmt.Errorf("%w: repository has no gitCommit digest", errs.ErrorInvalid)
+	}
+	actual, err := git(dir, "rev-parse", "HEAD")
+	if err != nil {
+		return err
+	}
+	if actual != expected {
+		return fmt.Errorf("%w: repository digest mismatch. Expected (%v) != actual (%v)", errs.ErrorDenied, expected, actual)
+	}
+	status, err := git(dir, "status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return err
+	}
+	if status != "" {
+		return fmt.Errorf("%w: repository has changes: %q", errs.ErrorDenied, status)
+	}
+	return nil
+}
+
+// cachePaths returns the paths where the Hugging Face Hub cache
+// stores the file of rd, relative to the cache directory.
+// See https://huggingface.co/docs/huggingface_hub/guides/manage-cache.
+func cachePaths(rd slsa.ResourceDescriptor) []string {
+	hf, ok := rd.Annotations["Huggingface"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	var paths []string
+	repoType, _ := hf["Type"].(string)
+	repo, _ := hf["Repo"].(string)
+	commit, _ := hf["Commit"].(string)
+	file, _ := hf["File"].(string)
+	if repoType == "" || repo == "" {
+		return nil
+	}
+	repoDir := repoType + "s--" + strings.ReplaceAll(repo, "/", "--")
+	if commit != "" && file != "" {
+		paths = append(paths, filepath.Join(repoDir, "snapshots", commit, filepath.FromSlash(file)))
+	}
+	// LFS files are stored under their sha256.
+	if lfs, ok := hf["LFSSHA256"].(string); ok {
+		paths = append(paths, filepath.Join(repoDir, "blobs", lfs))
+	}
+	return paths
+}
+
+func fileSHA256(fn string) (string, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// verifyCache rechecks the sha256 of the dependency files cached in dir.
+// Files not in the cache are skipped.
+func verifyCache(dir string, deps []slsa.ResourceDescriptor) ([]string, error) {
+	var files []string
+	for _, rd := range deps {
+		expected, ok := rd.DigestSet["sha256"]
+		if !ok {
+			continue
+		}
+		for _, p := range cachePaths(rd) {
+			fn := filepath.Join(dir, p)
+			actual, err := fileSHA256(fn)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("file (%q): %w", fn, err)
+			}
+			if actual != strings.ToLower(expected) {
+				return nil, fmt.Errorf("%w: file (%q) of uri (%q) sha256 mismatch. Expected (%v) != actual (%v)",
+					errs.ErrorDenied, fn, rd.URI, expected, actual)
+			}
+			files = append(files, fn)
+		}
+	}
+	return files, nil
+}
+
+// WithPublicKeys requires the provenance to be signed by one of the keys.
+func WithPublicKeys(keys ...slsa.PublicKey) Option {
+	return func(v *verifier) error {
+		for _, k := range keys {
+			if k.Key == nil {
+				return fmt.Errorf("%w: nil public key (%q)", errs.ErrorInvalid, k.KeyID)
+			}
+		}
+		v.keys = append(v.keys, keys...)
+		return nil
+	}
+}
+
+// WithRepository verifies the lineage repository in dir.
+func WithRepository(dir string) Option {
+	return func(v *verifier) error {
+		if dir == "" {
+			return fmt.Errorf("%w: empty repository directory", errs.ErrorInvalid)
+		}
+		v.repoDir = dir
+		return nil
+	}
+}
+
+// WithCacheDirs rechecks the dependency files cached in the
+// directories, e.g. ~/.cache/huggingface/hub.
+func WithCacheDirs(dirs ...string) Option {
+	return func(v *verifier) error {
+		for _, d := range dirs {
+			if d == "" {
+				return fmt.Errorf("%w: empty cache directory", errs.ErrorInvalid)
+			}
+		}
+		v.cacheDirs = append(v.cacheDirs, dirs...)
+		return nil
+	}
+}
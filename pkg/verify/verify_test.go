@@ -0,0 +1,233 @@
+package verify
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/laurentsimon/jupyter-lineage/pkg/errs"
+	"github.com/laurentsimon/jupyter-lineage/pkg/slsa"
+)
+
+const commit = "0123456789abcdef0123456789abcdef01234567"
+
+func sha256Hex(content string) string {
+	h := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(h[:])
+}
+
+func statement(t *testing.T, buildType string, repo slsa.DigestSet) []byte {
+	s := slsa.Statement{
+		Type:          slsa.StatementType,
+		PredicateType: slsa.PredicateType,
+		Predicate: slsa.Predicate{
+			BuildDefinition: slsa.BuildDefinition{
+				BuildType: buildType,
+				ResolvedDependencies: []slsa.ResourceDescriptor{
+					{
+						Name:      slsa.RepositoryName,
+						DigestSet: repo,
+					},
+					{
+						URI:       "https://huggingface.co/org/model/resolve/main/config.json",
+						DigestSet: slsa.DigestSet{"sha256": sha256Hex("config")},
+						Annotations: map[string]any{
+							"Handler": "HuggingfaceModel/v0.1",
+							"Huggingface": map[string]any{
+								"Type":   "model",
+								"Repo":   "org/model",
+								"Commit": commit,
+								"File":   "config.json",
+							},
+						},
+					},
+					{
+						URI:       "https://cdn-lfs.huggingface.co/repos/aa/bb/" + sha256Hex("weights"),
+						DigestSet: slsa.DigestSet{"sha256": sha256Hex("weights")},
+						Annotations: map[string]any{
+							"Handler": "HuggingfaceModel/v0.1",
+							"Huggingface": map[string]any{
+								"Type":      "model",
+								"Repo":      "org/model",
+								"LFSSHA256": sha256Hex("weights"),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return b
+}
+
+func writeFile(t *testing.T, fn, content string) {
+	if err := os.MkdirAll(filepath.Dir(fn), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(fn, []byte(content), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
+
+func Test_Provenance(t *testing.T) {
+	t.Parallel()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	sign := func(statement []byte) []byte {
+		envelope, err := slsa.Sign(statement, key, "")
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		return envelope
+	}
+	// Hugging Face Hub cache.
+	cache := t.TempDir()
+	writeFile(t, filepath.Join(cache, "models--org--model", "snapshots", commit, "config.json"), "config")
+	writeFile(t, filepath.Join(cache, "models--org--model", "blobs", sha256Hex("weights")), "weights")
+	tampered := t.TempDir()
+	writeFile(t, filepath.Join(tampered, "models--org--model", "snapshots", commit, "config.json"), "tampered")
+	repo := slsa.DigestSet{"gitCommit": commit}
+	tests := []struct {
+		name     string
+		content  []byte
+		options  []Option
+		signed   bool
+		files    []string
+		expected error
+	}{
+		{
+			name:    "unsigned statement",
+			content: statement(t, slsa.BuildType, repo),
+		},
+		{
+			name:    "signed envelope",
+			content: sign(statement(t, slsa.BuildType, repo)),
+			options: []Option{WithPublicKeys(slsa.PublicKey{Key: key.Public()})},
+			signed:  true,
+		},
+		{
+			name:     "wrong key",
+			content:  sign(statement(t, slsa.BuildType, repo)),
+			options:  []Option{WithPublicKeys(slsa.PublicKey{Key: otherKey.Public()})},
+			expected: errs.ErrorDenied,
+		},
+		{
+			name:     "statement not signed",
+			content:  statement(t, slsa.BuildType, repo),
+			options:  []Option{WithPublicKeys(slsa.PublicKey{Key: key.Public()})},
+			expected: errs.ErrorDenied,
+		},
+		{
+			name:     "envelope without key",
+			content:  sign(statement(t, slsa.BuildType, repo)),
+			expected: errs.ErrorInvalid,
+		},
+		{
+			name:     "build type",
+			content:  statement(t, "https://example.com/other", repo),
+			expected: errs.ErrorInvalid,
+		},
+		{
+			name:    "cached files",
+			content: statement(t, slsa.BuildType, repo),
+			options: []Option{WithCacheDirs(cache)},
+			files: []string{
+				filepath.Join(cache, "models--org--model", "snapshots", commit, "config.json"),
+				filepath.Join(cache, "models--org--model", "blobs", sha256Hex("weights")),
+			},
+		},
+		{
+			name:     "cached file mismatch",
+			content:  statement(t, slsa.BuildType, repo),
+			options:  []Option{WithCacheDirs(tampered)},
+			expected: errs.ErrorDenied,
+		},
+		{
+			name:    "files not cached",
+			content: statement(t, slsa.BuildType, repo),
+			options: []Option{WithCacheDirs(t.TempDir())},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			res, err := Provenance(tt.content, tt.options...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.signed, res.Signed); diff != "" {
+				t.Fatalf("unexpected signed (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.files, res.Files); diff != "" {
+				t.Fatalf("unexpected files (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Repository(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init"},
+		{"add", "--all"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-m", "msg"},
+	} {
+		if args[0] == "add" {
+			writeFile(t, filepath.Join(dir, "file"), "content")
+		}
+		if _, err := git(dir, args...); err != nil {
+			t.Fatalf("git: %v", err)
+		}
+	}
+	head, err := git(dir, "rev-parse", "HEAD")
+	if err != nil {
+		t.Fatalf("git: %v", err)
+	}
+	content := statement(t, slsa.BuildType, slsa.DigestSet{"gitCommit": head})
+	if _, err := Provenance(content, WithRepository(dir)); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	// Untracked files are ignored.
+	writeFile(t, filepath.Join(dir, "prov.json"), "provenance")
+	if _, err := Provenance(content, WithRepository(dir)); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	// Changes to the recorded files are not.
+	writeFile(t, filepath.Join(dir, "file"), "modified")
+	_, err = Provenance(content, WithRepository(dir))
+	if diff := cmp.Diff(errs.ErrorDenied, err, cmpopts.EquateErrors()); diff != "" {
+		t.Fatalf("unexpected err (-want +got): \n%s", diff)
+	}
+	// Other commits.
+	content = statement(t, slsa.BuildType, slsa.DigestSet{"gitCommit": commit})
+	_, err = Provenance(content, WithRepository(dir))
+	if diff := cmp.Diff(errs.ErrorDenied, err, cmpopts.EquateErrors()); diff != "" {
+		t.Fatalf("unexpected err (-want +got): \n%s", diff)
+	}
+}